@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Symantec/Dominator/imagebuilder/client"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func buildLogSubcommand(args []string, logger log.DebugLogger) {
+	if err := showBuildLog(args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting build log: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showBuildLog(streamName, id string) error {
+	buildLog, err := client.GetBuildLog(getImaginatorClient(), streamName, id)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(buildLog)
+	return err
+}
+
+func historySubcommand(args []string, logger log.DebugLogger) {
+	var maxRecords string
+	if len(args) > 1 {
+		maxRecords = args[1]
+	}
+	if err := showHistory(args[0], maxRecords); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting build history: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showHistory(streamName, maxRecordsString string) error {
+	var maxRecords uint64
+	if maxRecordsString != "" {
+		var err error
+		maxRecords, err = strconv.ParseUint(maxRecordsString, 10, 32)
+		if err != nil {
+			return err
+		}
+	}
+	records, err := client.GetBuildHistory(getImaginatorClient(), streamName,
+		uint(maxRecords))
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		user := record.Username
+		if user == "" {
+			user = "auto"
+		}
+		result := record.ImageName
+		if record.Error != "" {
+			result = "error: " + record.Error
+		} else if record.CacheResult != "" {
+			result += " (cache " + record.CacheResult + ")"
+		}
+		if usage := record.ResourceUsage; usage != nil {
+			result += fmt.Sprintf(" CPU: %s memory: %s",
+				format.Duration(usage.CpuTime),
+				format.FormatBytes(usage.MaxMemory))
+		}
+		fmt.Printf("%s %s by: %s %s\n", record.Id,
+			format.Duration(record.FinishTime.Sub(record.StartTime)), user,
+			result)
+	}
+	return nil
+}
@@ -35,6 +35,8 @@ var (
 		"Port number of image server")
 	maxSourceAge = flag.Duration("maxSourceAge", time.Hour,
 		"Maximum age of a source image before it is rebuilt")
+	priority = flag.Int("priority", 0,
+		"Build priority (higher is built first, raising requires privilege)")
 	rawSize flagutil.Size
 
 	minimumExpiration = 5 * time.Minute
@@ -54,9 +56,14 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Commands:")
 	fmt.Fprintln(os.Stderr, "  build-from-manifest manifestDir stream-name")
 	fmt.Fprintln(os.Stderr, "  build-image stream-name [git-branch]")
+	fmt.Fprintln(os.Stderr, "  build-log stream-name id")
 	fmt.Fprintln(os.Stderr, "  build-raw-from-manifest manifestDir rawFile")
 	fmt.Fprintln(os.Stderr, "  build-tree-from-manifest manifestDir")
+	fmt.Fprintln(os.Stderr, "  cancel-build id")
+	fmt.Fprintln(os.Stderr, "  history stream-name [max-records]")
 	fmt.Fprintln(os.Stderr, "  process-manifest manifestDir rootDir")
+	fmt.Fprintln(os.Stderr, "  queue")
+	fmt.Fprintln(os.Stderr, "  verify-reproducible stream-name [git-branch]")
 }
 
 type commandFunc func([]string, log.DebugLogger)
@@ -71,9 +78,14 @@ type subcommand struct {
 var subcommands = []subcommand{
 	{"build-from-manifest", 2, 2, buildFromManifestSubcommand},
 	{"build-image", 1, 2, buildImageSubcommand},
+	{"build-log", 2, 2, buildLogSubcommand},
 	{"build-raw-from-manifest", 2, 2, buildRawFromManifestSubcommand},
 	{"build-tree-from-manifest", 1, 1, buildTreeFromManifestSubcommand},
+	{"cancel-build", 1, 1, cancelBuildSubcommand},
+	{"history", 1, 2, historySubcommand},
 	{"process-manifest", 2, 2, processManifestSubcommand},
+	{"queue", 0, 0, queueSubcommand},
+	{"verify-reproducible", 1, 2, verifyReproducibleSubcommand},
 }
 
 var imaginatorSrpcClient *srpc.Client
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/imagebuilder/client"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func cancelBuildSubcommand(args []string, logger log.DebugLogger) {
+	if err := cancelBuild(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error cancelling build: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func cancelBuild(idString string) error {
+	id, err := strconv.ParseUint(idString, 10, 64)
+	if err != nil {
+		return err
+	}
+	return client.CancelBuild(getImaginatorClient(), id)
+}
+
+func queueSubcommand(args []string, logger log.DebugLogger) {
+	if err := showQueue(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting build queue: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showQueue() error {
+	entries, err := client.GetBuildQueue(getImaginatorClient())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		var state string
+		if entry.StartedAt.IsZero() {
+			state = "queued for " +
+				format.Duration(time.Since(entry.QueuedAt))
+		} else {
+			state = "building for " +
+				format.Duration(time.Since(entry.StartedAt))
+		}
+		streamName := entry.StreamName
+		if entry.GitBranch != "" {
+			streamName += " (" + entry.GitBranch + ")"
+		}
+		requesters := "auto"
+		if len(entry.Requesters) > 0 {
+			requesters = strings.Join(entry.Requesters, ",")
+		}
+		fmt.Printf("%d %s priority: %d %s by: %s\n",
+			entry.Id, streamName, entry.Priority, state, requesters)
+	}
+	return nil
+}
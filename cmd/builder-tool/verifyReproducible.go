@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/imagebuilder/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func verifyReproducibleSubcommand(args []string, logger log.DebugLogger) {
+	if err := verifyReproducible(args, logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying reproducible build: %s\n",
+			err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func verifyReproducible(args []string, logger log.Logger) error {
+	request := proto.BuildImageRequest{
+		StreamName:     args[0],
+		ExpiresIn:      *expiresIn,
+		MaxSourceAge:   *maxSourceAge,
+		Reproducible:   true,
+		ReturnImage:    true,
+		StreamBuildLog: true,
+	}
+	if len(args) > 1 {
+		request.GitBranch = args[1]
+	}
+	var images [2]*image.Image
+	for index := range images {
+		logger.Printf("Starting build %d of %d\n", index+1, len(images))
+		img, err := buildImageForVerify(request)
+		if err != nil {
+			return err
+		}
+		images[index] = img
+	}
+	differences := &bytes.Buffer{}
+	if !filesystem.CompareFileSystems(images[0].FileSystem,
+		images[1].FileSystem, differences) {
+		os.Stderr.Write(differences.Bytes())
+		return errors.New("builds are not reproducible")
+	}
+	logger.Println("Builds are reproducible")
+	return nil
+}
+
+func buildImageForVerify(request proto.BuildImageRequest) (
+	*image.Image, error) {
+	logBuffer := &bytes.Buffer{}
+	var logWriter io.Writer = logBuffer
+	if *alwaysShowBuildLog {
+		logWriter = os.Stderr
+	}
+	var reply proto.BuildImageResponse
+	err := client.BuildImage(getImaginatorClient(), request, &reply, logWriter)
+	if err != nil {
+		if !*alwaysShowBuildLog {
+			os.Stderr.Write(logBuffer.Bytes())
+		}
+		return nil, err
+	}
+	if reply.Image == nil {
+		return nil, errors.New("no image returned: upgrade the Imaginator")
+	}
+	if err := reply.Image.FileSystem.RebuildInodePointers(); err != nil {
+		return nil, err
+	}
+	return reply.Image, nil
+}
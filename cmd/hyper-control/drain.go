@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func drainSubcommand(args []string, logger log.DebugLogger) error {
+	if err := drain(args[0], logger); err != nil {
+		return fmt.Errorf("Error draining Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func undrainSubcommand(args []string, logger log.DebugLogger) error {
+	if err := undrain(args[0], logger); err != nil {
+		return fmt.Errorf("Error undraining Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func dialFleetManager() (*srpc.Client, error) {
+	if *fleetManagerHostname == "" {
+		return nil, errors.New("no fleetManagerHostname specified")
+	}
+	clientName := fmt.Sprintf("%s:%d", *fleetManagerHostname,
+		*fleetManagerPortNum)
+	return srpc.DialHTTPWithDialer("tcp", clientName, rrDialer)
+}
+
+func drain(hostname string, logger log.DebugLogger) error {
+	client, err := dialFleetManager()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	conn, err := client.Call("FleetManager.DrainHypervisor")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := proto.DrainHypervisorRequest{
+		Hostname:       hostname,
+		MaxConcurrency: *drainConcurrency,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply proto.DrainHypervisorResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if reply.ProgressMessage != "" {
+			logger.Println(reply.ProgressMessage)
+		}
+		if reply.Final {
+			return errors.New(reply.Error)
+		}
+	}
+}
+
+func undrain(hostname string, logger log.DebugLogger) error {
+	client, err := dialFleetManager()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := proto.UndrainHypervisorRequest{hostname}
+	var reply proto.UndrainHypervisorResponse
+	err = client.RequestReply("FleetManager.UndrainHypervisor", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
@@ -19,6 +19,8 @@ import (
 )
 
 var (
+	drainConcurrency = flag.Uint("drainConcurrency", 2,
+		"Maximum number of VMs to migrate concurrently when draining")
 	fleetManagerHostname = flag.String("fleetManagerHostname", "",
 		"Hostname of Fleet Manager")
 	fleetManagerPortNum = flag.Uint("fleetManagerPortNum",
@@ -72,6 +74,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  add-address MACaddr [IPaddr]")
 	fmt.Fprintln(os.Stderr, "  add-subnet ID IPgateway IPmask DNSserver...")
 	fmt.Fprintln(os.Stderr, "  change-tags")
+	fmt.Fprintln(os.Stderr, "  drain hostname")
 	fmt.Fprintln(os.Stderr, "  get-machine-info hostname")
 	fmt.Fprintln(os.Stderr, "  get-updates")
 	fmt.Fprintln(os.Stderr, "  installer-shell hostname")
@@ -85,6 +88,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  remove-mac-address MACaddr")
 	fmt.Fprintln(os.Stderr, "  rollout-image name")
 	fmt.Fprintln(os.Stderr, "  show-network-configuration")
+	fmt.Fprintln(os.Stderr, "  undrain hostname")
 	fmt.Fprintln(os.Stderr, "  update-network-configuration")
 	fmt.Fprintln(os.Stderr, "  write-netboot-files hostname dirname")
 }
@@ -102,6 +106,7 @@ var subcommands = []subcommand{
 	{"add-address", 1, 2, addAddressSubcommand},
 	{"add-subnet", 4, -1, addSubnetSubcommand},
 	{"change-tags", 0, 0, changeTagsSubcommand},
+	{"drain", 1, 1, drainSubcommand},
 	{"get-machine-info", 1, 1, getMachineInfoSubcommand},
 	{"get-updates", 0, 0, getUpdatesSubcommand},
 	{"installer-shell", 1, 1, installerShellSubcommand},
@@ -115,6 +120,7 @@ var subcommands = []subcommand{
 	{"remove-mac-address", 1, 1, removeMacAddressSubcommand},
 	{"rollout-image", 1, 1, rolloutImageSubcommand},
 	{"show-network-configuration", 0, 0, showNetworkConfigurationSubcommand},
+	{"undrain", 1, 1, undrainSubcommand},
 	{"update-network-configuration", 0, 0,
 		updateNetworkConfigurationSubcommand},
 	{"write-netboot-files", 2, 2, writeNetbootFilesSubcommand},
@@ -48,6 +48,12 @@ var (
 		"Name of default image stream for network booting")
 	username = flag.String("username", "nobody",
 		"Name of user to run VMs")
+	vmIdentityCaCertFile = flag.String("vmIdentityCaCertFile",
+		"/etc/ssl/hypervisor/vm-identity-ca-cert.pem",
+		"Name of file containing CA certificate for signing VM certificates")
+	vmIdentityCaKeyFile = flag.String("vmIdentityCaKeyFile",
+		"/etc/ssl/hypervisor/vm-identity-ca-key.pem",
+		"Name of file containing CA key for signing VM certificates")
 	volumeDirectories flagutil.StringList
 )
 
@@ -157,6 +163,8 @@ func main() {
 		StateDir:           *stateDir,
 		Username:           *username,
 		VlanIdToBridge:     vlanIdToBridge,
+		VmCaCertFile:       *vmIdentityCaCertFile,
+		VmCaKeyFile:        *vmIdentityCaKeyFile,
 		VolumeDirectories:  volumeDirectories,
 	})
 	if err != nil {
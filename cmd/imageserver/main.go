@@ -4,13 +4,20 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/Symantec/Dominator/imageserver/auditlog"
 	"github.com/Symantec/Dominator/imageserver/httpd"
 	imageserverRpcd "github.com/Symantec/Dominator/imageserver/rpcd"
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/imageserver/vulndb"
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
+	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/fsrateio"
 	"github.com/Symantec/Dominator/lib/log/serverlogger"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
 	"github.com/Symantec/Dominator/lib/srpc/setupserver"
 	objectserverRpcd "github.com/Symantec/Dominator/objectserver/rpcd"
@@ -29,12 +36,24 @@ var (
 		"Port number of image server")
 	objectDir = flag.String("objectDir", "/var/lib/objectserver",
 		"Name of image server data directory.")
+	objectScrubInterval = flag.Duration("objectScrubInterval", time.Hour*24,
+		"Minimum time between the start of object scrub passes")
+	objectScrubMaxSpeed     = flagutil.Size(100 << 20)
+	objectScrubSpeedPercent = flag.Uint("objectScrubSpeedPercent", 10,
+		"Object scrub speed as percentage of objectScrubMaxSpeed (0: disable)")
 	permitInsecureMode = flag.Bool("permitInsecureMode", false,
 		"If true, run in insecure mode. This gives remote access to all")
 	portNum = flag.Uint("portNum", constants.ImageServerPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
+	vulnerabilityFeedDirectory = flag.String("vulnerabilityFeedDirectory", "",
+		"Name of directory containing OSV and OVAL vulnerability feed files")
 )
 
+func init() {
+	flag.Var(&objectScrubMaxSpeed, "objectScrubMaxSpeed",
+		"Maximum read speed (bytes/second) of the object storage media")
+}
+
 type imageObjectServersType struct {
 	imdb   *scanner.ImageDataBase
 	objSrv *filesystem.ObjectServer
@@ -68,6 +87,21 @@ func main() {
 		imageServerAddress = fmt.Sprintf("%s:%d", *imageServerHostname,
 			*imageServerPortNum)
 	}
+	if *objectScrubSpeedPercent > 0 {
+		options := filesystem.ScrubberOptions{
+			Interval: *objectScrubInterval,
+			ReaderContext: fsrateio.NewReaderContext(
+				uint64(objectScrubMaxSpeed), 0,
+				uint64(*objectScrubSpeedPercent)),
+		}
+		if imageServerAddress != "" {
+			options.RepairSource = objectclient.NewObjectClient(
+				imageServerAddress)
+		}
+		if err := objSrv.StartScrubber(options); err != nil {
+			logger.Fatalf("Cannot start object scrubber: %s\n", err)
+		}
+	}
 	imdb, err := scanner.LoadImageDataBase(*imageDir, objSrv,
 		imageServerAddress, logger)
 	if err != nil {
@@ -76,8 +110,20 @@ func main() {
 	tricorder.RegisterMetric("/image-count",
 		func() uint { return imdb.CountImages() },
 		units.None, "number of images")
-	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(imdb, imageServerAddress,
-		objSrv, logger)
+	auditLog, err := auditlog.Open(filepath.Join(*imageDir, ".audit-log"),
+		logger)
+	if err != nil {
+		logger.Fatalf("Cannot open audit log: %s\n", err)
+	}
+	var vulnDb *vulndb.Manager
+	if *vulnerabilityFeedDirectory != "" {
+		vulnDb, err = vulndb.New(*vulnerabilityFeedDirectory, imdb, logger)
+		if err != nil {
+			logger.Fatalf("Cannot load vulnerability feed: %s\n", err)
+		}
+	}
+	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(imdb, auditLog, vulnDb,
+		imageServerAddress, objSrv, logger)
 	if err != nil {
 		logger.Fatalln(err)
 	}
@@ -85,10 +131,14 @@ func main() {
 		logger)
 	httpd.AddHtmlWriter(imdb)
 	httpd.AddHtmlWriter(&imageObjectServersType{imdb, objSrv})
+	if vulnDb != nil {
+		httpd.AddHtmlWriter(vulnDb)
+	}
 	httpd.AddHtmlWriter(imgSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(objSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(logger)
-	if err = httpd.StartServer(*portNum, imdb, objSrv, false); err != nil {
+	err = httpd.StartServer(*portNum, imdb, objSrv, vulnDb, false)
+	if err != nil {
 		logger.Fatalf("Unable to create http server: %s\n", err)
 	}
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Symantec/Dominator/imageserver/auditlog"
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func auditLogSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	var startSequence uint64 = 1
+	if len(args) > 0 {
+		var err error
+		startSequence, err = strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing sequence number: %s\n", err)
+			os.Exit(2)
+		}
+	}
+	if err := showAuditLog(imageSClient, startSequence); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting audit log: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showAuditLog(imageSClient *srpc.Client, startSequence uint64) error {
+	var previous *imageserver.AuditLogEntry
+	for {
+		entries, err := client.GetAuditLog(imageSClient, startSequence, 0)
+		if err != nil {
+			return err
+		}
+		if len(entries) < 1 {
+			return nil
+		}
+		toVerify := entries
+		if previous != nil {
+			toVerify = append([]imageserver.AuditLogEntry{*previous},
+				entries...)
+		}
+		if err := auditlog.VerifyEntries(toVerify); err != nil {
+			return fmt.Errorf("audit log verification failed: %s", err)
+		}
+		for _, entry := range entries {
+			printAuditLogEntry(entry)
+		}
+		previous = &entries[len(entries)-1]
+		startSequence = previous.Sequence + 1
+	}
+}
+
+func printAuditLogEntry(entry imageserver.AuditLogEntry) {
+	username := entry.Username
+	if username == "" {
+		username = "-"
+	}
+	keys := make([]string, 0, len(entry.Arguments))
+	for key := range entry.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	arguments := make([]string, 0, len(keys))
+	for _, key := range keys {
+		arguments = append(arguments,
+			fmt.Sprintf("%s=%q", key, entry.Arguments[key]))
+	}
+	timeToShow := entry.Time
+	if entry.ReplicatedFrom != "" {
+		timeToShow = entry.OriginalTime
+	}
+	fmt.Printf("%d %s %s %s(%s)", entry.Sequence,
+		timeToShow.Format("2006-01-02T15:04:05.000Z07:00"), username,
+		entry.Method, strings.Join(arguments, ", "))
+	if entry.Error != "" {
+		fmt.Printf(" error: %s", entry.Error)
+	}
+	if entry.ReplicatedFrom != "" {
+		fmt.Printf(" [replicated from %s:%d]", entry.ReplicatedFrom,
+			entry.OriginalSequence)
+	}
+	fmt.Println()
+}
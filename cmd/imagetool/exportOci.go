@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/filesystem/util"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/oci"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func exportOciSubcommand(args []string) {
+	imageSClient, objectClient := getClients()
+	tag := ""
+	if len(args) > 2 {
+		tag = args[2]
+	}
+	err := exportOci(imageSClient, objectClient, args[0], args[1], tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting image: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func exportOci(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient,
+	name, outputFilename, tag string) error {
+	file, err := os.Create(outputFilename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if *computedFilesRoot == "" {
+		// No local data are needed, so have the imageserver do the work.
+		err = client.ExportOciImage(imageSClient,
+			imageserver.ExportOciImageRequest{
+				ImageName:    name,
+				MaxLayerSize: uint64(maxOciLayerSize),
+				Tag:          tag,
+			},
+			writer)
+	} else {
+		err = exportOciLocal(imageSClient, objectClient, name, writer, tag)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputFilename)
+	}
+	return err
+}
+
+func exportOciLocal(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, name string, writer io.Writer,
+	tag string) error {
+	img, err := getImage(imageSClient, name)
+	if err != nil {
+		return err
+	}
+	objectsGetter, err := util.ReplaceComputedFiles(img.FileSystem,
+		&util.ComputedFilesData{RootDirectory: *computedFilesRoot},
+		objectClient)
+	if err != nil {
+		return err
+	}
+	return oci.Encode(writer, img, objectsGetter, oci.EncodeOptions{
+		MaxLayerSize: uint64(maxOciLayerSize),
+		Tag:          tag,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/image"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/oci"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func importOciSubcommand(args []string) {
+	imageSClient, objectClient := getClients()
+	err := importOci(imageSClient, objectClient, args[0], args[1], args[2],
+		args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing image: \"%s\": %s\n", args[0],
+			err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func importOci(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient,
+	name, source, filterFilename, triggersFilename string) error {
+	imageExists, err := client.CheckImage(imageSClient, name)
+	if err != nil {
+		return errors.New("error checking for image existence: " + err.Error())
+	}
+	if imageExists {
+		return errors.New("image exists")
+	}
+	newImage := new(image.Image)
+	if err := loadImageFiles(newImage, objectClient, filterFilename,
+		triggersFilename); err != nil {
+		return err
+	}
+	var h hasher
+	h.objQ, err = objectclient.NewObjectAdderQueue(imageSClient)
+	if err != nil {
+		return err
+	}
+	ociImage, err := oci.Decode(source, &h, newImage.Filter, logger)
+	if err != nil {
+		h.objQ.Close()
+		return errors.New("error decoding OCI image: " + err.Error())
+	}
+	if err := h.objQ.Close(); err != nil {
+		return err
+	}
+	newImage.FileSystem = ociImage.FileSystem
+	newImage.Packages = ociImage.Packages
+	if err := spliceComputedFiles(newImage.FileSystem); err != nil {
+		return err
+	}
+	if err := copyMtimes(imageSClient, newImage, *copyMtimesFrom); err != nil {
+		return err
+	}
+	return addImage(imageSClient, name, newImage)
+}
@@ -48,7 +48,8 @@ var (
 		"Port number of image server")
 	makeBootable = flag.Bool("makeBootable", true,
 		"If true, make raw image bootable by installing GRUB")
-	minFreeBytes = flag.Uint64("minFreeBytes", 4<<20,
+	maxOciLayerSize flagutil.Size
+	minFreeBytes    = flag.Uint64("minFreeBytes", 4<<20,
 		"minimum number of free bytes in raw image")
 	releaseNotes = flag.String("releaseNotes", "",
 		"Filename or URL containing release notes")
@@ -66,6 +67,8 @@ var (
 )
 
 func init() {
+	flag.Var(&maxOciLayerSize, "maxOciLayerSize",
+		"If non-zero, split export-oci output into layers of about this size")
 	flag.Var(&requiredPaths, "requiredPaths",
 		"Comma separated list of required path:type entries")
 	flag.Var(&tableType, "tableType", "partition table type for make-raw-image")
@@ -81,6 +84,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  addi   name imagename filterfile triggerfile")
 	fmt.Fprintln(os.Stderr, "  addrep name baseimage layerimage...")
 	fmt.Fprintln(os.Stderr, "  adds   name subname filterfile triggerfile")
+	fmt.Fprintln(os.Stderr, "  audit  [startSequence]")
 	fmt.Fprintln(os.Stderr, "  bulk-addrep layerimage...")
 	fmt.Fprintln(os.Stderr, "  change-image-expiration name")
 	fmt.Fprintln(os.Stderr, "  check  name")
@@ -97,12 +101,17 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "           i: name of an image on the imageserver")
 	fmt.Fprintln(os.Stderr, "           l: name of file containing an Image")
 	fmt.Fprintln(os.Stderr, "           s: name of sub to poll")
+	fmt.Fprintln(os.Stderr, "  diff-sbom           left right")
 	fmt.Fprintln(os.Stderr, "  estimate-usage      name")
+	fmt.Fprintln(os.Stderr, "  export-oci          name outfile [tag]")
 	fmt.Fprintln(os.Stderr, "  find-latest-image   directory")
 	fmt.Fprintln(os.Stderr, "  get                 name directory")
 	fmt.Fprintln(os.Stderr, "  get-archive-data    name outfile")
 	fmt.Fprintln(os.Stderr, "  get-file-in-image   name imageFile [outfile]")
 	fmt.Fprintln(os.Stderr, "  get-image-expiration name")
+	fmt.Fprintln(os.Stderr, "  get-sbom            name [native|spdx|cyclonedx]")
+	fmt.Fprintln(os.Stderr, "  import-oci name source filterfile triggerfile")
+	fmt.Fprintln(os.Stderr, "         source: OCI layout or docker save tarball")
 	fmt.Fprintln(os.Stderr, "  list")
 	fmt.Fprintln(os.Stderr, "  listdirs")
 	fmt.Fprintln(os.Stderr, "  listunrefobj")
@@ -115,6 +124,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  showunrefobj")
 	fmt.Fprintln(os.Stderr, "  tar                 name [file]")
 	fmt.Fprintln(os.Stderr, "  test-download-speed name")
+	fmt.Fprintln(os.Stderr, "  vulns               name...")
 	fmt.Fprintln(os.Stderr, "Fields:")
 	fmt.Fprintln(os.Stderr, "  m: mode")
 	fmt.Fprintln(os.Stderr, "  l: number of hardlinks")
@@ -140,6 +150,7 @@ var subcommands = []subcommand{
 	{"addi", 4, 4, addImageimageSubcommand},
 	{"addrep", 3, -1, addReplaceImageSubcommand},
 	{"adds", 4, 4, addImagesubSubcommand},
+	{"audit", 0, 1, auditLogSubcommand},
 	{"bulk-addrep", 1, -1, bulkAddReplaceImagesSubcommand},
 	{"change-image-expiration", 1, 1, changeImageExpirationSubcommand},
 	{"check", 1, 1, checkImageSubcommand},
@@ -149,12 +160,16 @@ var subcommands = []subcommand{
 	{"delete", 1, 1, deleteImageSubcommand},
 	{"delunrefobj", 2, 2, deleteUnreferencedObjectsSubcommand},
 	{"diff", 3, 3, diffSubcommand},
+	{"diff-sbom", 2, 2, diffSbomSubcommand},
 	{"estimate-usage", 1, 1, estimateImageUsageSubcommand},
+	{"export-oci", 2, 3, exportOciSubcommand},
 	{"find-latest-image", 1, 1, findLatestImageSubcommand},
 	{"get", 2, 2, getImageSubcommand},
 	{"get-archive-data", 2, 2, getImageArchiveDataSubcommand},
 	{"get-file-in-image", 2, 3, getFileInImageSubcommand},
 	{"get-image-expiration", 1, 1, getImageExpirationSubcommand},
+	{"get-sbom", 1, 2, getSbomSubcommand},
+	{"import-oci", 4, 4, importOciSubcommand},
 	{"list", 0, 0, listImagesSubcommand},
 	{"listdirs", 0, 0, listDirectoriesSubcommand},
 	{"listunrefobj", 0, 0, listUnreferencedObjectsSubcommand},
@@ -167,6 +182,7 @@ var subcommands = []subcommand{
 	{"showunrefobj", 0, 0, showUnreferencedObjectsSubcommand},
 	{"tar", 1, 2, tarImageSubcommand},
 	{"test-download-speed", 1, 1, testDownloadSpeedSubcommand},
+	{"vulns", 1, -1, listVulnerabilitiesSubcommand},
 }
 
 var imageSrpcClient *srpc.Client
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/sbom"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func diffSbomSubcommand(args []string) {
+	imageSClient, objectClient := getClients()
+	if err := diffSbom(imageSClient, objectClient, args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error diffing SBOMs: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func getSbomSubcommand(args []string) {
+	imageSClient, objectClient := getClients()
+	format := "native"
+	if len(args) > 1 {
+		format = args[1]
+	}
+	if err := getSbom(imageSClient, objectClient, args[0], format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting SBOM: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func diffSbom(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, left, right string) error {
+	leftDoc, err := readSbom(imageSClient, objectClient, left)
+	if err != nil {
+		return err
+	}
+	rightDoc, err := readSbom(imageSClient, objectClient, right)
+	if err != nil {
+		return err
+	}
+	for _, change := range sbom.Diff(leftDoc, rightDoc) {
+		fmt.Println(change)
+	}
+	return nil
+}
+
+func getSbom(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, name, format string) error {
+	doc, err := readSbom(imageSClient, objectClient, name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(os.Stdout)
+	defer writer.Flush()
+	switch format {
+	case "native":
+		return doc.Encode(writer)
+	case "cyclonedx":
+		return doc.WriteCycloneDx(writer)
+	case "spdx":
+		return doc.WriteSpdx(writer)
+	}
+	return errors.New("unknown format: " + format)
+}
+
+func readSbom(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, name string) (
+	*sbom.Document, error) {
+	img, err := getImage(imageSClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if img.Sbom == nil || img.Sbom.Object == nil {
+		return nil, errors.New(name + ": no SBOM")
+	}
+	_, reader, err := objectClient.GetObject(*img.Sbom.Object)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return sbom.Decode(reader)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	imgclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func listVulnerabilitiesSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := listVulnerabilities(imageSClient, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing vulnerabilities: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func listVulnerabilities(client *srpc.Client, names []string) error {
+	results, err := imgclient.GetImageVulnerabilities(client, names)
+	if err != nil {
+		return err
+	}
+	var numErrors int
+	for _, result := range results {
+		if len(names) > 1 {
+			fmt.Printf("%s:\n", result.ImageName)
+		}
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.ImageName, result.Error)
+			numErrors++
+			continue
+		}
+		for _, vuln := range result.Vulnerabilities {
+			fields := []string{vuln.Id, vuln.PackageName, vuln.PackageVersion}
+			if vuln.FixedVersion != "" {
+				fields = append(fields, "fixed-in="+vuln.FixedVersion)
+			}
+			if vuln.Severity != "" {
+				fields = append(fields, "severity="+vuln.Severity)
+			}
+			fmt.Println(strings.Join(fields, " "))
+		}
+	}
+	if numErrors > 0 {
+		return errors.New("errors matching some images")
+	}
+	return nil
+}
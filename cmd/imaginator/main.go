@@ -3,8 +3,10 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"syscall"
 	"time"
@@ -42,6 +44,8 @@ var (
 		"Name of state directory")
 	variablesFile = flag.String("variablesFile", "",
 		"A JSON encoded file containing special variables (i.e. secrets)")
+	webhookSecretFile = flag.String("webhookSecretFile", "",
+		"File containing shared secret for verifying webhook push events")
 )
 
 func main() {
@@ -70,6 +74,14 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Error starting slave driver: %s\n", err)
 	}
+	var webhookSecret []byte
+	if *webhookSecretFile != "" {
+		webhookSecret, err = ioutil.ReadFile(*webhookSecretFile)
+		if err != nil {
+			logger.Fatalf("Cannot read webhook secret: %s\n", err)
+		}
+		webhookSecret = bytes.TrimSpace(webhookSecret)
+	}
 	builderObj, err := builder.Load(*configurationUrl, *variablesFile,
 		*stateDir,
 		fmt.Sprintf("%s:%d", *imageServerHostname, *imageServerPortNum),
@@ -87,7 +99,8 @@ func main() {
 	}
 	httpd.AddHtmlWriter(rpcHtmlWriter)
 	httpd.AddHtmlWriter(logger)
-	if err = httpd.StartServer(*portNum, builderObj, false); err != nil {
+	if err = httpd.StartServer(*portNum, builderObj, webhookSecret,
+		false); err != nil {
 		logger.Fatalf("Unable to create http server: %s\n", err)
 	}
 }
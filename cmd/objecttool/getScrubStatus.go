@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+)
+
+func getScrubStatusSubcommand(objSrv objectserver.ObjectServer,
+	args []string) {
+	if err := getScrubStatus(objSrv); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting scrub status: %s\n", err)
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func getScrubStatus(objSrv objectserver.ObjectServer) error {
+	objClient, ok := objSrv.(*objectclient.ObjectClient)
+	if !ok {
+		return errors.New("object server does not support scrub status")
+	}
+	status, err := objClient.GetScrubStatus()
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", status)
+}
@@ -35,6 +35,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  add    files...")
 	fmt.Fprintln(os.Stderr, "  check  hash")
 	fmt.Fprintln(os.Stderr, "  get    hash baseOutputFilename")
+	fmt.Fprintln(os.Stderr, "  get-scrub-status")
 	fmt.Fprintln(os.Stderr, "  mget   hashesFile directory")
 	fmt.Fprintln(os.Stderr, "  test-bandwidth-from-server")
 	fmt.Fprintln(os.Stderr, "  test-bandwidth-to-server")
@@ -53,6 +54,7 @@ var subcommands = []subcommand{
 	{"add", 1, -1, addObjectsSubcommand},
 	{"check", 1, 1, checkObjectSubcommand},
 	{"get", 2, 2, getObjectSubcommand},
+	{"get-scrub-status", 0, 0, getScrubStatusSubcommand},
 	{"mget", 2, 2, getObjectsSubcommand},
 	{"test-bandwidth-from-server", 0, 0, testBandwidthFromServerSubcommand},
 	{"test-bandwidth-to-server", 0, 0, testBandwidthToServerSubcommand},
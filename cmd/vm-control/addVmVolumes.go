@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func addVmVolumesSubcommand(args []string, logger log.DebugLogger) error {
+	if err := addVmVolumes(args[0], logger); err != nil {
+		return fmt.Errorf("Error adding VM volumes: %s", err)
+	}
+	return nil
+}
+
+func addVmVolumes(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return addVmVolumesOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func addVmVolumesOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	volumes, err := parseSizes(secondaryVolumeSizes)
+	if err != nil {
+		return err
+	}
+	if len(volumes) < 1 {
+		return errors.New("no secondaryVolumeSizes specified")
+	}
+	for index := range volumes {
+		volumes[index].Format = volumeFormat
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return hyperclient.AddVmVolumes(client, ipAddr, nil, volumes)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmFirewallRulesSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := changeVmFirewallRules(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM firewall rules: %s", err)
+	}
+	return nil
+}
+
+func changeVmFirewallRules(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmFirewallRulesOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmFirewallRulesOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	rules, err := readFirewallRules()
+	if err != nil {
+		return err
+	}
+	request := proto.ChangeVmFirewallRulesRequest{
+		FirewallRules: rules,
+		IpAddress:     ipAddr,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmFirewallRulesResponse
+	err = client.RequestReply("Hypervisor.ChangeVmFirewallRules", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+// readFirewallRules reads the firewall rules from the file specified by the
+// -firewallRulesFile option. If no file is specified, nil is returned.
+func readFirewallRules() (*proto.FirewallRules, error) {
+	if *firewallRulesFile == "" {
+		return nil, nil
+	}
+	var rules proto.FirewallRules
+	if err := json.ReadFromFile(*firewallRulesFile, &rules); err != nil {
+		return nil, err
+	}
+	if err := rules.CheckValid(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmRestartPolicySubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := changeVmRestartPolicy(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM restart policy: %s", err)
+	}
+	return nil
+}
+
+func changeVmRestartPolicy(vmHostname string,
+	logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmRestartPolicyOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmRestartPolicyOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ChangeVmRestartPolicyRequest{
+		IpAddress:     ipAddr,
+		MaxRestarts:   *maxRestarts,
+		RestartPolicy: restartPolicy,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmRestartPolicyResponse
+	err = client.RequestReply("Hypervisor.ChangeVmRestartPolicy",
+		request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmSizeSubcommand(args []string, logger log.DebugLogger) error {
+	if err := changeVmSize(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM size: %s", err)
+	}
+	return nil
+}
+
+func changeVmSize(vmHostname string, logger log.DebugLogger) error {
+	if memory < 1 && *milliCPUs < 1 {
+		return errors.New("no memory or milliCPUs specified")
+	}
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmSizeOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmSizeOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ChangeVmSizeRequest{
+		IpAddress:   ipAddr,
+		MemoryInMiB: uint64(memory >> 20),
+		MilliCPUs:   *milliCPUs,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmSizeResponse
+	err = client.RequestReply("Hypervisor.ChangeVmSize", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
@@ -112,6 +112,7 @@ func copyVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	defer destHypervisor.Close()
 	request := hyper_proto.CopyVmRequest{
 		AccessToken:      accessToken,
+		DiscardSnapshots: *discardSnapshots,
 		IpAddress:        vmIP,
 		SourceHypervisor: sourceHypervisorAddress,
 		VmInfo:           vmInfo,
@@ -100,14 +100,17 @@ func createVm(logger log.DebugLogger) error {
 
 func createVmInfoFromFlags() hyper_proto.VmInfo {
 	return hyper_proto.VmInfo{
+		CloudInitSeed:      *cloudInitSeed,
 		ConsoleType:        consoleType,
 		DestroyProtection:  *destroyProtection,
 		DisableVirtIO:      *disableVirtIO,
 		Hostname:           *vmHostname,
+		MaxRestarts:        *maxRestarts,
 		MemoryInMiB:        uint64(memory >> 20),
 		MilliCPUs:          *milliCPUs,
 		OwnerGroups:        ownerGroups,
 		OwnerUsers:         ownerUsers,
+		RestartPolicy:      restartPolicy,
 		Tags:               vmTags,
 		SecondarySubnetIDs: secondarySubnetIDs,
 		SubnetId:           *subnetId,
@@ -154,6 +157,11 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 	} else {
 		request.SecondaryVolumes = sizes
 	}
+	if rules, err := readFirewallRules(); err != nil {
+		return err
+	} else {
+		request.FirewallRules = rules
+	}
 	var imageReader, userDataReader io.Reader
 	if *imageName != "" {
 		request.ImageName = *imageName
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func getVmStatsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := getVmStats(args[0], logger); err != nil {
+		return fmt.Errorf("Error getting VM stats: %s", err)
+	}
+	return nil
+}
+
+func getVmStats(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return getVmStatsOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func getVmStatsOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := proto.GetVmStatsRequest{ipAddr}
+	var reply proto.GetVmStatsResponse
+	err = client.RequestReply("Hypervisor.GetVmStats", request, &reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", reply.Stats)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func listVmSnapshotsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listVmSnapshots(args[0], logger); err != nil {
+		return fmt.Errorf("Error listing VM snapshots: %s", err)
+	}
+	return nil
+}
+
+func listVmSnapshots(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return listVmSnapshotsOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func listVmSnapshotsOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ListVmSnapshotsRequest{ipAddr}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ListVmSnapshotsResponse
+	err = client.RequestReply("Hypervisor.ListVmSnapshots", request, &reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", reply.Snapshots)
+}
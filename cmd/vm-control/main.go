@@ -21,6 +21,8 @@ import (
 var (
 	adjacentVM = flag.String("adjacentVM", "",
 		"IP address of VM adjacent (same Hypervisor) to VM being created")
+	cloudInitSeed = flag.Bool("cloudInitSeed", false,
+		"If true, point cloud-init in VM to the metadata server via SMBIOS")
 	consoleType       hyper_proto.ConsoleType
 	destroyProtection = flag.Bool("destroyProtection", false,
 		"If true, do not destroy running VM")
@@ -28,6 +30,10 @@ var (
 		"If true, disable virtio drivers, reducing I/O performance")
 	dhcpTimeout = flag.Duration("dhcpTimeout", time.Minute,
 		"Time to wait before timing out on DHCP request from VM")
+	discardSnapshots = flag.Bool("discardSnapshots", false,
+		"If true, discard named snapshots when copying or migrating VM")
+	firewallRulesFile = flag.String("firewallRulesFile", "",
+		"Name of JSON file containing VM firewall rules")
 	fleetManagerHostname = flag.String("fleetManagerHostname", "",
 		"Hostname of Fleet Manager")
 	fleetManagerPortNum = flag.Uint("fleetManagerPortNum",
@@ -54,6 +60,8 @@ var (
 		"Command to destroy local VM when exporting. The VM name is given as the argument")
 	location = flag.String("location", "",
 		"Location to search for hypervisors")
+	maxRestarts = flag.Uint("maxRestarts", 0,
+		"Maximum consecutive restarts for on-failure policy (0: unlimited)")
 	memory       flagutil.Size
 	milliCPUs    = flag.Uint("milliCPUs", 0, "milli CPUs (default 250)")
 	minFreeBytes = flagutil.Size(256 << 20)
@@ -70,9 +78,12 @@ var (
 		"If true, directly boot into the kernel")
 	subnetId = flag.String("subnetId", "",
 		"Subnet ID to launch VM in")
-	requestIPs   flagutil.StringList
-	roundupPower = flag.Uint64("roundupPower", 28,
+	requestIPs    flagutil.StringList
+	restartPolicy hyper_proto.RestartPolicy
+	roundupPower  = flag.Uint64("roundupPower", 28,
 		"power of 2 to round up root volume size")
+	snapshotName = flag.String("snapshotName", "",
+		"Name of snapshot (default unnamed snapshot)")
 	snapshotRootOnly = flag.Bool("snapshotRootOnly", false,
 		"If true, snapshot only the root volume")
 	traceMetadata = flag.Bool("traceMetadata", false,
@@ -85,8 +96,10 @@ var (
 		"Path to VNC viewer")
 	volumeFilename = flag.String("volumeFilename", "",
 		"Name of file to write volume data to")
-	volumeIndex = flag.Uint("volumeIndex", 0,
-		"Index of volume to get or delete")
+	volumeFormat hyper_proto.VolumeFormat
+	volumeIndex  = flag.Uint("volumeIndex", 0,
+		"Index of volume to get, delete or resize")
+	volumeSize flagutil.Size
 
 	logger   log.DebugLogger
 	rrDialer *rrdialer.Dialer
@@ -101,10 +114,15 @@ func init() {
 	flag.Var(&ownerGroups, "ownerGroups", "Groups who own the VM")
 	flag.Var(&ownerUsers, "ownerUsers", "Extra users who own the VM")
 	flag.Var(&requestIPs, "requestIPs", "Request specific IPs, if available")
+	flag.Var(&restartPolicy, "restartPolicy",
+		"restart policy for crashed VMs (default never)")
 	flag.Var(&secondarySubnetIDs, "secondarySubnetIDs", "Secondary Subnet IDs")
 	flag.Var(&secondaryVolumeSizes, "secondaryVolumeSizes",
 		"Sizes for secondary volumes")
 	flag.Var(&vmTags, "vmTags", "Tags to apply to VM")
+	flag.Var(&volumeFormat, "volumeFormat",
+		"format of added volumes (default raw)")
+	flag.Var(&volumeSize, "volumeSize", "new size of volume to resize")
 }
 
 func printUsage() {
@@ -113,10 +131,14 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Common flags:")
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  add-vm-volumes IPaddr")
 	fmt.Fprintln(os.Stderr, "  become-primary-vm-owner IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-console-type IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-destroy-protection IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-firewall-rules IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-owner-users IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-restart-policy IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-size IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-tags IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-console IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-serial-port IPaddr")
@@ -130,6 +152,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  export-local-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  export-virsh-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-info IPaddr")
+	fmt.Fprintln(os.Stderr, "  get-vm-stats IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-user-data IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-volume IPaddr")
 	fmt.Fprintln(os.Stderr, "  import-local-vm info-file root-volume")
@@ -137,11 +160,13 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  list-hypervisors")
 	fmt.Fprintln(os.Stderr, "  list-locations [TopLocation]")
 	fmt.Fprintln(os.Stderr, "  list-vms")
+	fmt.Fprintln(os.Stderr, "  list-vm-snapshots IPaddr")
 	fmt.Fprintln(os.Stderr, "  migrate-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  patch-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  probe-vm-port IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-user-data IPaddr")
+	fmt.Fprintln(os.Stderr, "  resize-vm-volume IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-from-snapshot IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-user-data IPaddr")
@@ -163,10 +188,14 @@ type subcommand struct {
 }
 
 var subcommands = []subcommand{
+	{"add-vm-volumes", 1, 1, addVmVolumesSubcommand},
 	{"become-primary-vm-owner", 1, 1, becomePrimaryVmOwnerSubcommand},
 	{"change-vm-console-type", 1, 1, changeVmConsoleTypeSubcommand},
 	{"change-vm-destroy-protection", 1, 1, changeVmDestroyProtectionSubcommand},
+	{"change-vm-firewall-rules", 1, 1, changeVmFirewallRulesSubcommand},
 	{"change-vm-owner-users", 1, 1, changeVmOwnerUsersSubcommand},
+	{"change-vm-restart-policy", 1, 1, changeVmRestartPolicySubcommand},
+	{"change-vm-size", 1, 1, changeVmSizeSubcommand},
 	{"change-vm-tags", 1, 1, changeVmTagsSubcommand},
 	{"connect-to-vm-console", 1, 1, connectToVmConsoleSubcommand},
 	{"connect-to-vm-serial-port", 1, 1, connectToVmSerialPortSubcommand},
@@ -180,6 +209,7 @@ var subcommands = []subcommand{
 	{"export-local-vm", 1, 1, exportLocalVmSubcommand},
 	{"export-virsh-vm", 1, 1, exportVirshVmSubcommand},
 	{"get-vm-info", 1, 1, getVmInfoSubcommand},
+	{"get-vm-stats", 1, 1, getVmStatsSubcommand},
 	{"get-vm-user-data", 1, 1, getVmUserDataSubcommand},
 	{"get-vm-volume", 1, 1, getVmVolumeSubcommand},
 	{"import-local-vm", 2, 2, importLocalVmSubcommand},
@@ -187,11 +217,13 @@ var subcommands = []subcommand{
 	{"list-hypervisors", 0, 0, listHypervisorsSubcommand},
 	{"list-locations", 0, 1, listLocationsSubcommand},
 	{"list-vms", 0, 0, listVMsSubcommand},
+	{"list-vm-snapshots", 1, 1, listVmSnapshotsSubcommand},
 	{"migrate-vm", 1, 1, migrateVmSubcommand},
 	{"patch-vm-image", 1, 1, patchVmImageSubcommand},
 	{"probe-vm-port", 1, 1, probeVmPortSubcommand},
 	{"replace-vm-image", 1, 1, replaceVmImageSubcommand},
 	{"replace-vm-user-data", 1, 1, replaceVmUserDataSubcommand},
+	{"resize-vm-volume", 1, 1, resizeVmVolumeSubcommand},
 	{"restore-vm-from-snapshot", 1, 1, restoreVmFromSnapshotSubcommand},
 	{"restore-vm-image", 1, 1, restoreVmImageSubcommand},
 	{"restore-vm-user-data", 1, 1, restoreVmUserDataSubcommand},
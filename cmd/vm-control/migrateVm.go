@@ -76,6 +76,7 @@ func migrateVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	defer conn.Close()
 	request := hyper_proto.MigrateVmRequest{
 		AccessToken:      accessToken,
+		DiscardSnapshots: *discardSnapshots,
 		IpAddress:        vmIP,
 		SourceHypervisor: sourceHypervisorAddress,
 	}
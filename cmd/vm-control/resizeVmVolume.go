@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func resizeVmVolumeSubcommand(args []string, logger log.DebugLogger) error {
+	if err := resizeVmVolume(args[0], logger); err != nil {
+		return fmt.Errorf("Error resizing VM volume: %s", err)
+	}
+	return nil
+}
+
+func resizeVmVolume(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return resizeVmVolumeOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func resizeVmVolumeOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	if volumeSize < 1 {
+		return errors.New("no volumeSize specified")
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return hyperclient.ResizeVmVolume(client, ipAddr, nil, *volumeIndex,
+		uint64(volumeSize))
+}
@@ -27,7 +27,7 @@ func snapshotVm(vmHostname string, logger log.DebugLogger) error {
 func snapshotVmOnHypervisor(hypervisor string, ipAddr net.IP,
 	logger log.DebugLogger) error {
 	request := proto.SnapshotVmRequest{ipAddr, *forceIfNotStopped,
-		*snapshotRootOnly}
+		*snapshotRootOnly, *snapshotName}
 	client, err := dialHypervisor(hypervisor)
 	if err != nil {
 		return err
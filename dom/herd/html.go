@@ -64,6 +64,8 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer,
 		"Number of compliant subs: <a href=\"showCompliantSubs\">%d</a><br>\n",
 		numSubs)
+	fmt.Fprintln(writer,
+		"Vulnerabilities: <a href=\"showVulnerableSubs\">by image</a><br>")
 	subs := herd.getSelectedSubs(nil)
 	connectDurations := getConnectDurations(subs)
 	shortPollDurations := getPollDurations(subs, false)
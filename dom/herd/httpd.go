@@ -27,6 +27,8 @@ func (herd *Herd) startServer(portNum uint, daemon bool) error {
 	html.HandleFunc("/showReachableSubs",
 		html.BenchmarkedHandler(herd.showReachableSubsHandler))
 	html.HandleFunc("/showSub", html.BenchmarkedHandler(herd.showSubHandler))
+	html.HandleFunc("/showVulnerableSubs",
+		html.BenchmarkedHandler(herd.showVulnerableSubsHandler))
 	if daemon {
 		go http.Serve(listener, nil)
 	} else {
@@ -0,0 +1,102 @@
+package herd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	imageclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+// showVulnerableSubsHandler will show the vulnerabilities for each sub, based
+// on the image the sub was last successfully updated to.
+func (herd *Herd) showVulnerableSubsHandler(w io.Writer, req *http.Request) {
+	fmt.Fprintln(w, "<title>Dominator vulnerable subs</title>")
+	fmt.Fprintln(w, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(w, "<body>")
+	subsByImage := make(map[string][]*Sub)
+	for _, sub := range herd.getSelectedSubs(nil) {
+		if name := sub.lastSuccessfulImageName; name != "" {
+			subsByImage[name] = append(subsByImage[name], sub)
+		}
+	}
+	imageNames := make([]string, 0, len(subsByImage))
+	for name := range subsByImage {
+		imageNames = append(imageNames, name)
+	}
+	sort.Strings(imageNames)
+	results, err := herd.getImageVulnerabilities(imageNames)
+	if err != nil {
+		fmt.Fprintf(w, "Error getting vulnerabilities: %s\n", err)
+		return
+	}
+	sort.SliceStable(results, func(left, right int) bool {
+		return len(results[left].Vulnerabilities) >
+			len(results[right].Vulnerabilities)
+	})
+	fmt.Fprintln(w, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(w, "  <tr>")
+	fmt.Fprintln(w, "    <th>Current Image</th>")
+	fmt.Fprintln(w, "    <th>Vulnerabilities</th>")
+	fmt.Fprintln(w, "    <th>Vulnerable Packages</th>")
+	fmt.Fprintln(w, "    <th>Subs</th>")
+	fmt.Fprintln(w, "  </tr>")
+	for _, result := range results {
+		fmt.Fprintln(w, "  <tr>")
+		fmt.Fprintf(w,
+			"    <td><a href=\"http://%s/listPackages?%s\">%s</a></td>\n",
+			herd.imageManager, result.ImageName, result.ImageName)
+		if result.Error != "" {
+			fmt.Fprintf(w, "    <td><font color=\"red\">%s</font></td>\n",
+				result.Error)
+			fmt.Fprintln(w, "    <td></td>")
+		} else {
+			fmt.Fprintf(w, "    <td>%d</td>\n", len(result.Vulnerabilities))
+			fmt.Fprintf(w, "    <td>%s</td>\n",
+				strings.Join(listVulnerablePackages(result), " "))
+		}
+		subs := subsByImage[result.ImageName]
+		subNames := make([]string, 0, len(subs))
+		for _, sub := range subs {
+			subNames = append(subNames, fmt.Sprintf(
+				"<a href=\"showSub?%s\">%s</a>", sub.mdb.Hostname, sub))
+		}
+		fmt.Fprintf(w, "    <td>%s</td>\n", strings.Join(subNames, " "))
+		fmt.Fprintln(w, "  </tr>")
+	}
+	fmt.Fprintln(w, "</table>")
+	fmt.Fprintln(w, "</body>")
+}
+
+func (herd *Herd) getImageVulnerabilities(names []string) (
+	[]imageserver.ImageVulnerabilities, error) {
+	if len(names) < 1 {
+		return nil, nil
+	}
+	client, err := srpc.DialHTTP("tcp", herd.imageManager.String(),
+		time.Second*15)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return imageclient.GetImageVulnerabilities(client, names)
+}
+
+func listVulnerablePackages(result imageserver.ImageVulnerabilities) []string {
+	var packages []string
+	for _, vuln := range result.Vulnerabilities {
+		if len(packages) < 1 || packages[len(packages)-1] != vuln.PackageName {
+			packages = append(packages, vuln.PackageName)
+		}
+	}
+	return packages
+}
@@ -1,6 +1,7 @@
 package images
 
 import (
+	"errors"
 	"time"
 
 	"github.com/Symantec/Dominator/imageserver/client"
@@ -166,6 +167,10 @@ func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
 	if img == nil || m.scheduleExpiration(img, name) {
 		return imageClient, nil, nil
 	}
+	if img.TestsFailed() {
+		// Not logged since missing images are requested every second.
+		return imageClient, nil, errors.New("image tests failed")
+	}
 	if err := img.FileSystem.RebuildInodePointers(); err != nil {
 		m.logger.Printf("Error building inode pointers for image: %s %s",
 			name, err)
@@ -32,16 +32,20 @@ type hypervisorType struct {
 	cachedSerialNumber string
 	conn               *srpc.Conn
 	deleteScheduled    bool
+	drainInProgress    bool
 	healthStatus       string
 	lastIpmiProbe      time.Time
 	localTags          tags.Tags
 	location           string
 	machine            *fm_proto.Machine
+	memoryInMiB        uint64
 	migratingVms       map[string]*vmInfoType // Key: VM IP address.
+	numCPUs            uint
 	ownerUsers         map[string]struct{}
 	probeStatus        probeStatus
 	serialNumber       string
 	subnets            []hyper_proto.Subnet
+	unschedulable      bool
 	vms                map[string]*vmInfoType // Key: VM IP address.
 }
 
@@ -92,6 +96,7 @@ type Storer interface {
 	ipStorer
 	serialStorer
 	tagsStorer
+	unschedulableStorer
 	vmStorer
 }
 
@@ -107,6 +112,11 @@ type tagsStorer interface {
 	WriteMachineTags(hypervisor net.IP, tgs tags.Tags) error
 }
 
+type unschedulableStorer interface {
+	ReadMachineUnschedulable(hypervisor net.IP) (bool, error)
+	WriteMachineUnschedulable(hypervisor net.IP, unschedulable bool) error
+}
+
 type vmInfoType struct {
 	ipAddr string
 	hyper_proto.VmInfo
@@ -133,6 +143,14 @@ func (m *Manager) CloseUpdateChannel(channel <-chan fm_proto.Update) {
 	m.closeUpdateChannel(channel)
 }
 
+// DrainHypervisor will mark a hypervisor as unschedulable and migrate its VMs
+// to other hypervisors. Progress messages are sent to progress.
+func (m *Manager) DrainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation, maxConcurrency uint,
+	progress chan<- string) error {
+	return m.drainHypervisor(hostname, authInfo, maxConcurrency, progress)
+}
+
 func (m *Manager) GetHypervisorForVm(ipAddr net.IP) (string, error) {
 	return m.getHypervisorForVm(ipAddr)
 }
@@ -166,6 +184,11 @@ func (m *Manager) MoveIpAddresses(hostname string, ipAddresses []net.IP) error {
 	return m.moveIpAddresses(hostname, ipAddresses)
 }
 
+func (m *Manager) UndrainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation) error {
+	return m.undrainHypervisor(hostname, authInfo)
+}
+
 func (m *Manager) WriteHtml(writer io.Writer) {
 	m.writeHtml(writer)
 }
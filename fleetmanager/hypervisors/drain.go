@@ -0,0 +1,441 @@
+package hypervisors
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type drainStateType struct {
+	hostname      string
+	location      string
+	ownerGroups   map[string]struct{}
+	progress      chan<- string
+	sourceAddress string
+	mutex         sync.Mutex // Protect everything below.
+	numFailed     uint
+	numMigrated   uint
+	reservations  map[string]reservationType // Key: VM IP address.
+}
+
+type drainCandidate struct {
+	freeMemory uint64
+	hypervisor *hypervisorType
+}
+
+type reservationType struct {
+	hostname    string
+	memoryInMiB uint64
+	milliCPUs   uint
+}
+
+func hypervisorAddress(hostname string) string {
+	return fmt.Sprintf("%s:%d", hostname, constants.HypervisorPortNumber)
+}
+
+func (m *Manager) drainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation, maxConcurrency uint,
+	progress chan<- string) error {
+	if !*manageHypervisors {
+		return errors.New("this is a read-only Fleet Manager")
+	}
+	h, err := m.getLockedHypervisor(hostname, true)
+	if err != nil {
+		return err
+	}
+	if err := h.checkAuth(authInfo); err != nil {
+		h.mutex.Unlock()
+		return err
+	}
+	if h.drainInProgress {
+		h.mutex.Unlock()
+		return errors.New("drain already in progress")
+	}
+	if h.probeStatus != probeStatusConnected {
+		h.mutex.Unlock()
+		return fmt.Errorf("hypervisor is not connected: %s", h.probeStatus)
+	}
+	if !h.unschedulable {
+		err := m.storer.WriteMachineUnschedulable(h.machine.HostIpAddress,
+			true)
+		if err != nil {
+			h.mutex.Unlock()
+			return err
+		}
+	}
+	h.drainInProgress = true
+	h.unschedulable = true
+	state := &drainStateType{
+		hostname:      hostname,
+		location:      h.location,
+		ownerGroups:   stringSliceToSet(h.machine.OwnerGroups),
+		progress:      progress,
+		sourceAddress: hypervisorAddress(hostname),
+		reservations:  make(map[string]reservationType),
+	}
+	vms := make([]hyper_proto.VmInfo, 0, len(h.vms))
+	for _, vm := range h.vms {
+		vms = append(vms, vm.VmInfo)
+	}
+	h.mutex.Unlock()
+	m.logger.Printf("draining hypervisor: %s with %d VMs\n", hostname, len(vms))
+	defer func() {
+		h.mutex.Lock()
+		h.drainInProgress = false
+		h.mutex.Unlock()
+	}()
+	sort.Slice(vms, func(left, right int) bool {
+		return vms[left].Address.IpAddress.String() <
+			vms[right].Address.IpAddress.String()
+	})
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	semaphore := make(chan struct{}, maxConcurrency)
+	var numSkipped uint
+	var waitGroup sync.WaitGroup
+	for _, vm := range vms {
+		ipAddr := vm.Address.IpAddress
+		if vm.DestroyProtection {
+			state.sendProgress(fmt.Sprintf(
+				"%s: skipping VM with destroy protection", ipAddr))
+			numSkipped++
+			continue
+		}
+		if vm.State != hyper_proto.StateRunning &&
+			vm.State != hyper_proto.StateStopped {
+			state.sendProgress(fmt.Sprintf("%s: skipping VM in state: %s",
+				ipAddr, vm.State))
+			numSkipped++
+			continue
+		}
+		semaphore <- struct{}{}
+		if !h.isUnschedulable() {
+			<-semaphore
+			state.sendProgress("drain cancelled, waiting for migrations")
+			break
+		}
+		waitGroup.Add(1)
+		go func(vm hyper_proto.VmInfo) {
+			defer func() {
+				<-semaphore
+				waitGroup.Done()
+			}()
+			err := m.evacuateVm(state, vm)
+			if err != nil {
+				state.sendProgress(fmt.Sprintf("%s: %s",
+					vm.Address.IpAddress, err))
+			}
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			if err != nil {
+				state.numFailed++
+			} else {
+				state.numMigrated++
+			}
+		}(vm)
+	}
+	waitGroup.Wait()
+	message := fmt.Sprintf("migrated %d VMs, %d failed, %d skipped",
+		state.numMigrated, state.numFailed, numSkipped)
+	m.logger.Printf("drained hypervisor: %s: %s\n", hostname, message)
+	if state.numFailed > 0 || numSkipped > 0 {
+		return errors.New(message)
+	}
+	state.sendProgress(message)
+	return nil
+}
+
+// evacuateVm will migrate a VM to the first of the candidate hypervisors
+// which accepts it.
+func (m *Manager) evacuateVm(state *drainStateType,
+	vm hyper_proto.VmInfo) error {
+	candidates, err := m.getDrainCandidates(state, vm)
+	if err != nil {
+		return err
+	}
+	if len(candidates) < 1 {
+		return errors.New("no suitable hypervisor available")
+	}
+	ipAddr := vm.Address.IpAddress
+	for _, h := range candidates {
+		hostname := h.machine.Hostname
+		if !state.reserve(h, vm) {
+			continue
+		}
+		state.sendProgress(fmt.Sprintf("%s: migrating to %s", ipAddr,
+			hostname))
+		err := state.migrateVm(hypervisorAddress(hostname), ipAddr)
+		if err == nil {
+			// The reservation is kept until the destination reports the VM.
+			state.sendProgress(fmt.Sprintf("%s: migrated to %s", ipAddr,
+				hostname))
+			return nil
+		}
+		state.release(ipAddr)
+		state.sendProgress(fmt.Sprintf("%s: error migrating to %s: %s",
+			ipAddr, hostname, err))
+	}
+	return errors.New("all candidate hypervisors failed")
+}
+
+// getDrainCandidates returns the healthy, schedulable hypervisors in the same
+// location which have the subnets needed by the VM, which are permitted to
+// host it and which have the memory and CPU for it, ordered by decreasing
+// free memory.
+func (m *Manager) getDrainCandidates(state *drainStateType,
+	vm hyper_proto.VmInfo) ([]*hypervisorType, error) {
+	hypervisors, err := m.listHypervisors(state.location, showOK, vm.SubnetId)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]drainCandidate, 0, len(hypervisors))
+	for _, h := range hypervisors {
+		hostname := h.machine.Hostname
+		if hostname == state.hostname {
+			continue
+		}
+		if !m.hasSubnets(hostname, vm.SecondarySubnetIDs) {
+			continue
+		}
+		h.mutex.RLock()
+		if h.unschedulable || !h.canOwnVm(vm, state.ownerGroups) {
+			h.mutex.RUnlock()
+			continue
+		}
+		state.mutex.Lock()
+		freeMemory, freeMilliCPUs := state.getFreeCapacity(h)
+		state.mutex.Unlock()
+		h.mutex.RUnlock()
+		if vm.MemoryInMiB > freeMemory || vm.MilliCPUs > freeMilliCPUs {
+			continue
+		}
+		candidates = append(candidates, drainCandidate{freeMemory, h})
+	}
+	sort.SliceStable(candidates, func(left, right int) bool {
+		return candidates[left].freeMemory > candidates[right].freeMemory
+	})
+	hypervisors = make([]*hypervisorType, 0, len(candidates))
+	for _, candidate := range candidates {
+		hypervisors = append(hypervisors, candidate.hypervisor)
+	}
+	return hypervisors, nil
+}
+
+func (m *Manager) hasSubnets(hostname string, subnetIDs []string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, subnetId := range subnetIDs {
+		if ok, _ := m.topology.CheckIfMachineHasSubnet(hostname,
+			subnetId); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) undrainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation) error {
+	if !*manageHypervisors {
+		return errors.New("this is a read-only Fleet Manager")
+	}
+	h, err := m.getLockedHypervisor(hostname, true)
+	if err != nil {
+		return err
+	}
+	defer h.mutex.Unlock()
+	if err := h.checkAuth(authInfo); err != nil {
+		return err
+	}
+	if !h.unschedulable {
+		return errors.New("hypervisor is not drained")
+	}
+	err = m.storer.WriteMachineUnschedulable(h.machine.HostIpAddress, false)
+	if err != nil {
+		return err
+	}
+	h.unschedulable = false
+	m.logger.Printf("undrained hypervisor: %s\n", hostname)
+	return nil
+}
+
+// canOwnVm returns true if the hypervisor has no owners, or if it is owned
+// by one of the VM owners or by one of the source hypervisor owner groups.
+// The hypervisor lock must be held.
+func (h *hypervisorType) canOwnVm(vm hyper_proto.VmInfo,
+	sourceOwnerGroups map[string]struct{}) bool {
+	if len(h.ownerUsers) < 1 && len(h.machine.OwnerGroups) < 1 {
+		return true
+	}
+	for _, user := range vm.OwnerUsers {
+		if _, ok := h.ownerUsers[user]; ok {
+			return true
+		}
+	}
+	for _, group := range h.machine.OwnerGroups {
+		if _, ok := sourceOwnerGroups[group]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *hypervisorType) isUnschedulable() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.unschedulable
+}
+
+func (state *drainStateType) migrateVm(destinationAddress string,
+	ipAddr net.IP) error {
+	source, err := srpc.DialHTTP("tcp", state.sourceAddress, time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	accessToken, err := getVmAccessToken(source, ipAddr)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		request := hyper_proto.DiscardVmAccessTokenRequest{
+			AccessToken: accessToken,
+			IpAddress:   ipAddr,
+		}
+		var reply hyper_proto.DiscardVmAccessTokenResponse
+		source.RequestReply("Hypervisor.DiscardVmAccessToken", request,
+			&reply)
+	}()
+	destination, err := srpc.DialHTTP("tcp", destinationAddress,
+		time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+	conn, err := destination.Call("Hypervisor.MigrateVm")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := hyper_proto.MigrateVmRequest{
+		AccessToken:      accessToken,
+		IpAddress:        ipAddr,
+		SourceHypervisor: state.sourceAddress,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply hyper_proto.MigrateVmResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if err := errors.New(reply.Error); err != nil {
+			return err
+		}
+		if reply.ProgressMessage != "" {
+			state.sendProgress(fmt.Sprintf("%s: %s", ipAddr,
+				reply.ProgressMessage))
+		}
+		if reply.RequestCommit {
+			response := hyper_proto.MigrateVmResponseResponse{Commit: true}
+			if err := conn.Encode(response); err != nil {
+				return err
+			}
+			if err := conn.Flush(); err != nil {
+				return err
+			}
+		}
+		if reply.Final {
+			return nil
+		}
+	}
+}
+
+// getFreeCapacity returns the memory (in MiB) and CPU (in milli-CPUs) of the
+// hypervisor which are neither allocated to VMs nor reserved for VMs being
+// migrated to it. Reservations for VMs which the hypervisor now reports are
+// dropped. A hypervisor which has not reported its capacity has none free.
+// The hypervisor lock and the drain state lock must be held.
+func (state *drainStateType) getFreeCapacity(h *hypervisorType) (
+	uint64, uint) {
+	usedMemory := uint64(0)
+	usedMilliCPUs := uint(0)
+	for _, vm := range h.vms {
+		usedMemory += vm.MemoryInMiB
+		usedMilliCPUs += vm.MilliCPUs
+	}
+	for ipAddr, reservation := range state.reservations {
+		if reservation.hostname != h.machine.Hostname {
+			continue
+		}
+		if _, ok := h.vms[ipAddr]; ok {
+			delete(state.reservations, ipAddr)
+			continue
+		}
+		usedMemory += reservation.memoryInMiB
+		usedMilliCPUs += reservation.milliCPUs
+	}
+	var freeMemory uint64
+	var freeMilliCPUs uint
+	if usedMemory < h.memoryInMiB {
+		freeMemory = h.memoryInMiB - usedMemory
+	}
+	if usedMilliCPUs < h.numCPUs*1000 {
+		freeMilliCPUs = h.numCPUs*1000 - usedMilliCPUs
+	}
+	return freeMemory, freeMilliCPUs
+}
+
+func (state *drainStateType) release(ipAddr net.IP) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	delete(state.reservations, ipAddr.String())
+}
+
+// reserve will reserve memory and CPU on the hypervisor for the VM, returning
+// false if there is insufficient free capacity.
+func (state *drainStateType) reserve(h *hypervisorType,
+	vm hyper_proto.VmInfo) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	freeMemory, freeMilliCPUs := state.getFreeCapacity(h)
+	if vm.MemoryInMiB > freeMemory || vm.MilliCPUs > freeMilliCPUs {
+		return false
+	}
+	state.reservations[vm.Address.IpAddress.String()] = reservationType{
+		hostname:    h.machine.Hostname,
+		memoryInMiB: vm.MemoryInMiB,
+		milliCPUs:   vm.MilliCPUs,
+	}
+	return true
+}
+
+func (state *drainStateType) sendProgress(message string) {
+	state.progress <- message
+}
+
+func getVmAccessToken(client *srpc.Client, ipAddr net.IP) ([]byte, error) {
+	request := hyper_proto.GetVmAccessTokenRequest{ipAddr, time.Hour}
+	var reply hyper_proto.GetVmAccessTokenResponse
+	err := client.RequestReply("Hypervisor.GetVmAccessToken", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Token, nil
+}
@@ -215,6 +215,30 @@ func (s *Storer) writeMachineTags(hypervisor net.IP, tgs tags.Tags) error {
 	return writer.Flush()
 }
 
+func (s *Storer) writeMachineUnschedulable(hypervisor net.IP,
+	unschedulable bool) error {
+	hypervisorIP, err := netIpToIp(hypervisor)
+	if err != nil {
+		return err
+	}
+	dirname := s.getHypervisorDirectory(hypervisorIP)
+	filename := filepath.Join(dirname, "unschedulable")
+	if !unschedulable {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dirname, dirPerms); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, filePerms)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 func writeIpList(filename string, ipList []IP, flags int) error {
 	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|flags, filePerms)
 	if err != nil {
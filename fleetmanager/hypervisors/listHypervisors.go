@@ -167,6 +167,9 @@ func (m *Manager) listHypervisorsInLocation(
 	}
 	addresses := make([]string, 0, len(hypervisors))
 	for _, hypervisor := range hypervisors {
+		if hypervisor.isUnschedulable() {
+			continue
+		}
 		addresses = append(addresses,
 			fmt.Sprintf("%s:%d",
 				hypervisor.machine.Hostname, constants.HypervisorPortNumber))
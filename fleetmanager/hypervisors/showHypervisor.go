@@ -8,6 +8,7 @@ import (
 	"sort"
 
 	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/url"
 )
@@ -79,9 +80,22 @@ func (m *Manager) showHypervisorHandler(w http.ResponseWriter,
 		fmt.Fprintln(writer, "</table>")
 	}
 	fmt.Fprintf(writer, "Status: %s<br>\n", h.getHealthStatus())
+	if h.drainInProgress {
+		fmt.Fprintln(writer, "Draining: new VMs will not be placed here<br>")
+	} else if h.unschedulable {
+		fmt.Fprintln(writer, "Drained: new VMs will not be placed here<br>")
+	}
 	fmt.Fprintf(writer,
 		"Number of VMs known: <a href=\"http://%s:%d/listVMs\">%d</a>\n",
 		hostname, constants.HypervisorPortNumber, len(h.vms))
+	var memoryInMiB uint64
+	var milliCPUs uint
+	for _, vm := range h.vms {
+		memoryInMiB += vm.MemoryInMiB
+		milliCPUs += vm.MilliCPUs
+	}
+	fmt.Fprintf(writer, "<br>Allocated to VMs: %s RAM, %g CPUs<br>\n",
+		format.FormatBytes(memoryInMiB<<20), float64(milliCPUs)*1e-3)
 	fmt.Fprintln(writer, "</body>")
 }
 
@@ -375,6 +375,13 @@ func (m *Manager) manageHypervisorLoop(h *hypervisorType, hostname string) {
 		h.logger.Printf("error reading tags, not managing hypervisor: %s", err)
 		return
 	}
+	h.unschedulable, err = m.storer.ReadMachineUnschedulable(
+		h.machine.HostIpAddress)
+	if err != nil {
+		h.logger.Printf(
+			"error reading drain state, not managing hypervisor: %s", err)
+		return
+	}
 	for _, vmIpAddr := range vmList {
 		pVmInfo, err := m.storer.ReadVm(h.machine.HostIpAddress, vmIpAddr)
 		if err != nil {
@@ -596,6 +603,12 @@ func (m *Manager) processHypervisorUpdate(h *hypervisorType,
 	h.mutex.Lock()
 	oldHealthStatus := h.healthStatus
 	h.healthStatus = update.HealthStatus
+	if update.MemoryInMiB > 0 {
+		h.memoryInMiB = update.MemoryInMiB
+	}
+	if update.NumCPUs > 0 {
+		h.numCPUs = update.NumCPUs
+	}
 	oldSerialNumber := h.serialNumber
 	if update.HaveSerialNumber && update.SerialNumber != "" {
 		h.serialNumber = update.SerialNumber
@@ -36,12 +36,14 @@ func Setup(hypervisorsManager *hypervisors.Manager, logger log.DebugLogger) (
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"ChangeMachineTags",
+				"DrainHypervisor",
 				"GetHypervisorForVM",
 				"GetMachineInfo",
 				"GetUpdates",
 				"ListHypervisorLocations",
 				"ListHypervisorsInLocation",
 				"ListVMsInLocation",
+				"UndrainHypervisor",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
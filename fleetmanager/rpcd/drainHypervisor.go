@@ -0,0 +1,38 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) DrainHypervisor(conn *srpc.Conn) error {
+	var request proto.DrainHypervisorRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	progressChannel := make(chan string, 16)
+	errorChannel := make(chan error, 1)
+	go func() {
+		errorChannel <- t.hypervisorsManager.DrainHypervisor(request.Hostname,
+			conn.GetAuthInformation(), request.MaxConcurrency, progressChannel)
+		close(progressChannel)
+	}()
+	var sendError error
+	for message := range progressChannel {
+		if sendError != nil {
+			continue // Keep draining if the client went away.
+		}
+		response := proto.DrainHypervisorResponse{ProgressMessage: message}
+		if sendError = conn.Encode(response); sendError == nil {
+			sendError = conn.Flush()
+		}
+	}
+	if sendError != nil {
+		return sendError
+	}
+	return conn.Encode(proto.DrainHypervisorResponse{
+		Error: errors.ErrorToString(<-errorChannel),
+		Final: true,
+	})
+}
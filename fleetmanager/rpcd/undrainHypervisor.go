@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) UndrainHypervisor(conn *srpc.Conn,
+	request proto.UndrainHypervisorRequest,
+	reply *proto.UndrainHypervisorResponse) error {
+	err := t.hypervisorsManager.UndrainHypervisor(request.Hostname,
+		conn.GetAuthInformation())
+	*reply = proto.UndrainHypervisorResponse{errors.ErrorToString(err)}
+	return nil
+}
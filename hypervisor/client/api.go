@@ -12,6 +12,11 @@ func AcknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return acknowledgeVm(client, ipAddress)
 }
 
+func AddVmVolumes(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumes []proto.Volume) error {
+	return addVmVolumes(client, ipAddr, accessToken, volumes)
+}
+
 func CreateVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
 	return createVm(client, request, reply, logger)
@@ -40,6 +45,11 @@ func PrepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	return prepareVmForMigration(client, ipAddr, accessToken, enable)
 }
 
+func ResizeVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumeIndex uint, size uint64) error {
+	return resizeVmVolume(client, ipAddr, accessToken, volumeIndex, size)
+}
+
 func StartVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	return startVm(client, ipAddr, accessToken)
 }
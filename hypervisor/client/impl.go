@@ -16,6 +16,21 @@ func acknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return client.RequestReply("Hypervisor.AcknowledgeVm", request, &reply)
 }
 
+func addVmVolumes(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumes []proto.Volume) error {
+	request := proto.AddVmVolumesRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		Volumes:     volumes,
+	}
+	var reply proto.AddVmVolumesResponse
+	err := client.RequestReply("Hypervisor.AddVmVolumes", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func createVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
 	if conn, err := client.Call("Hypervisor.CreateVm"); err != nil {
@@ -121,6 +136,22 @@ func prepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	return errors.New(reply.Error)
 }
 
+func resizeVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumeIndex uint, size uint64) error {
+	request := proto.ResizeVmVolumeRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		VolumeIndex: volumeIndex,
+		VolumeSize:  size,
+	}
+	var reply proto.ResizeVmVolumeResponse
+	err := client.RequestReply("Hypervisor.ResizeVmVolume", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func startVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	request := proto.StartVmRequest{
 		AccessToken: accessToken,
@@ -12,6 +12,7 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/url"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
 func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
@@ -84,6 +85,9 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 		writeStrings(writer, "Owner users", vm.OwnerGroups)
 		writeStrings(writer, "Owner users", vm.OwnerUsers)
 		writeBool(writer, "Spread volumes", vm.SpreadVolumes)
+		if vm.FirewallDisabled {
+			writeString(writer, "Firewall", "disabled (nft not available)")
+		}
 		writeString(writer, "Latest boot",
 			fmt.Sprintf("<a href=\"showVmBootLog?%s\">log</a>", ipAddr))
 		if ok, _ := s.manager.CheckVmHasHealthAgent(netIpAddr); ok {
@@ -92,6 +96,9 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 					ipAddr))
 		}
 		fmt.Fprintln(writer, "</table>")
+		if stats, err := s.manager.GetVmStats(netIpAddr); err == nil {
+			writeVmStats(writer, stats)
+		}
 		fmt.Fprintln(writer, "Tags:<br>")
 		fmt.Fprintln(writer, `<table border="1">`)
 		fmt.Fprintln(writer, "  <tr>")
@@ -139,3 +146,33 @@ func writeStrings(writer io.Writer, name string, value []string) {
 func writeUint64(writer io.Writer, name string, value uint64) {
 	fmt.Fprintf(writer, "  <tr><td>%s</td><td>%d</td></tr>\n", name, value)
 }
+
+func writeVmStats(writer io.Writer, stats proto.VmStats) {
+	fmt.Fprintln(writer, "Resource usage:<br>")
+	fmt.Fprintln(writer, `<table border="0">`)
+	writeString(writer, "CPU time", format.Duration(stats.CpuTime))
+	writeString(writer, "Resident memory", format.FormatBytes(stats.RssBytes))
+	if stats.BalloonBytes > 0 {
+		writeString(writer, "Balloon size",
+			format.FormatBytes(stats.BalloonBytes))
+	}
+	for _, network := range stats.Networks {
+		writeString(writer, network.Interface+" received",
+			fmt.Sprintf("%s, %d packets",
+				format.FormatBytes(network.ReceivedBytes),
+				network.ReceivedPackets))
+		writeString(writer, network.Interface+" sent",
+			fmt.Sprintf("%s, %d packets",
+				format.FormatBytes(network.SentBytes), network.SentPackets))
+	}
+	for _, volume := range stats.Volumes {
+		writeString(writer, volume.Device+" read",
+			fmt.Sprintf("%s, %d operations",
+				format.FormatBytes(volume.ReadBytes), volume.ReadOperations))
+		writeString(writer, volume.Device+" written",
+			fmt.Sprintf("%s, %d operations",
+				format.FormatBytes(volume.WriteBytes),
+				volume.WriteOperations))
+	}
+	fmt.Fprintln(writer, "</table><br>")
+}
@@ -1,6 +1,9 @@
 package manager
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
 	"io"
 	"net"
 	"sync"
@@ -30,17 +33,23 @@ type DhcpServer interface {
 type Manager struct {
 	StartOptions
 	rootCookie        []byte
+	firewallDisabled  bool
 	memTotalInMiB     uint64
 	numCPU            int
 	serialNumber      string
 	volumeDirectories []string
+	identityCaCert    *x509.Certificate
+	identityCaCertPEM []byte
+	identityCaKey     crypto.Signer
 	mutex             sync.RWMutex // Lock everything below (those can change).
 	addressPool       addressPoolType
+	firewallUpdates   chan<- struct{}
 	healthStatus      string
 	notifiers         map[<-chan proto.Update]chan<- proto.Update
 	objectCache       *cachingreader.ObjectServer
 	ownerGroups       map[string]struct{}
 	ownerUsers        map[string]struct{}
+	shuttingDown      bool
 	subnets           map[string]proto.Subnet // Key: Subnet ID.
 	subnetChannels    []chan<- proto.Subnet
 	vms               map[string]*vmInfoType // Key: IP address.
@@ -55,6 +64,8 @@ type StartOptions struct {
 	StateDir           string
 	Username           string
 	VlanIdToBridge     map[uint]string // Key: VLAN ID, value: bridge interface.
+	VmCaCertFile       string
+	VmCaKeyFile        string
 	VolumeDirectories  []string
 }
 
@@ -67,16 +78,27 @@ type vmInfoType struct {
 	dirname                    string
 	doNotWriteOrSend           bool
 	hasHealthAgent             bool
+	identityCertPEM            []byte
+	identityExpires            time.Time
+	identityFingerprint        string
+	identityKey                *ecdsa.PrivateKey
+	identityKeyPEM             []byte
 	ipAddress                  string
 	logger                     log.DebugLogger
 	manager                    *Manager
 	metadataChannels           map[chan<- string]struct{}
+	monitorLock                sync.Mutex // Protect monitor fields below.
 	monitorSockname            string
+	monitorWaiters             map[string]chan<- monitorResponse
+	nextMonitorId              uint64
 	ownerUsers                 map[string]struct{}
 	serialInput                io.Writer
 	serialOutput               chan<- byte
+	startTime                  time.Time
+	stats                      proto.VmStats
 	stoppedNotifier            chan<- struct{}
 	proto.LocalVmInfo
+	TapDevices []string `json:",omitempty"` // Persisted for the firewall.
 }
 
 func New(startOptions StartOptions) (*Manager, error) {
@@ -88,6 +110,11 @@ func (m *Manager) AcknowledgeVm(ipAddr net.IP,
 	return m.acknowledgeVm(ipAddr, authInfo)
 }
 
+func (m *Manager) AddVmVolumes(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, volumes []proto.Volume) error {
+	return m.addVmVolumes(ipAddr, authInfo, accessToken, volumes)
+}
+
 func (m *Manager) AddAddressesToPool(addresses []proto.Address) error {
 	return m.addAddressesToPool(addresses)
 }
@@ -111,11 +138,27 @@ func (m *Manager) ChangeVmDestroyProtection(ipAddr net.IP,
 	return m.changeVmDestroyProtection(ipAddr, authInfo, destroyProtection)
 }
 
+func (m *Manager) ChangeVmFirewallRules(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, rules *proto.FirewallRules) error {
+	return m.changeVmFirewallRules(ipAddr, authInfo, rules)
+}
+
 func (m *Manager) ChangeVmOwnerUsers(ipAddr net.IP,
 	authInfo *srpc.AuthInformation, extraUsers []string) error {
 	return m.changeVmOwnerUsers(ipAddr, authInfo, extraUsers)
 }
 
+func (m *Manager) ChangeVmRestartPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy proto.RestartPolicy,
+	maxRestarts uint) error {
+	return m.changeVmRestartPolicy(ipAddr, authInfo, policy, maxRestarts)
+}
+
+func (m *Manager) ChangeVmSize(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, memoryInMiB uint64, milliCPUs uint) error {
+	return m.changeVmSize(ipAddr, authInfo, memoryInMiB, milliCPUs)
+}
+
 func (m *Manager) ChangeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	return m.changeVmTags(ipAddr, authInfo, tgs)
@@ -183,8 +226,8 @@ func (m *Manager) DiscardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) DiscardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
-	return m.discardVmSnapshot(ipAddr, authInfo)
+	authInfo *srpc.AuthInformation, name string) error {
+	return m.discardVmSnapshot(ipAddr, authInfo, name)
 }
 
 func (m *Manager) ExportLocalVm(authInfo *srpc.AuthInformation,
@@ -217,6 +260,22 @@ func (m *Manager) GetVmInfo(ipAddr net.IP) (proto.VmInfo, error) {
 	return m.getVmInfo(ipAddr)
 }
 
+// GetVmIdentityCertificate returns a short-lived certificate (followed by the
+// CA certificate) and private key, PEM encoded, which attest the identity of
+// the VM.
+func (m *Manager) GetVmIdentityCertificate(ipAddr net.IP) ([]byte, []byte,
+	error) {
+	return m.getVmIdentityCertificate(ipAddr)
+}
+
+func (m *Manager) GetVmMemory(conn *srpc.Conn) error {
+	return m.getVmMemory(conn)
+}
+
+func (m *Manager) GetVmStats(ipAddr net.IP) (proto.VmStats, error) {
+	return m.getVmStats(ipAddr)
+}
+
 func (m *Manager) GetVmUserData(ipAddr net.IP) (io.ReadCloser, error) {
 	rc, _, err := m.getVmUserData(ipAddr,
 		&srpc.AuthInformation{HaveMethodAccess: true},
@@ -251,6 +310,10 @@ func (m *Manager) ListVMs(ownerUsers []string, doSort bool) []string {
 	return m.listVMs(ownerUsers, doSort)
 }
 
+func (m *Manager) ListVmSnapshots(ipAddr net.IP) ([]proto.Snapshot, error) {
+	return m.listVmSnapshots(ipAddr)
+}
+
 func (m *Manager) ListVolumeDirectories() []string {
 	return m.volumeDirectories
 }
@@ -304,9 +367,16 @@ func (m *Manager) ReplaceVmUserData(ipAddr net.IP, reader io.Reader,
 	return m.replaceVmUserData(ipAddr, reader, size, authInfo)
 }
 
+func (m *Manager) ResizeVmVolume(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, accessToken []byte, volumeIndex uint,
+	size uint64) error {
+	return m.resizeVmVolume(ipAddr, authInfo, accessToken, volumeIndex, size)
+}
+
 func (m *Manager) RestoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
-	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped)
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	name string) error {
+	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped, name)
 }
 
 func (m *Manager) RestoreVmImage(ipAddr net.IP,
@@ -324,8 +394,9 @@ func (m *Manager) ShutdownVMsAndExit() {
 }
 
 func (m *Manager) SnapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
-	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly)
+	forceIfNotStopped, snapshotRootOnly bool, name string) error {
+	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly,
+		name)
 }
 
 func (m *Manager) StartVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
@@ -0,0 +1,307 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	firewallTable   = "hypervisor"
+	metadataAddress = "169.254.169.254"
+)
+
+var (
+	errorFirewallDisabled = errors.New(
+		"VM firewall is disabled: nft not available")
+)
+
+type firewallVm struct {
+	addresses  []proto.Address
+	ipAddress  string
+	rules      *proto.FirewallRules
+	tapDevices []string
+}
+
+type firewallWriter struct {
+	buffer   bytes.Buffer
+	peerTags map[string][]string // Key: tag, value: IP addresses.
+	vms      []firewallVm
+}
+
+func isNftAvailable() bool {
+	_, err := exec.LookPath("nft")
+	return err == nil
+}
+
+func (m *Manager) changeVmFirewallRules(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, rules *proto.FirewallRules) error {
+	if err := m.checkFirewallRules(rules); err != nil {
+		return err
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	vm.FirewallRules = rules
+	vm.writeAndSendInfo()
+	return nil
+}
+
+// checkFirewallRules returns an error if the rules are not valid, or if there
+// are rules and the VM firewall is disabled.
+func (m *Manager) checkFirewallRules(rules *proto.FirewallRules) error {
+	if rules == nil {
+		return nil
+	}
+	if m.firewallDisabled {
+		return errorFirewallDisabled
+	}
+	return rules.CheckValid()
+}
+
+// firewallUpdater will re-program the firewall whenever an update is
+// requested. Updates are coalesced.
+func (m *Manager) firewallUpdater(updates <-chan struct{}) {
+	for range updates {
+		if err := m.updateFirewall(); err != nil {
+			m.Logger.Printf("error updating VM firewall: %s\n", err)
+		}
+	}
+}
+
+// requestFirewallUpdate will request an asynchronous update of the firewall.
+// It is safe to call with any locks held.
+func (m *Manager) requestFirewallUpdate() {
+	select {
+	case m.firewallUpdates <- struct{}{}:
+	default:
+	}
+}
+
+// startFirewall will start the firewall updater if nftables is available.
+func (m *Manager) startFirewall() {
+	if m.firewallDisabled {
+		m.Logger.Println(
+			"nft not available, VM firewall and anti-spoofing disabled")
+		return
+	}
+	updates := make(chan struct{}, 1)
+	m.firewallUpdates = updates
+	go m.firewallUpdater(updates)
+	m.requestFirewallUpdate()
+}
+
+// updateFirewall will atomically replace the nftables table for all VMs
+// which have tap devices.
+func (m *Manager) updateFirewall() error {
+	writer := &firewallWriter{peerTags: make(map[string][]string)}
+	m.mutex.RLock()
+	for _, vm := range m.vms {
+		vm.mutex.RLock()
+		for _, ipAddr := range vm.getIdentityIPs() {
+			for key, value := range vm.Tags {
+				tag := key + "=" + value
+				writer.peerTags[tag] = append(writer.peerTags[tag],
+					ipAddr.String())
+			}
+		}
+		if len(vm.TapDevices) > 0 && (vm.State == proto.StateStarting ||
+			vm.State == proto.StateRunning) {
+			addresses := make([]proto.Address, 0,
+				len(vm.SecondaryAddresses)+1)
+			addresses = append(addresses, vm.Address)
+			addresses = append(addresses, vm.SecondaryAddresses...)
+			writer.vms = append(writer.vms, firewallVm{
+				addresses:  addresses,
+				ipAddress:  vm.ipAddress,
+				rules:      vm.FirewallRules,
+				tapDevices: vm.TapDevices,
+			})
+		}
+		vm.mutex.RUnlock()
+	}
+	m.mutex.RUnlock()
+	sort.Slice(writer.vms, func(left, right int) bool {
+		return writer.vms[left].ipAddress < writer.vms[right].ipAddress
+	})
+	writer.write()
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = &writer.buffer
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running nft: %s: %s", err, output)
+	}
+	return nil
+}
+
+func chainName(ipAddress, suffix string) string {
+	return "vm-" + strings.Replace(ipAddress, ".", "-", -1) + "-" + suffix
+}
+
+func (w *firewallWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&w.buffer, format, args...)
+}
+
+// getPeers returns the addresses and networks matched by the rule. If the
+// rule matches any peer, nil is returned.
+func (w *firewallWriter) getPeers(rule proto.FirewallRule) []string {
+	if len(rule.Cidrs) < 1 && len(rule.PeerTags) < 1 {
+		return nil
+	}
+	peers := make([]string, 0, len(rule.Cidrs))
+	peers = append(peers, rule.Cidrs...)
+	var matchingAddresses map[string]struct{}
+	for key, value := range rule.PeerTags {
+		addresses := make(map[string]struct{})
+		for _, address := range w.peerTags[key+"="+value] {
+			if matchingAddresses == nil {
+				addresses[address] = struct{}{}
+			} else if _, ok := matchingAddresses[address]; ok {
+				addresses[address] = struct{}{}
+			}
+		}
+		matchingAddresses = addresses
+	}
+	for address := range matchingAddresses {
+		peers = append(peers, address)
+	}
+	sort.Strings(peers[len(rule.Cidrs):])
+	return peers
+}
+
+func (w *firewallWriter) write() {
+	// Creating and deleting the table first ensures that the delete will
+	// succeed. The whole file is applied atomically.
+	w.printf("table bridge %s\n", firewallTable)
+	w.printf("delete table bridge %s\n", firewallTable)
+	w.printf("table bridge %s {\n", firewallTable)
+	w.printf("\tchain prerouting {\n")
+	w.printf(
+		"\t\ttype filter hook prerouting priority -200; policy accept;\n")
+	for _, vm := range w.vms {
+		for index, tapDevice := range vm.tapDevices {
+			if index < len(vm.addresses) {
+				w.printf("\t\tiifname \"%s\" jump %s\n", tapDevice,
+					chainName(vm.ipAddress, fmt.Sprintf("spoof%d", index)))
+			}
+		}
+	}
+	w.printf("\t}\n")
+	w.printf("\tchain forward {\n")
+	w.printf("\t\ttype filter hook forward priority 0; policy accept;\n")
+	w.printf("\t\tct state established,related accept\n")
+	for _, vm := range w.vms {
+		if vm.rules == nil {
+			continue
+		}
+		for _, tapDevice := range vm.tapDevices {
+			w.printf("\t\tiifname \"%s\" jump %s\n", tapDevice,
+				chainName(vm.ipAddress, "egress"))
+		}
+		for _, tapDevice := range vm.tapDevices {
+			w.printf("\t\toifname \"%s\" jump %s\n", tapDevice,
+				chainName(vm.ipAddress, "ingress"))
+		}
+	}
+	w.printf("\t}\n")
+	for _, vm := range w.vms {
+		w.writeVm(vm)
+	}
+	w.printf("}\n")
+}
+
+// writeNeighbourRules writes rules which allow address resolution, so that
+// default deny rules do not break connectivity.
+func (w *firewallWriter) writeNeighbourRules() {
+	w.printf("\t\tether type arp return\n")
+	w.printf("\t\ticmpv6 type { nd-router-solicit, nd-router-advert, " +
+		"nd-neighbor-solicit, nd-neighbor-advert } return\n")
+}
+
+// writeRule writes a rule which returns to the forward chain if matched, so
+// that the rules for the VM at the other end (if local) are also applied.
+func (w *firewallWriter) writeRule(rule proto.FirewallRule, peerField string) {
+	var match []string
+	if rule.Protocol != "" {
+		match = append(match, "ip protocol "+rule.Protocol)
+	}
+	if peers := w.getPeers(rule); peers != nil {
+		if len(peers) < 1 {
+			return // Nothing can match.
+		}
+		match = append(match, fmt.Sprintf("ip %s { %s }", peerField,
+			strings.Join(peers, ", ")))
+	}
+	if len(rule.Ports) > 0 {
+		ports := make([]string, 0, len(rule.Ports))
+		for _, portRange := range rule.Ports {
+			if portRange.Last > portRange.First {
+				ports = append(ports, fmt.Sprintf("%d-%d",
+					portRange.First, portRange.Last))
+			} else {
+				ports = append(ports, fmt.Sprintf("%d", portRange.First))
+			}
+		}
+		match = append(match, fmt.Sprintf("%s dport { %s }", rule.Protocol,
+			strings.Join(ports, ", ")))
+	}
+	match = append(match, "return")
+	w.printf("\t\t%s\n", strings.Join(match, " "))
+}
+
+func (w *firewallWriter) writeVm(vm firewallVm) {
+	for index, address := range vm.addresses {
+		if index >= len(vm.tapDevices) {
+			break
+		}
+		w.printf("\tchain %s {\n",
+			chainName(vm.ipAddress, fmt.Sprintf("spoof%d", index)))
+		w.printf("\t\tether saddr != %s drop\n", address.MacAddress)
+		// Neighbour discovery uses the unspecified address or a link-local
+		// address, which need not be derived from the MAC address.
+		ip6Sources := []string{"::", "fe80::/10"}
+		if ipAddr := address.IpAddress; ipAddr.To4() != nil {
+			w.printf("\t\tarp saddr ip != %s drop\n", ipAddr)
+			w.printf("\t\tip saddr 0.0.0.0 udp dport 67 return\n")
+			w.printf("\t\tip saddr != %s drop\n", ipAddr)
+		} else if len(ipAddr) > 0 {
+			ip6Sources = append(ip6Sources, ipAddr.String())
+		}
+		w.printf("\t\tip6 saddr != { %s } drop\n",
+			strings.Join(ip6Sources, ", "))
+		w.printf("\t\ticmpv6 type nd-router-advert drop\n") // Rogue router.
+		w.printf("\t\tether type != { ip, ip6, arp } drop\n")
+		w.printf("\t}\n")
+	}
+	if vm.rules == nil {
+		return
+	}
+	w.printf("\tchain %s {\n", chainName(vm.ipAddress, "egress"))
+	w.writeNeighbourRules()
+	w.printf("\t\tip daddr %s return\n", metadataAddress)
+	w.printf("\t\tudp dport 67 return\n")
+	for _, rule := range vm.rules.Egress {
+		w.writeRule(rule, "daddr")
+	}
+	if vm.rules.EgressDefaultDeny {
+		w.printf("\t\tdrop\n")
+	}
+	w.printf("\t}\n")
+	w.printf("\tchain %s {\n", chainName(vm.ipAddress, "ingress"))
+	w.writeNeighbourRules()
+	for _, rule := range vm.rules.Ingress {
+		w.writeRule(rule, "saddr")
+	}
+	if vm.rules.IngressDefaultDeny {
+		w.printf("\t\tdrop\n")
+	}
+	w.printf("\t}\n")
+}
@@ -54,6 +54,10 @@ func (m *Manager) writeHtml(writer io.Writer) {
 		fmt.Fprintf(writer, "Owner users: %s<br>\n",
 			strings.Join(ownerUsers, " "))
 	}
+	if m.firewallDisabled {
+		fmt.Fprintln(writer, `VM firewall: <font color="red">disabled</font>`+
+			" (nft not available)<br>")
+	}
 	if m.serialNumber != "" {
 		fmt.Fprintf(writer, "Serial number: \"%s\"<br>\n", m.serialNumber)
 	}
@@ -0,0 +1,220 @@
+package manager
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/constants"
+)
+
+const (
+	identityCertLifetime = 24 * time.Hour
+	identityClockSkew    = 5 * time.Minute
+)
+
+// loadVmIdentityCa will load the intermediate CA used to sign VM identity
+// certificates. If the CA files are not configured or do not exist, VM
+// identity certificates are disabled.
+func (m *Manager) loadVmIdentityCa() error {
+	if m.VmCaCertFile == "" || m.VmCaKeyFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(m.VmCaKeyFile); os.IsNotExist(err) {
+		m.Logger.Println("no VM identity CA, not issuing VM certificates")
+		return nil
+	}
+	tlsCert, err := tls.LoadX509KeyPair(m.VmCaCertFile,
+		m.VmCaKeyFile)
+	if err != nil {
+		return fmt.Errorf("error loading VM identity CA: %s", err)
+	}
+	caCert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	if !caCert.IsCA {
+		return errors.New("VM identity CA certificate is not a CA")
+	}
+	signer, ok := tlsCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("VM identity CA key cannot sign")
+	}
+	m.identityCaCert = caCert
+	m.identityCaCertPEM = pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: caCert.Raw,
+	})
+	m.identityCaKey = signer
+	return nil
+}
+
+func makeListExtension(oid string, list []string) (pkix.Extension, error) {
+	var id asn1.ObjectIdentifier
+	for _, field := range strings.Split(oid, ".") {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		id = append(id, value)
+	}
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: id, Value: value}, nil
+}
+
+func (m *Manager) getVmIdentityCertificate(ipAddr net.IP) (
+	[]byte, []byte, error) {
+	if m.identityCaKey == nil {
+		return nil, nil, errors.New("VM identity certificates not available")
+	}
+	vm, err := m.getVmAndLock(ipAddr, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer vm.mutex.Unlock()
+	fingerprint := vm.getIdentityFingerprint()
+	renewTime := vm.identityExpires.Add(-identityCertLifetime / 2)
+	if vm.identityCertPEM == nil || time.Now().After(renewTime) ||
+		fingerprint != vm.identityFingerprint {
+		if err := vm.makeIdentityCertificate(fingerprint); err != nil {
+			return nil, nil, err
+		}
+	}
+	return vm.identityCertPEM, vm.identityKeyPEM, nil
+}
+
+// getIdentityFingerprint returns a string which changes whenever any of the
+// attributes encoded in the identity certificate change. The VM lock must be
+// held.
+func (vm *vmInfoType) getIdentityFingerprint() string {
+	fields := []string{vm.Hostname}
+	for _, ipAddr := range vm.getIdentityIPs() {
+		fields = append(fields, ipAddr.String())
+	}
+	fields = append(fields, strings.Join(vm.OwnerUsers, ","),
+		strings.Join(vm.OwnerGroups, ","))
+	fields = append(fields, vm.getIdentityTags()...)
+	return strings.Join(fields, "\n")
+}
+
+func (vm *vmInfoType) getIdentityIPs() []net.IP {
+	ipAddrs := []net.IP{vm.Address.IpAddress}
+	for _, address := range vm.SecondaryAddresses {
+		if len(address.IpAddress) > 0 {
+			ipAddrs = append(ipAddrs, address.IpAddress)
+		}
+	}
+	return ipAddrs
+}
+
+func (vm *vmInfoType) getIdentityTags() []string {
+	tagList := make([]string, 0, len(vm.Tags))
+	for key, value := range vm.Tags {
+		tagList = append(tagList, key+"="+value)
+	}
+	sort.Strings(tagList)
+	return tagList
+}
+
+// makeIdentityCertificate will sign a new identity certificate for the VM.
+// The private key is kept in memory and re-used for renewals. The VM lock
+// must be held.
+func (vm *vmInfoType) makeIdentityCertificate(fingerprint string) error {
+	m := vm.manager
+	if vm.identityKey == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		vm.identityKey = key
+		vm.identityKeyPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyDER,
+		})
+	}
+	serialNumber, err := rand.Int(rand.Reader,
+		new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	extensions := make([]pkix.Extension, 0, 3)
+	for _, list := range []struct {
+		oid   string
+		items []string
+	}{
+		{constants.VmOwnerGroupsOID, vm.OwnerGroups},
+		{constants.VmOwnerUsersOID, vm.OwnerUsers},
+		{constants.VmTagsOID, vm.getIdentityTags()},
+	} {
+		if len(list.items) < 1 {
+			continue
+		}
+		extension, err := makeListExtension(list.oid, list.items)
+		if err != nil {
+			return err
+		}
+		extensions = append(extensions, extension)
+	}
+	now := time.Now()
+	expires := now.Add(identityCertLifetime)
+	if expires.After(m.identityCaCert.NotAfter) {
+		expires = m.identityCaCert.NotAfter
+	}
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   vm.Address.IpAddress.String(),
+			Organization: []string{"SmallStack VM"},
+		},
+		NotBefore: now.Add(-identityClockSkew),
+		NotAfter:  expires,
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageClientAuth,
+			x509.ExtKeyUsageServerAuth,
+		},
+		BasicConstraintsValid: true,
+		IPAddresses:           vm.getIdentityIPs(),
+		ExtraExtensions:       extensions,
+	}
+	if vm.Hostname != "" {
+		template.DNSNames = []string{vm.Hostname}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template,
+		m.identityCaCert, &vm.identityKey.PublicKey, m.identityCaKey)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certDER,
+	})
+	vm.identityCertPEM = append(certPEM, m.identityCaCertPEM...)
+	vm.identityExpires = expires
+	vm.identityFingerprint = fingerprint
+	vm.logger.Debugf(0, "issued identity certificate, expires: %s\n",
+		expires.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,371 @@
+package manager
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const migrationTimeout = time.Minute
+
+type migrationStatus struct {
+	Status string `json:"status"`
+}
+
+// receiveChunks will copy length-prefixed chunks from reader to writer until
+// an empty chunk is received.
+func receiveChunks(writer io.Writer, reader io.Reader) (uint64, error) {
+	var total uint64
+	for {
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return total, err
+		}
+		if length < 1 {
+			return total, nil
+		}
+		if _, err := io.CopyN(writer, reader, int64(length)); err != nil {
+			return total, err
+		}
+		total += uint64(length)
+	}
+}
+
+// sendChunks will copy from reader to writer as length-prefixed chunks until
+// EOF, followed by an empty chunk.
+func sendChunks(writer io.Writer, reader io.Reader) (uint64, error) {
+	buffer := make([]byte, 65536)
+	var total uint64
+	for {
+		nRead, err := reader.Read(buffer)
+		if nRead > 0 {
+			e := binary.Write(writer, binary.BigEndian, uint32(nRead))
+			if e != nil {
+				return total, e
+			}
+			if _, err := writer.Write(buffer[:nRead]); err != nil {
+				return total, err
+			}
+			total += uint64(nRead)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return total, err
+		}
+	}
+	return total, binary.Write(writer, binary.BigEndian, uint32(0))
+}
+
+// getVmMemory is run on the source hypervisor. It will send the memory and
+// device state of a running VM to the destination hypervisor and then keep
+// the VM paused until the destination commits or abandons the migration.
+func (m *Manager) getVmMemory(conn *srpc.Conn) error {
+	var request proto.GetVmMemoryRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	authInfo := *conn.GetAuthInformation()
+	authInfo.HaveMethodAccess = false // Require VM ownership or token.
+	vm, err := m.getVmLockAndAuth(request.IpAddress, false, &authInfo,
+		request.AccessToken)
+	if err != nil {
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	if vm.State != proto.StateRunning {
+		vm.mutex.RUnlock()
+		return conn.Encode(proto.GetVmMemoryResponse{
+			Error: "VM is not running"})
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		vm.mutex.RUnlock()
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	defer listener.Close()
+	id, responseChannel, err := vm.queueMonitorCommand("migrate",
+		map[string]string{"uri": "tcp:" + listener.Addr().String()})
+	vm.mutex.RUnlock()
+	if err != nil {
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	doResume := true
+	defer func() {
+		if doResume {
+			vm.resumeAfterMigration()
+		}
+	}()
+	_, err = vm.waitMonitorResponse(id, responseChannel, migrationTimeout)
+	if err != nil {
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(migrationTimeout))
+	qemuConn, err := listener.Accept()
+	if err != nil {
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	defer qemuConn.Close()
+	vm.logger.Println("sending memory to migrating VM")
+	if err := conn.Encode(proto.GetVmMemoryResponse{}); err != nil {
+		return err
+	}
+	if _, err := sendChunks(conn, qemuConn); err != nil {
+		return err
+	}
+	status, err := vm.waitMigrationStatus("query-migrate", "completed",
+		"failed", "cancelled")
+	if err == nil && status != "completed" {
+		err = fmt.Errorf("migration %s", status)
+	}
+	if err != nil {
+		conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+		return conn.Flush()
+	}
+	if err := conn.Encode(proto.GetVmMemoryResponse{}); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var reply proto.GetVmMemoryResponseResponse
+	if err := conn.Decode(&reply); err != nil {
+		return err
+	}
+	if !reply.Commit {
+		return nil
+	}
+	vm.mutex.Lock()
+	if vm.State != proto.StateRunning || vm.commandChannel == nil {
+		vm.mutex.Unlock()
+		return conn.Encode(proto.GetVmMemoryResponse{
+			Error: "VM is not running"})
+	}
+	doResume = false
+	stoppedNotifier := make(chan struct{}, 1)
+	vm.stoppedNotifier = stoppedNotifier
+	vm.setState(proto.StateStopping)
+	vm.commandChannel <- "quit"
+	vm.mutex.Unlock()
+	<-stoppedNotifier
+	vm.logger.Println("stopped VM after memory migration")
+	err = m.prepareVmForMigration(request.IpAddress,
+		conn.GetAuthInformation(), request.AccessToken, true)
+	if err != nil {
+		return conn.Encode(proto.GetVmMemoryResponse{Error: err.Error()})
+	}
+	return conn.Encode(proto.GetVmMemoryResponse{})
+}
+
+// abandonLiveMigration is run on the destination hypervisor. It will tell the
+// source hypervisor to resume the paused VM.
+func abandonLiveMigration(memoryConn *srpc.Conn) {
+	memoryConn.Encode(proto.GetVmMemoryResponseResponse{})
+	memoryConn.Flush()
+	memoryConn.Close()
+}
+
+// commitLiveMigration is run on the destination hypervisor once the client
+// has committed the migration. It will tell the source hypervisor to stop the
+// paused VM and then resume the VM here. If false is returned the source VM
+// may still be running, so the migration must be abandoned. memoryConn is
+// always closed.
+func (vm *vmInfoType) commitLiveMigration(memoryConn *srpc.Conn,
+	hypervisor *srpc.Client) bool {
+	defer memoryConn.Close()
+	err := memoryConn.Encode(proto.GetVmMemoryResponseResponse{Commit: true})
+	if err == nil {
+		err = memoryConn.Flush()
+	}
+	if err != nil {
+		vm.logger.Printf("error committing migration: %s\n", err)
+		return false
+	}
+	var response proto.GetVmMemoryResponse
+	if err := memoryConn.Decode(&response); err != nil {
+		// The source may or may not have received the commit.
+		vm.logger.Printf("error reading commit response: %s\n", err)
+		if vm.isRunningOnSource(hypervisor) {
+			return false
+		}
+	} else if response.Error != "" {
+		// The VM is no longer running on the source, so carry on.
+		vm.logger.Printf("error stopping source VM: %s\n", response.Error)
+	}
+	_, err = vm.sendMonitorCommand("cont", nil, migrationTimeout)
+	if err != nil {
+		vm.logger.Printf("error resuming migrated VM: %s\n", err)
+	} else {
+		vm.logger.Println("resumed migrated VM")
+	}
+	return true
+}
+
+// isRunningOnSource returns true if the source hypervisor reports that the VM
+// is running.
+func (vm *vmInfoType) isRunningOnSource(hypervisor *srpc.Client) bool {
+	request := proto.GetVmInfoRequest{vm.Address.IpAddress}
+	var reply proto.GetVmInfoResponse
+	err := hypervisor.RequestReply("Hypervisor.GetVmInfo", request, &reply)
+	if err != nil || reply.Error != "" {
+		return false
+	}
+	return reply.VmInfo.State == proto.StateRunning
+}
+
+// migrateVmLive is run on the destination hypervisor. It will start QEMU
+// waiting for an incoming migration, copy the memory and device state of the
+// running VM from the source hypervisor and update the volumes while the VM is
+// paused. The VM is left paused on both hypervisors and the returned
+// connection must be passed to commitLiveMigration or abandonLiveMigration.
+func (m *Manager) migrateVmLive(conn *srpc.Conn, hypervisor *srpc.Client,
+	vm *vmInfoType, accessToken []byte) (*srpc.Conn, error) {
+	if err := sendVmMigrationMessage(conn, "starting incoming VM"); err != nil {
+		return nil, err
+	}
+	vm.State = proto.StateStarting
+	m.mutex.Lock()
+	m.vms[vm.ipAddress] = vm
+	m.mutex.Unlock()
+	vm.monitorSockname = filepath.Join(vm.dirname, "monitor.sock")
+	if err := vm.startVm(false, true); err != nil {
+		return nil, err
+	}
+	if _, err := vm.startManaging(0, false); err != nil {
+		return nil, err
+	}
+	// Do not activate the volumes until the VM is resumed, since they will be
+	// updated after the source VM is paused.
+	_, err := vm.sendMonitorCommand("migrate-set-capabilities",
+		map[string]interface{}{
+			"capabilities": []map[string]interface{}{
+				{"capability": "late-block-activate", "state": true},
+			},
+		},
+		migrationTimeout)
+	if err != nil {
+		for index := range vm.VolumeLocations {
+			if vm.getVolumeFormat(index) == proto.VolumeFormatQCOW2 {
+				return nil, fmt.Errorf(
+					"cannot live migrate QCOW2 volumes: %s", err)
+			}
+		}
+		vm.logger.Printf("error enabling late block activation: %s\n", err)
+	}
+	memoryConn, err := hypervisor.Call("Hypervisor.GetVmMemory")
+	if err != nil {
+		return nil, err
+	}
+	err = vm.receiveVmMemory(conn, hypervisor, memoryConn, accessToken)
+	if err != nil {
+		abandonLiveMigration(memoryConn)
+		return nil, err
+	}
+	return memoryConn, nil
+}
+
+// receiveVmMemory will copy the memory and device state of the VM from the
+// source hypervisor and then update the volumes, leaving the VM paused on
+// both hypervisors.
+func (vm *vmInfoType) receiveVmMemory(conn *srpc.Conn,
+	hypervisor *srpc.Client, memoryConn *srpc.Conn,
+	accessToken []byte) error {
+	request := proto.GetVmMemoryRequest{
+		AccessToken: accessToken,
+		IpAddress:   vm.Address.IpAddress,
+	}
+	if err := memoryConn.Encode(request); err != nil {
+		return fmt.Errorf("error encoding request: %s", err)
+	}
+	if err := memoryConn.Flush(); err != nil {
+		return err
+	}
+	var response proto.GetVmMemoryResponse
+	if err := memoryConn.Decode(&response); err != nil {
+		return err
+	}
+	if err := errors.New(response.Error); err != nil {
+		return err
+	}
+	err := sendVmMigrationMessage(conn, "copying memory, VM still running")
+	if err != nil {
+		return err
+	}
+	qemuConn, err := net.Dial("unix",
+		filepath.Join(vm.dirname, migrationSockFilename))
+	if err != nil {
+		return err
+	}
+	nBytes, err := receiveChunks(qemuConn, memoryConn)
+	qemuConn.Close()
+	if err != nil {
+		return err
+	}
+	if err := memoryConn.Decode(&response); err != nil {
+		return err
+	}
+	if err := errors.New(response.Error); err != nil {
+		return err
+	}
+	_, err = vm.waitMigrationStatus("query-status", "paused")
+	if err != nil {
+		return err
+	}
+	err = sendVmMigrationMessage(conn,
+		fmt.Sprintf("copied %s of memory, VM paused, update volume(s)",
+			format.FormatBytes(nBytes)))
+	if err != nil {
+		return err
+	}
+	return vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress, accessToken)
+}
+
+// resumeAfterMigration will cancel any migration of the VM and resume it if
+// it was paused.
+func (vm *vmInfoType) resumeAfterMigration() {
+	_, err := vm.sendMonitorCommand("migrate_cancel", nil, migrationTimeout)
+	if err != nil {
+		vm.logger.Printf("error cancelling migration: %s\n", err)
+	}
+	_, err = vm.sendMonitorCommand("cont", nil, migrationTimeout)
+	if err != nil {
+		vm.logger.Printf("error resuming VM: %s\n", err)
+	} else {
+		vm.logger.Println("resumed VM after failed migration")
+	}
+}
+
+// waitMigrationStatus will poll the QEMU monitor with the specified command
+// until the status is one of the specified statuses.
+func (vm *vmInfoType) waitMigrationStatus(command string,
+	statuses ...string) (string, error) {
+	stopTime := time.Now().Add(migrationTimeout)
+	for {
+		result, err := vm.sendMonitorCommand(command, nil, migrationTimeout)
+		if err != nil {
+			return "", err
+		}
+		var status migrationStatus
+		if err := json.Unmarshal(result, &status); err != nil {
+			return "", err
+		}
+		for _, s := range statuses {
+			if status.Status == s {
+				return status.Status, nil
+			}
+		}
+		if time.Until(stopTime) <= 0 {
+			return "", fmt.Errorf("timed out waiting for migration, status: %s",
+				status.Status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
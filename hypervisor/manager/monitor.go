@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+const monitorCommandTimeout = 30 * time.Second
+
+type monitorCommand struct {
+	Arguments interface{} `json:"arguments,omitempty"`
+	Execute   string      `json:"execute"`
+	Id        string      `json:"id,omitempty"`
+}
+
+type monitorError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+type monitorResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Error  *monitorError   `json:"error"`
+	Event  string          `json:"event"`
+	Id     string          `json:"id"`
+	Return json.RawMessage `json:"return"`
+}
+
+type shutdownEventData struct {
+	Guest bool `json:"guest"`
+}
+
+// processMonitorOutput will read responses from the QEMU monitor and deliver
+// them to any waiters, until the monitor is closed. All remaining waiters are
+// then released. It returns true if the guest initiated a shutdown.
+func (vm *vmInfoType) processMonitorOutput(reader io.Reader) bool {
+	decoder := json.NewDecoder(reader)
+	var guestShutdown bool
+	for {
+		var response monitorResponse
+		if err := decoder.Decode(&response); err != nil {
+			break
+		}
+		if response.Event == "SHUTDOWN" {
+			// Older QEMU versions do not provide the reason.
+			data := shutdownEventData{Guest: true}
+			if len(response.Data) > 0 {
+				json.Unmarshal(response.Data, &data)
+			}
+			guestShutdown = data.Guest
+		}
+		if response.Id == "" {
+			continue
+		}
+		vm.monitorLock.Lock()
+		if waiter, ok := vm.monitorWaiters[response.Id]; ok {
+			delete(vm.monitorWaiters, response.Id)
+			waiter <- response
+		}
+		vm.monitorLock.Unlock()
+	}
+	vm.monitorLock.Lock()
+	for _, waiter := range vm.monitorWaiters {
+		close(waiter)
+	}
+	vm.monitorWaiters = nil
+	vm.monitorLock.Unlock()
+	return guestShutdown
+}
+
+// queueMonitorCommand will send a command to the QEMU monitor and returns the
+// command ID and a channel which will receive the response. The VM lock must
+// be held.
+func (vm *vmInfoType) queueMonitorCommand(command string,
+	arguments interface{}) (string, <-chan monitorResponse, error) {
+	if vm.commandChannel == nil {
+		return "", nil, errors.New("VM monitor is not connected")
+	}
+	vm.monitorLock.Lock()
+	vm.nextMonitorId++
+	id := strconv.FormatUint(vm.nextMonitorId, 10)
+	responseChannel := make(chan monitorResponse, 1)
+	if vm.monitorWaiters == nil {
+		vm.monitorWaiters = make(map[string]chan<- monitorResponse)
+	}
+	vm.monitorWaiters[id] = responseChannel
+	vm.monitorLock.Unlock()
+	data, err := json.Marshal(monitorCommand{arguments, command, id})
+	if err != nil {
+		vm.monitorLock.Lock()
+		delete(vm.monitorWaiters, id)
+		vm.monitorLock.Unlock()
+		return "", nil, err
+	}
+	vm.commandChannel <- string(data)
+	return id, responseChannel, nil
+}
+
+// sendMonitorCommand will send a command to the QEMU monitor and wait for the
+// response. The VM lock must not be held.
+func (vm *vmInfoType) sendMonitorCommand(command string,
+	arguments interface{}, timeout time.Duration) (json.RawMessage, error) {
+	vm.mutex.RLock()
+	id, responseChannel, err := vm.queueMonitorCommand(command, arguments)
+	vm.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return vm.waitMonitorResponse(id, responseChannel, timeout)
+}
+
+// waitMonitorResponse will wait for the response to a command queued with
+// queueMonitorCommand.
+func (vm *vmInfoType) waitMonitorResponse(id string,
+	responseChannel <-chan monitorResponse,
+	timeout time.Duration) (json.RawMessage, error) {
+	timer := time.NewTimer(timeout)
+	select {
+	case response, ok := <-responseChannel:
+		timer.Stop()
+		if !ok {
+			return nil, errors.New("VM monitor closed")
+		}
+		if response.Error != nil {
+			return nil, errors.New(response.Error.Desc)
+		}
+		return response.Return, nil
+	case <-timer.C:
+		vm.monitorLock.Lock()
+		delete(vm.monitorWaiters, id)
+		vm.monitorLock.Unlock()
+		return nil, errors.New("timed out waiting for VM monitor")
+	}
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"net"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	restartBackoffMaximum = 5 * time.Minute
+	restartBackoffMinimum = 5 * time.Second
+	restartStableTime     = 10 * time.Minute
+)
+
+func (m *Manager) changeVmRestartPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy proto.RestartPolicy,
+	maxRestarts uint) error {
+	if err := policy.CheckValid(); err != nil {
+		return err
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	vm.MaxRestarts = maxRestarts
+	vm.RestartPolicy = policy
+	if policy == proto.RestartNever && vm.CrashInfo != nil {
+		vm.CrashInfo.RestartPending = false
+	}
+	vm.writeAndSendInfo()
+	return nil
+}
+
+// autoRestart will restart the VM if a restart is still pending.
+func (vm *vmInfoType) autoRestart() {
+	vm.mutex.Lock()
+	if vm.CrashInfo == nil || !vm.CrashInfo.RestartPending {
+		vm.mutex.Unlock()
+		return
+	}
+	if vm.State != proto.StateStopped &&
+		vm.State != proto.StateFailedToStart {
+		vm.mutex.Unlock()
+		return
+	}
+	vm.startTime = time.Now()
+	vm.CrashInfo.ConsecutiveRestarts++
+	vm.CrashInfo.NumRestarts++
+	if err := checkAvailableMemory(vm.MemoryInMiB); err != nil {
+		vm.recordExit(true, "restart failed: "+err.Error())
+		vm.mutex.Unlock()
+		return
+	}
+	vm.logger.Printf("restarting VM, restart: %d\n", vm.CrashInfo.NumRestarts)
+	vm.setState(proto.StateStarting)
+	vm.mutex.Unlock()
+	if _, err := vm.startManaging(0, false); err != nil {
+		vm.mutex.Lock()
+		defer vm.mutex.Unlock()
+		if vm.State == proto.StateFailedToStart {
+			vm.recordExit(true, "restart failed: "+err.Error())
+		}
+	}
+}
+
+// handleUnexpectedExit is called when QEMU exits while the VM is supposed to
+// be running. The restart policy is applied.
+func (vm *vmInfoType) handleUnexpectedExit(guestShutdown bool) {
+	vm.manager.mutex.RLock()
+	shuttingDown := vm.manager.shuttingDown
+	vm.manager.mutex.RUnlock()
+	if shuttingDown {
+		return // VMs which were running are restarted on the next start.
+	}
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if vm.State != proto.StateRunning || vm.commandChannel != nil {
+		return
+	}
+	if guestShutdown {
+		vm.logger.Println("VM shut down")
+		vm.recordExit(false, "")
+	} else {
+		vm.logger.Println("QEMU exited unexpectedly")
+		vm.recordExit(true, "QEMU exited unexpectedly")
+	}
+}
+
+// recordExit will record a crash (if failed) and will either schedule a
+// restart or leave the VM stopped. The VM lock must be held.
+func (vm *vmInfoType) recordExit(failed bool, reason string) {
+	if failed {
+		if vm.CrashInfo == nil {
+			vm.CrashInfo = &proto.CrashInfo{}
+		}
+		vm.CrashInfo.LastCrashReason = reason
+		vm.CrashInfo.LastCrashTime = time.Now()
+		vm.CrashInfo.NumCrashes++
+	}
+	if vm.CrashInfo != nil && time.Since(vm.startTime) >= restartStableTime {
+		vm.CrashInfo.ConsecutiveRestarts = 0
+	}
+	var restart bool
+	switch vm.RestartPolicy {
+	case proto.RestartOnFailure:
+		restart = failed
+	case proto.RestartAlways:
+		restart = true
+	}
+	if restart && vm.CrashInfo == nil {
+		vm.CrashInfo = &proto.CrashInfo{}
+	}
+	if restart && vm.RestartPolicy == proto.RestartOnFailure &&
+		vm.MaxRestarts > 0 &&
+		vm.CrashInfo.ConsecutiveRestarts >= vm.MaxRestarts {
+		vm.logger.Printf("not restarting after %d consecutive restarts\n",
+			vm.CrashInfo.ConsecutiveRestarts)
+		restart = false
+	}
+	if vm.CrashInfo != nil {
+		vm.CrashInfo.RestartPending = restart
+	}
+	if vm.State == proto.StateRunning {
+		vm.setState(proto.StateStopped)
+	} else {
+		vm.writeAndSendInfo()
+	}
+	if restart {
+		vm.scheduleRestart()
+	}
+}
+
+// scheduleRestart will restart the VM after a delay which increases with the
+// number of consecutive restarts.
+func (vm *vmInfoType) scheduleRestart() {
+	delay := restartBackoffMinimum
+	for count := uint(0); count < vm.CrashInfo.ConsecutiveRestarts; count++ {
+		if delay >= restartBackoffMaximum {
+			break
+		}
+		delay *= 2
+	}
+	if delay > restartBackoffMaximum {
+		delay = restartBackoffMaximum
+	}
+	vm.logger.Printf("restarting VM in %s\n", delay)
+	time.AfterFunc(delay, vm.autoRestart)
+}
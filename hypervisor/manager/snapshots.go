@@ -0,0 +1,294 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/wsyscall"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const ficlone = 0x40049409 // From linux/fs.h.
+
+type qemuImageInfo struct {
+	Snapshots []struct {
+		Name string `json:"name"`
+	} `json:"snapshots"`
+	VirtualSize uint64 `json:"virtual-size"`
+}
+
+func checkSnapshotName(name string) error {
+	if name == "" {
+		return errors.New("no snapshot name specified")
+	}
+	if name[0] == '.' || name[0] == '-' {
+		return fmt.Errorf("invalid snapshot name: %s", name)
+	}
+	for _, ch := range name {
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') ||
+			(ch >= '0' && ch <= '9') || ch == '-' || ch == '_' || ch == '.' {
+			continue
+		}
+		return fmt.Errorf("invalid character: %q in snapshot name", ch)
+	}
+	return nil
+}
+
+// cloneFile will make a copy-on-write clone (reflink) of a file if the
+// filesystem supports it, else it will make a full copy.
+func cloneFile(destFilename, sourceFilename string) error {
+	sourceFile, err := os.Open(sourceFilename)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destFile, err := os.OpenFile(destFilename,
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY, privateFilePerms)
+	if err != nil {
+		return err
+	}
+	err = wsyscall.Ioctl(int(destFile.Fd()), ficlone, sourceFile.Fd())
+	if closeErr := destFile.Close(); err == nil {
+		return closeErr
+	}
+	os.Remove(destFilename)
+	return fsutil.CopyFile(destFilename, sourceFilename, privateFilePerms)
+}
+
+// getAllocatedSize returns the space allocated to a file. Extents shared with
+// other files (reflinks) are included.
+func getAllocatedSize(filename string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(filename, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Blocks) * 512, nil
+}
+
+func getQemuImageInfo(filename string) (qemuImageInfo, error) {
+	var info qemuImageInfo
+	cmd := exec.Command("qemu-img", "info", "--output=json", "-U", filename)
+	if output, err := cmd.Output(); err != nil {
+		return info, fmt.Errorf("error running qemu-img: %s", err)
+	} else if err := json.Unmarshal(output, &info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+func hasInternalSnapshot(filename, name string) (bool, error) {
+	info, err := getQemuImageInfo(filename)
+	if err != nil {
+		return false, err
+	}
+	for _, snapshot := range info.Snapshots {
+		if snapshot.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func snapshotFilename(volumeFilename, name string) string {
+	return volumeFilename + ".snapshot." + name
+}
+
+func (m *Manager) listVmSnapshots(ipAddr net.IP) ([]proto.Snapshot, error) {
+	vm, err := m.getVmAndLock(ipAddr, false)
+	if err != nil {
+		return nil, err
+	}
+	defer vm.mutex.RUnlock()
+	snapshots := make([]proto.Snapshot, len(vm.Snapshots))
+	copy(snapshots, vm.Snapshots)
+	return snapshots, nil
+}
+
+// createSnapshot will create a named snapshot. QCOW2 volumes of a stopped VM
+// get an internal snapshot, other volumes are cloned. The VM lock must be
+// held.
+func (vm *vmInfoType) createSnapshot(name string, rootOnly bool) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+	if vm.findSnapshot(name) >= 0 {
+		return fmt.Errorf("snapshot: %s already exists", name)
+	}
+	snapshot := proto.Snapshot{
+		CreatedOn: time.Now(),
+		Name:      name,
+		RootOnly:  rootOnly,
+	}
+	var clonedFiles, internalSnapshotVolumes []string
+	doCleanup := true
+	defer func() {
+		if !doCleanup {
+			return
+		}
+		for _, filename := range clonedFiles {
+			os.Remove(filename)
+		}
+		for _, filename := range internalSnapshotVolumes {
+			runQemuImg("snapshot", "-d", name, filename)
+		}
+	}()
+	for index, volume := range vm.VolumeLocations {
+		if index > 0 && rootOnly {
+			break
+		}
+		if vm.State == proto.StateStopped &&
+			vm.getVolumeFormat(index) == proto.VolumeFormatQCOW2 {
+			err := runQemuImg("snapshot", "-c", name, volume.Filename)
+			if err != nil {
+				return err
+			}
+			internalSnapshotVolumes = append(internalSnapshotVolumes,
+				volume.Filename)
+			continue
+		}
+		filename := snapshotFilename(volume.Filename, name)
+		os.Remove(filename) // Left over from an incomplete snapshot.
+		if err := cloneFile(filename, volume.Filename); err != nil {
+			return err
+		}
+		clonedFiles = append(clonedFiles, filename)
+		if size, err := getAllocatedSize(filename); err != nil {
+			return err
+		} else {
+			snapshot.Size += size
+		}
+	}
+	doCleanup = false
+	vm.Snapshots = append(vm.Snapshots, snapshot)
+	vm.writeAndSendInfo()
+	return nil
+}
+
+// deleteSnapshot will delete a named snapshot. The VM lock must be held.
+func (vm *vmInfoType) deleteSnapshot(name string) error {
+	snapshotIndex := vm.findSnapshot(name)
+	if snapshotIndex < 0 {
+		return fmt.Errorf("snapshot: %s not found", name)
+	}
+	for index, volume := range vm.VolumeLocations {
+		err := os.Remove(snapshotFilename(volume.Filename, name))
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if vm.getVolumeFormat(index) != proto.VolumeFormatQCOW2 {
+			continue
+		}
+		if found, err := hasInternalSnapshot(volume.Filename, name); err != nil {
+			return err
+		} else if found {
+			err := runQemuImg("snapshot", "-d", name, volume.Filename)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	vm.Snapshots = append(vm.Snapshots[:snapshotIndex],
+		vm.Snapshots[snapshotIndex+1:]...)
+	vm.writeAndSendInfo()
+	return nil
+}
+
+// findSnapshot returns the index of the named snapshot or -1 if not found.
+func (vm *vmInfoType) findSnapshot(name string) int {
+	for index, snapshot := range vm.Snapshots {
+		if snapshot.Name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func (vm *vmInfoType) getVolumeFormat(index int) proto.VolumeFormat {
+	if index < len(vm.Volumes) {
+		return vm.Volumes[index].Format
+	}
+	return proto.VolumeFormatRaw
+}
+
+// restoreSnapshot will restore the volumes from a named snapshot. The
+// snapshot is kept. Volumes which are not in the snapshot are not changed.
+// The VM lock must be held.
+func (vm *vmInfoType) restoreSnapshot(name string) error {
+	if vm.findSnapshot(name) < 0 {
+		return fmt.Errorf("snapshot: %s not found", name)
+	}
+	for index := range vm.VolumeLocations {
+		if restored, err := vm.restoreVolume(index, name); err != nil {
+			return err
+		} else if restored {
+			if err := vm.updateVolumeSize(index); err != nil {
+				return err
+			}
+		}
+	}
+	vm.writeAndSendInfo()
+	return nil
+}
+
+func (vm *vmInfoType) restoreVolume(index int, name string) (bool, error) {
+	volumeFilename := vm.VolumeLocations[index].Filename
+	filename := snapshotFilename(volumeFilename, name)
+	if _, err := os.Stat(filename); err == nil {
+		tmpFilename := volumeFilename + ".tmp"
+		os.Remove(tmpFilename)
+		if err := cloneFile(tmpFilename, filename); err != nil {
+			return false, err
+		}
+		if err := os.Rename(tmpFilename, volumeFilename); err != nil {
+			os.Remove(tmpFilename)
+			return false, err
+		}
+		return true, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	if vm.getVolumeFormat(index) != proto.VolumeFormatQCOW2 {
+		return false, nil
+	}
+	if found, err := hasInternalSnapshot(volumeFilename, name); err != nil {
+		return false, err
+	} else if !found {
+		return false, nil
+	}
+	if vm.State != proto.StateStopped {
+		return false,
+			errors.New("VM must be stopped to restore internal QCOW2 snapshot")
+	}
+	return true, runQemuImg("snapshot", "-a", name, volumeFilename)
+}
+
+// updateVolumeSize will update the recorded size of a volume from the volume
+// file.
+func (vm *vmInfoType) updateVolumeSize(index int) error {
+	if index >= len(vm.Volumes) {
+		return nil
+	}
+	filename := vm.VolumeLocations[index].Filename
+	if vm.Volumes[index].Format == proto.VolumeFormatQCOW2 {
+		if info, err := getQemuImageInfo(filename); err != nil {
+			return err
+		} else {
+			vm.Volumes[index].Size = info.VirtualSize
+		}
+	} else if fi, err := os.Stat(filename); err != nil {
+		return err
+	} else {
+		vm.Volumes[index].Size = uint64(fi.Size())
+	}
+	return nil
+}
@@ -47,6 +47,7 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 	manager := &Manager{
 		StartOptions:      startOptions,
 		rootCookie:        rootCookie,
+		firewallDisabled:  !isNftAvailable(),
 		memTotalInMiB:     memInfo.Total >> 20,
 		notifiers:         make(map[<-chan proto.Update]chan<- proto.Update),
 		numCPU:            runtime.NumCPU(),
@@ -54,6 +55,9 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		vms:               make(map[string]*vmInfoType),
 		volumeDirectories: startOptions.VolumeDirectories,
 	}
+	if err := manager.loadVmIdentityCa(); err != nil {
+		return nil, err
+	}
 	if err := manager.loadSubnets(); err != nil {
 		return nil, err
 	}
@@ -88,6 +92,7 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 			return nil, err
 		}
 		vmInfo.Address.Shrink()
+		vmInfo.FirewallDisabled = manager.firewallDisabled
 		vmInfo.manager = manager
 		vmInfo.dirname = vmDirname
 		vmInfo.ipAddress = ipAddr
@@ -105,6 +110,9 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 				vmInfo.destroy()
 			}
 		}
+		if vmInfo.CrashInfo != nil && vmInfo.CrashInfo.RestartPending {
+			vmInfo.scheduleRestart()
+		}
 	}
 	// Check address pool for used addresses with no VM.
 	freeIPs := make(map[string]struct{}, len(manager.addressPool.Free))
@@ -159,7 +167,9 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		}
 		manager.objectCache = objSrv
 	}
+	manager.startFirewall()
 	go manager.loopCheckHealthStatus()
+	go manager.loopCollectVmStats()
 	return manager, nil
 }
 
@@ -0,0 +1,352 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"github.com/Symantec/tricorder/go/tricorder"
+	"github.com/Symantec/tricorder/go/tricorder/units"
+)
+
+const (
+	clockTicksPerSecond = 100
+	statsInterval       = 10 * time.Second
+	statsMonitorTimeout = 5 * time.Second
+	vmMetricsDirectory  = "/vms"
+)
+
+type balloonInfo struct {
+	Actual uint64 `json:"actual"`
+}
+
+type blockStats struct {
+	Device string `json:"device"`
+	Stats  struct {
+		ReadBytes       uint64 `json:"rd_bytes"`
+		ReadOperations  uint64 `json:"rd_operations"`
+		WriteBytes      uint64 `json:"wr_bytes"`
+		WriteOperations uint64 `json:"wr_operations"`
+	} `json:"stats"`
+}
+
+type cpuInfo struct {
+	ThreadId int `json:"thread-id"`
+}
+
+type statsTotals struct {
+	proto.VmStats
+	network proto.NetworkStats
+	volume  proto.VolumeStats
+}
+
+func readProcStatusField(pid int, field string) (string, error) {
+	filename := fmt.Sprintf("/proc/%d/status", pid)
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == field+":" {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: no %s field", filename, field)
+}
+
+// readProcessCpuTime returns the user and system CPU time consumed by all
+// threads of the process.
+func readProcessCpuTime(pid int) (time.Duration, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// Skip past the command name, which may contain spaces.
+	index := strings.LastIndexByte(string(data), ')')
+	if index < 0 {
+		return 0, errors.New("malformed stat file")
+	}
+	fields := strings.Fields(string(data[index+1:]))
+	if len(fields) < 13 {
+		return 0, errors.New("short stat file")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicksPerSecond,
+		nil
+}
+
+func readTapStatistic(tapDevice, name string) uint64 {
+	data, err := ioutil.ReadFile(filepath.Join("/sys/class/net", tapDevice,
+		"statistics", name))
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return value
+}
+
+func (m *Manager) getVmStats(ipAddr net.IP) (proto.VmStats, error) {
+	vm, err := m.getVmAndLock(ipAddr, false)
+	if err != nil {
+		return proto.VmStats{}, err
+	}
+	state := vm.State
+	stats := vm.stats
+	vm.mutex.RUnlock()
+	if state != proto.StateRunning {
+		return proto.VmStats{}, errors.New("VM is not running")
+	}
+	if !stats.Timestamp.IsZero() {
+		return stats, nil
+	}
+	return vm.updateStats()
+}
+
+// loopCollectVmStats will periodically collect resource usage statistics for
+// all running VMs and maintain the per-VM metrics.
+func (m *Manager) loopCollectVmStats() {
+	metricsDir, err := tricorder.RegisterDirectory(vmMetricsDirectory)
+	if err != nil {
+		m.Logger.Printf("error registering VM metrics: %s\n", err)
+		return
+	}
+	registered := make(map[string]struct{})
+	for ; ; time.Sleep(statsInterval) {
+		m.mutex.RLock()
+		vms := make([]*vmInfoType, 0, len(m.vms))
+		for _, vm := range m.vms {
+			vms = append(vms, vm)
+		}
+		m.mutex.RUnlock()
+		present := make(map[string]struct{}, len(vms))
+		for _, vm := range vms {
+			vm.mutex.RLock()
+			running := vm.State == proto.StateRunning
+			vm.mutex.RUnlock()
+			if !running {
+				continue
+			}
+			if _, err := vm.updateStats(); err != nil {
+				vm.logger.Debugf(0, "error collecting stats: %s\n", err)
+				continue
+			}
+			present[vm.ipAddress] = struct{}{}
+			if _, ok := registered[vm.ipAddress]; ok {
+				continue
+			}
+			if err := vm.registerMetrics(metricsDir); err != nil {
+				vm.logger.Printf("error registering metrics: %s\n", err)
+				continue
+			}
+			registered[vm.ipAddress] = struct{}{}
+		}
+		for ipAddr := range registered {
+			if _, ok := present[ipAddr]; !ok {
+				metricsDir.UnregisterPath(ipAddr)
+				delete(registered, ipAddr)
+			}
+		}
+	}
+}
+
+// collectStats will collect resource usage statistics for the VM from QEMU
+// and the host. The VM lock must not be held.
+func (vm *vmInfoType) collectStats() (proto.VmStats, error) {
+	stats := proto.VmStats{Timestamp: time.Now()}
+	pid, err := vm.getQemuPid()
+	if err != nil {
+		return proto.VmStats{}, err
+	}
+	if stats.CpuTime, err = readProcessCpuTime(pid); err != nil {
+		return proto.VmStats{}, err
+	}
+	if value, err := readProcStatusField(pid, "VmRSS"); err != nil {
+		return proto.VmStats{}, err
+	} else if rssKiB, err := strconv.ParseUint(value, 10, 64); err != nil {
+		return proto.VmStats{}, err
+	} else {
+		stats.RssBytes = rssKiB << 10
+	}
+	// The query fails if there is no balloon device, which is not an error.
+	data, err := vm.sendMonitorCommand("query-balloon", nil,
+		statsMonitorTimeout)
+	if err == nil {
+		var balloon balloonInfo
+		if err := json.Unmarshal(data, &balloon); err == nil {
+			stats.BalloonBytes = balloon.Actual
+		}
+	}
+	data, err = vm.sendMonitorCommand("query-blockstats", nil,
+		statsMonitorTimeout)
+	if err != nil {
+		return proto.VmStats{}, err
+	}
+	var blockStatsList []blockStats
+	if err := json.Unmarshal(data, &blockStatsList); err != nil {
+		return proto.VmStats{}, err
+	}
+	for _, block := range blockStatsList {
+		if block.Device == "" {
+			continue
+		}
+		stats.Volumes = append(stats.Volumes, proto.VolumeStats{
+			Device:          block.Device,
+			ReadBytes:       block.Stats.ReadBytes,
+			ReadOperations:  block.Stats.ReadOperations,
+			WriteBytes:      block.Stats.WriteBytes,
+			WriteOperations: block.Stats.WriteOperations,
+		})
+	}
+	vm.mutex.RLock()
+	tapDevices := vm.TapDevices
+	vm.mutex.RUnlock()
+	// The tap device receives what the VM sends and vice versa.
+	for _, tapDevice := range tapDevices {
+		stats.Networks = append(stats.Networks, proto.NetworkStats{
+			Interface:       tapDevice,
+			ReceivedBytes:   readTapStatistic(tapDevice, "tx_bytes"),
+			ReceivedPackets: readTapStatistic(tapDevice, "tx_packets"),
+			SentBytes:       readTapStatistic(tapDevice, "rx_bytes"),
+			SentPackets:     readTapStatistic(tapDevice, "rx_packets"),
+		})
+	}
+	return stats, nil
+}
+
+// getQemuPid returns the process ID of QEMU, found via a vCPU thread.
+func (vm *vmInfoType) getQemuPid() (int, error) {
+	data, err := vm.sendMonitorCommand("query-cpus-fast", nil,
+		statsMonitorTimeout)
+	if err != nil {
+		return 0, err
+	}
+	var cpus []cpuInfo
+	if err := json.Unmarshal(data, &cpus); err != nil {
+		return 0, err
+	}
+	if len(cpus) < 1 || cpus[0].ThreadId < 1 {
+		return 0, errors.New("no vCPU threads")
+	}
+	value, err := readProcStatusField(cpus[0].ThreadId, "Tgid")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// getStatsTotals returns the statistics with the network and volume counters
+// summed.
+func (vm *vmInfoType) getStatsTotals() statsTotals {
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+	totals := statsTotals{VmStats: vm.stats}
+	for _, stats := range vm.stats.Networks {
+		totals.network.ReceivedBytes += stats.ReceivedBytes
+		totals.network.ReceivedPackets += stats.ReceivedPackets
+		totals.network.SentBytes += stats.SentBytes
+		totals.network.SentPackets += stats.SentPackets
+	}
+	for _, stats := range vm.stats.Volumes {
+		totals.volume.ReadBytes += stats.ReadBytes
+		totals.volume.ReadOperations += stats.ReadOperations
+		totals.volume.WriteBytes += stats.WriteBytes
+		totals.volume.WriteOperations += stats.WriteOperations
+	}
+	return totals
+}
+
+func (vm *vmInfoType) registerMetrics(
+	metricsDir *tricorder.DirectorySpec) error {
+	dir, err := metricsDir.RegisterDirectory(vm.ipAddress)
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("owner", func() string {
+		vm.mutex.RLock()
+		defer vm.mutex.RUnlock()
+		if len(vm.OwnerUsers) < 1 {
+			return ""
+		}
+		return vm.OwnerUsers[0]
+	}, units.None, "primary owner of VM")
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("cpu-time", func() time.Duration {
+		return vm.getStatsTotals().CpuTime
+	}, units.Second, "CPU time consumed by VM")
+	if err != nil {
+		return err
+	}
+	for _, metric := range []struct {
+		name        string
+		unit        units.Unit
+		description string
+		getter      func(statsTotals) uint64
+	}{
+		{"memory/balloon", units.Byte, "balloon size",
+			func(t statsTotals) uint64 { return t.BalloonBytes }},
+		{"memory/rss", units.Byte, "resident memory of QEMU",
+			func(t statsTotals) uint64 { return t.RssBytes }},
+		{"network/received-bytes", units.Byte, "bytes received by VM",
+			func(t statsTotals) uint64 { return t.network.ReceivedBytes }},
+		{"network/received-packets", units.None, "packets received by VM",
+			func(t statsTotals) uint64 { return t.network.ReceivedPackets }},
+		{"network/sent-bytes", units.Byte, "bytes sent by VM",
+			func(t statsTotals) uint64 { return t.network.SentBytes }},
+		{"network/sent-packets", units.None, "packets sent by VM",
+			func(t statsTotals) uint64 { return t.network.SentPackets }},
+		{"storage/read-bytes", units.Byte, "bytes read from volumes",
+			func(t statsTotals) uint64 { return t.volume.ReadBytes }},
+		{"storage/read-operations", units.None, "volume read operations",
+			func(t statsTotals) uint64 { return t.volume.ReadOperations }},
+		{"storage/write-bytes", units.Byte, "bytes written to volumes",
+			func(t statsTotals) uint64 { return t.volume.WriteBytes }},
+		{"storage/write-operations", units.None, "volume write operations",
+			func(t statsTotals) uint64 { return t.volume.WriteOperations }},
+	} {
+		getter := metric.getter
+		err := dir.RegisterMetric(metric.name, func() uint64 {
+			return getter(vm.getStatsTotals())
+		}, metric.unit, metric.description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStats will collect and record the latest statistics for the VM. The
+// VM lock must not be held.
+func (vm *vmInfoType) updateStats() (proto.VmStats, error) {
+	stats, err := vm.collectStats()
+	if err != nil {
+		return proto.VmStats{}, err
+	}
+	vm.mutex.Lock()
+	vm.stats = stats
+	vm.mutex.Unlock()
+	return stats, nil
+}
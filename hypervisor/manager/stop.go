@@ -14,6 +14,9 @@ type flusher interface {
 
 func (m *Manager) shutdownVMsAndExit() {
 	var waitGroup sync.WaitGroup
+	m.mutex.Lock()
+	m.shuttingDown = true
+	m.mutex.Unlock()
 	m.mutex.RLock()
 	for _, vm := range m.vms {
 		waitGroup.Add(1)
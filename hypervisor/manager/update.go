@@ -41,6 +41,8 @@ func (m *Manager) makeUpdateChannel() <-chan proto.Update {
 		AddressPool:      m.addressPool.Registered,
 		NumFreeAddresses: numFreeAddresses,
 		HealthStatus:     m.healthStatus,
+		MemoryInMiB:      m.memTotalInMiB,
+		NumCPUs:          uint(m.numCPU),
 		HaveSerialNumber: true,
 		SerialNumber:     m.serialNumber,
 		HaveSubnets:      true,
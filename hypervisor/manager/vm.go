@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -45,8 +46,10 @@ import (
 )
 
 const (
-	bootlogFilename    = "bootlog"
-	serialSockFilename = "serial0.sock"
+	bootlogFilename       = "bootlog"
+	cloudInitSeedURL      = "http://169.254.169.254/nocloud/"
+	migrationSockFilename = "migration.sock"
+	serialSockFilename    = "serial0.sock"
 )
 
 var (
@@ -82,10 +85,10 @@ func copyData(filename string, reader io.Reader, length uint64,
 	return err
 }
 
-func createTapDevice(bridge string) (*os.File, error) {
+func createTapDevice(bridge string) (*os.File, string, error) {
 	tapFile, tapName, err := libnet.CreateTapDevice()
 	if err != nil {
-		return nil, fmt.Errorf("error creating tap device: %s", err)
+		return nil, "", fmt.Errorf("error creating tap device: %s", err)
 	}
 	doAutoClose := true
 	defer func() {
@@ -95,14 +98,14 @@ func createTapDevice(bridge string) (*os.File, error) {
 	}()
 	cmd := exec.Command("ip", "link", "set", tapName, "up")
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("error upping: %s: %s", err, output)
+		return nil, "", fmt.Errorf("error upping: %s: %s", err, output)
 	}
 	cmd = exec.Command("ip", "link", "set", tapName, "master", bridge)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("error attaching: %s: %s", err, output)
+		return nil, "", fmt.Errorf("error attaching: %s: %s", err, output)
 	}
 	doAutoClose = false
-	return tapFile, nil
+	return tapFile, tapName, nil
 }
 
 func extractKernel(volume proto.LocalVolume, extension string,
@@ -166,6 +169,18 @@ func maybeDrainUserData(conn *srpc.Conn, request proto.CreateVmRequest) error {
 	return nil
 }
 
+// numVCPUs returns the number of virtual CPUs given to QEMU for a VM.
+func numVCPUs(milliCPUs uint) uint {
+	nCpus := milliCPUs / 1000
+	if nCpus < 1 {
+		nCpus = 1
+	}
+	if nCpus*1000 < milliCPUs {
+		nCpus++
+	}
+	return nCpus
+}
+
 func readData(firstByte byte, moreBytes <-chan byte) []byte {
 	buffer := make([]byte, 1, len(moreBytes)+1)
 	buffer[0] = firstByte
@@ -210,6 +225,75 @@ func (m *Manager) acknowledgeVm(ipAddr net.IP,
 	return nil
 }
 
+func (m *Manager) addVmVolumes(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, volumes []proto.Volume) error {
+	if len(volumes) < 1 {
+		return errors.New("no volumes specified")
+	}
+	for _, volume := range volumes {
+		if volume.Size < 1 {
+			return errors.New("zero size volume specified")
+		}
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if vm.State != proto.StateStopped {
+		return errors.New("VM is not stopped")
+	}
+	if len(vm.Volumes) != len(vm.VolumeLocations) {
+		return errors.New("VM volume information is incomplete")
+	}
+	var volumeLocations []proto.LocalVolume
+	doCleanup := true
+	defer func() {
+		if doCleanup {
+			for _, volumeLocation := range volumeLocations {
+				os.Remove(volumeLocation.Filename)
+				os.Remove(volumeLocation.DirectoryToCleanup)
+			}
+		}
+	}()
+	freeSpaceTable := make(map[string]uint64, len(m.volumeDirectories))
+	position := 0
+	volumeNumber := len(vm.VolumeLocations) - 1
+	for _, volume := range volumes {
+		dirname, err := m.findFreeSpace(volume.Size, freeSpaceTable, &position)
+		if err != nil {
+			return err
+		}
+		if vm.SpreadVolumes {
+			position++
+		}
+		volumeDirectory := filepath.Join(dirname, vm.ipAddress)
+		if err := os.MkdirAll(volumeDirectory, dirPerms); err != nil {
+			return err
+		}
+		var filename string
+		for ; ; volumeNumber++ {
+			filename = filepath.Join(volumeDirectory,
+				fmt.Sprintf("secondary-volume.%d", volumeNumber))
+			if _, err := os.Lstat(filename); err != nil {
+				break
+			}
+		}
+		volumeNumber++
+		if err := createVolume(filename, volume); err != nil {
+			os.Remove(volumeDirectory)
+			return err
+		}
+		volumeLocations = append(volumeLocations,
+			proto.LocalVolume{volumeDirectory, filename})
+	}
+	doCleanup = false
+	vm.VolumeLocations = append(vm.VolumeLocations, volumeLocations...)
+	vm.Volumes = append(vm.Volumes, volumes...)
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	authInfo *srpc.AuthInformation) (*vmInfoType, error) {
 	if err := req.ConsoleType.CheckValid(); err != nil {
@@ -221,6 +305,12 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	if req.MilliCPUs < 1 {
 		return nil, errors.New("no CPUs specified")
 	}
+	if err := req.RestartPolicy.CheckValid(); err != nil {
+		return nil, err
+	}
+	if err := m.checkFirewallRules(req.FirewallRules); err != nil {
+		return nil, err
+	}
 	subnetIDs := map[string]struct{}{req.SubnetId: {}}
 	for _, subnetId := range req.SecondarySubnetIDs {
 		if subnetId == "" {
@@ -279,15 +369,20 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 		LocalVmInfo: proto.LocalVmInfo{
 			VmInfo: proto.VmInfo{
 				Address:            address,
+				CloudInitSeed:      req.CloudInitSeed,
 				ConsoleType:        req.ConsoleType,
 				DestroyProtection:  req.DestroyProtection,
 				DisableVirtIO:      req.DisableVirtIO,
+				FirewallDisabled:   m.firewallDisabled,
+				FirewallRules:      req.FirewallRules,
 				Hostname:           req.Hostname,
 				ImageName:          req.ImageName,
 				ImageURL:           req.ImageURL,
+				MaxRestarts:        req.MaxRestarts,
 				MemoryInMiB:        req.MemoryInMiB,
 				MilliCPUs:          req.MilliCPUs,
 				OwnerGroups:        req.OwnerGroups,
+				RestartPolicy:      req.RestartPolicy,
 				SpreadVolumes:      req.SpreadVolumes,
 				SecondaryAddresses: secondaryAddresses,
 				SecondarySubnetIDs: req.SecondarySubnetIDs,
@@ -384,6 +479,64 @@ func (m *Manager) changeVmOwnerUsers(ipAddr net.IP,
 	return nil
 }
 
+// changeVmSize will change the memory and CPU allocation for a VM. A running
+// VM may only be changed if QEMU does not need to be reconfigured, which means
+// the memory size and number of virtual CPUs must stay the same.
+func (m *Manager) changeVmSize(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	memoryInMiB uint64, milliCPUs uint) error {
+	ipStr := ipAddr.String()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	vm := m.vms[ipStr]
+	if vm == nil {
+		return fmt.Errorf("no VM with IP address: %s found", ipStr)
+	}
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if err := vm.checkAuth(authInfo, nil); err != nil {
+		return err
+	}
+	if memoryInMiB < 1 {
+		memoryInMiB = vm.MemoryInMiB
+	}
+	if milliCPUs < 1 {
+		milliCPUs = vm.MilliCPUs
+	}
+	if memoryInMiB == vm.MemoryInMiB && milliCPUs == vm.MilliCPUs {
+		return nil
+	}
+	switch vm.State {
+	case proto.StateStopped, proto.StateFailedToStart:
+	case proto.StateRunning:
+		if memoryInMiB != vm.MemoryInMiB {
+			return errors.New(
+				"cannot change memory of running VM, stop it first")
+		}
+		if numVCPUs(milliCPUs) != numVCPUs(vm.MilliCPUs) {
+			return errors.New(
+				"cannot change number of CPUs of running VM, stop it first")
+		}
+	default:
+		return errors.New("VM is not stopped or running")
+	}
+	if milliCPUs > vm.MilliCPUs {
+		err := m.checkSufficientCPUWithLock(milliCPUs - vm.MilliCPUs)
+		if err != nil {
+			return err
+		}
+	}
+	if memoryInMiB > vm.MemoryInMiB {
+		err := m.checkSufficientMemoryWithLock(memoryInMiB - vm.MemoryInMiB)
+		if err != nil {
+			return err
+		}
+	}
+	vm.MemoryInMiB = memoryInMiB
+	vm.MilliCPUs = milliCPUs
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) changeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
@@ -516,6 +669,9 @@ func (m *Manager) copyVm(conn *srpc.Conn, request proto.CopyVmRequest) error {
 	default:
 		return errors.New("VM is not stopped or running")
 	}
+	if len(getInfoReply.VmInfo.Snapshots) > 0 && !request.DiscardSnapshots {
+		return errors.New("VM has named snapshots which cannot be copied")
+	}
 	accessToken := request.AccessToken
 	ownerUsers := make([]string, 1, len(request.OwnerUsers)+1)
 	ownerUsers[0] = conn.Username()
@@ -526,6 +682,7 @@ func (m *Manager) copyVm(conn *srpc.Conn, request proto.CopyVmRequest) error {
 	vmInfo := request.VmInfo
 	vmInfo.Address = proto.Address{}
 	vmInfo.SecondaryAddresses = nil
+	vmInfo.Snapshots = nil
 	vmInfo.Uncommitted = false
 	vmInfo.Volumes = getInfoReply.VmInfo.Volumes
 	vm, err := m.allocateVm(proto.CreateVmRequest{VmInfo: vmInfo},
@@ -887,12 +1044,15 @@ func (m *Manager) discardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) discardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
+	authInfo *srpc.AuthInformation, name string) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
 	}
 	defer vm.mutex.Unlock()
+	if name != "" {
+		return vm.deleteSnapshot(name)
+	}
 	return vm.discardSnapshot()
 }
 
@@ -1148,6 +1308,9 @@ func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 	if !bytes.Equal(m.rootCookie, request.VerificationCookie) {
 		return fmt.Errorf("bad verification cookie: you are not root")
 	}
+	if err := m.checkFirewallRules(request.FirewallRules); err != nil {
+		return err
+	}
 	request.VmInfo.OwnerUsers = []string{authInfo.Username}
 	request.VmInfo.Uncommitted = true
 	volumeDirectories := make(map[string]struct{}, len(m.volumeDirectories))
@@ -1191,6 +1354,7 @@ func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
+	vm.VmInfo.FirewallDisabled = m.firewallDisabled
 	vm.VmInfo.State = proto.StateStarting
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -1325,6 +1489,10 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	}
 	accessToken := request.AccessToken
 	vmInfo := getInfoReply.VmInfo
+	if len(vmInfo.Snapshots) > 0 && !request.DiscardSnapshots {
+		return errors.New("VM has named snapshots which cannot be migrated")
+	}
+	vmInfo.Snapshots = nil // Snapshot copies of volumes are not migrated.
 	if subnetId != vmInfo.SubnetId {
 		return fmt.Errorf("subnet ID changing from: %s to: %s",
 			vmInfo.SubnetId, subnetId)
@@ -1354,16 +1522,21 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
+	vm.FirewallDisabled = m.firewallDisabled
 	vm.Uncommitted = true
+	// For a running VM, the source VM is paused while memoryConn is open.
+	var memoryConn *srpc.Conn
 	defer func() { // Evaluate vm at return time, not defer time.
 		if vm == nil {
 			return
 		}
+		if memoryConn != nil {
+			abandonLiveMigration(memoryConn)
+		}
 		vm.cleanup()
-		hyperclient.PrepareVmForMigration(hypervisor, request.IpAddress,
-			accessToken, false)
-		if vmInfo.State == proto.StateRunning {
-			hyperclient.StartVm(hypervisor, request.IpAddress, accessToken)
+		if vmInfo.State == proto.StateStopped {
+			hyperclient.PrepareVmForMigration(hypervisor, request.IpAddress,
+				accessToken, false)
 		}
 	}()
 	vm.ownerUsers = make(map[string]struct{}, len(vm.OwnerUsers))
@@ -1406,50 +1579,33 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if err != nil {
 		return err
 	}
-	if vmInfo.State != proto.StateStopped {
-		err = sendVmMigrationMessage(conn, "stopping VM")
-		if err != nil {
-			return err
-		}
-		err := hyperclient.StopVm(hypervisor, request.IpAddress,
-			request.AccessToken)
-		if err != nil {
+	err = migratevmUserData(hypervisor,
+		filepath.Join(vm.dirname, "user-data.raw"),
+		request.IpAddress, accessToken)
+	if err != nil {
+		return err
+	}
+	if vmInfo.State == proto.StateStopped {
+		if err := sendVmMigrationMessage(conn, "starting VM"); err != nil {
 			return err
 		}
-		err = hyperclient.PrepareVmForMigration(hypervisor, request.IpAddress,
-			request.AccessToken, true)
+		vm.State = proto.StateStarting
+		m.mutex.Lock()
+		m.vms[ipAddress] = vm
+		m.mutex.Unlock()
+		dhcpTimedOut, err := vm.startManaging(request.DhcpTimeout, false)
 		if err != nil {
 			return err
 		}
-		err = sendVmMigrationMessage(conn, "update volume(s)")
-		if err != nil {
-			return err
+		if dhcpTimedOut {
+			return fmt.Errorf("DHCP timed out")
 		}
-		err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress, accessToken)
+	} else {
+		memoryConn, err = m.migrateVmLive(conn, hypervisor, vm, accessToken)
 		if err != nil {
 			return err
 		}
 	}
-	err = migratevmUserData(hypervisor,
-		filepath.Join(vm.dirname, "user-data.raw"),
-		request.IpAddress, accessToken)
-	if err != nil {
-		return err
-	}
-	if err := sendVmMigrationMessage(conn, "starting VM"); err != nil {
-		return err
-	}
-	vm.State = proto.StateStarting
-	m.mutex.Lock()
-	m.vms[ipAddress] = vm
-	m.mutex.Unlock()
-	dhcpTimedOut, err := vm.startManaging(request.DhcpTimeout, false)
-	if err != nil {
-		return err
-	}
-	if dhcpTimedOut {
-		return fmt.Errorf("DHCP timed out")
-	}
 	err = conn.Encode(proto.MigrateVmResponse{RequestCommit: true})
 	if err != nil {
 		return err
@@ -1464,13 +1620,30 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if !reply.Commit {
 		return fmt.Errorf("VM migration abandoned")
 	}
-	if err := m.registerAddress(vm.Address); err != nil {
-		return err
-	}
-	for _, address := range vm.SecondaryAddresses {
-		if err := m.registerAddress(address); err != nil {
+	if memoryConn != nil {
+		sourceConn := memoryConn
+		memoryConn = nil
+		if !vm.commitLiveMigration(sourceConn, hypervisor) {
+			return errors.New("source VM not stopped, migration abandoned")
+		}
+		// The VM now only exists here, so errors must not clean it up.
+		if err := m.registerAddress(vm.Address); err != nil {
+			vm.logger.Println(err)
+		}
+		for _, address := range vm.SecondaryAddresses {
+			if err := m.registerAddress(address); err != nil {
+				vm.logger.Println(err)
+			}
+		}
+	} else {
+		if err := m.registerAddress(vm.Address); err != nil {
 			return err
 		}
+		for _, address := range vm.SecondaryAddresses {
+			if err := m.registerAddress(address); err != nil {
+				return err
+			}
+		}
 	}
 	vm.doNotWriteOrSend = false
 	vm.Uncommitted = false
@@ -2065,8 +2238,71 @@ func (m *Manager) replaceVmUserData(ipAddr net.IP, reader io.Reader,
 	return nil
 }
 
+// resizeVmVolume will grow a volume. If the VM is running the new size is
+// sent to QEMU, which grows the volume and makes the new size visible to the
+// VM.
+func (m *Manager) resizeVmVolume(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, accessToken []byte, volumeIndex uint,
+	size uint64) error {
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if volumeIndex >= uint(len(vm.VolumeLocations)) ||
+		volumeIndex >= uint(len(vm.Volumes)) {
+		return errors.New("volume index too large")
+	}
+	volume := vm.Volumes[volumeIndex]
+	if size < volume.Size {
+		return errors.New("cannot shrink volume")
+	}
+	if size == volume.Size {
+		return nil
+	}
+	volumeLocation := vm.VolumeLocations[volumeIndex]
+	freeSpace, err := getFreeSpace(volumeLocation.DirectoryToCleanup,
+		make(map[string]uint64, 1))
+	if err != nil {
+		return err
+	}
+	if size-volume.Size >= freeSpace {
+		return fmt.Errorf("not enough free space to grow volume by %s",
+			format.FormatBytes(size-volume.Size))
+	}
+	switch vm.State {
+	case proto.StateStopped:
+		err := growVolume(volumeLocation.Filename, volume, size)
+		if err != nil {
+			return err
+		}
+	case proto.StateRunning:
+		// The VM lock is held while waiting, so that the size is only recorded
+		// if QEMU succeeded and no other change can intervene.
+		id, responseChannel, err := vm.queueMonitorCommand("block_resize",
+			map[string]interface{}{
+				"device": vm.driveId(volumeIndex),
+				"size":   size,
+			})
+		if err != nil {
+			return err
+		}
+		_, err = vm.waitMonitorResponse(id, responseChannel,
+			monitorCommandTimeout)
+		if err != nil {
+			return fmt.Errorf("error resizing volume: %s", err)
+		}
+	default:
+		return errors.New("VM is not stopped or running")
+	}
+	vm.Volumes[volumeIndex].Size = size
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	name string) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
@@ -2077,6 +2313,9 @@ func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
 			return errors.New("VM is not stopped")
 		}
 	}
+	if name != "" {
+		return vm.restoreSnapshot(name)
+	}
 	for _, volume := range vm.VolumeLocations {
 		snapshotFilename := volume.Filename + ".snapshot"
 		if err := os.Rename(snapshotFilename, volume.Filename); err != nil {
@@ -2129,6 +2368,7 @@ func (m *Manager) restoreVmUserData(ipAddr net.IP,
 }
 
 func (m *Manager) sendVmInfo(ipAddress string, vm *proto.VmInfo) {
+	m.requestFirewallUpdate()
 	if ipAddress != "0.0.0.0" {
 		if vm == nil { // GOB cannot encode a nil value in a map.
 			vm = new(proto.VmInfo)
@@ -2141,7 +2381,7 @@ func (m *Manager) sendVmInfo(ipAddress string, vm *proto.VmInfo) {
 }
 
 func (m *Manager) snapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
+	forceIfNotStopped, snapshotRootOnly bool, name string) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
@@ -2153,6 +2393,9 @@ func (m *Manager) snapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
 			return errors.New("VM is not stopped")
 		}
 	}
+	if name != "" {
+		return vm.createSnapshot(name, snapshotRootOnly)
+	}
 	if err := vm.discardSnapshot(); err != nil {
 		return err
 	}
@@ -2165,9 +2408,7 @@ func (m *Manager) snapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	for index, volume := range vm.VolumeLocations {
 		snapshotFilename := volume.Filename + ".snapshot"
 		if index == 0 || !snapshotRootOnly {
-			err := fsutil.CopyFile(snapshotFilename, volume.Filename,
-				privateFilePerms)
-			if err != nil {
+			if err := cloneFile(snapshotFilename, volume.Filename); err != nil {
 				return err
 			}
 		}
@@ -2473,6 +2714,14 @@ func (vm *vmInfoType) discardSnapshot() error {
 	return nil
 }
 
+// driveId returns the identifier QEMU assigns to the drive for a volume.
+func (vm *vmInfoType) driveId(volumeIndex uint) string {
+	if vm.DisableVirtIO {
+		return fmt.Sprintf("ide%d-hd%d", volumeIndex/2, volumeIndex%2)
+	}
+	return fmt.Sprintf("virtio%d", volumeIndex)
+}
+
 func (vm *vmInfoType) getActiveInitrdPath() string {
 	initrdPath := vm.getInitrdPath()
 	if _, err := os.Stat(initrdPath); err == nil {
@@ -2514,7 +2763,10 @@ func (vm *vmInfoType) monitor(monitorSock net.Conn,
 	go vm.probeHealthAgent(cancelChannel)
 	go vm.serialManager()
 	for command := range commandChannel {
-		_, err := fmt.Fprintf(monitorSock, `{"execute":"%s"}`, command)
+		if !strings.HasPrefix(command, "{") {
+			command = fmt.Sprintf(`{"execute":"%s"}`, command)
+		}
+		_, err := io.WriteString(monitorSock, command)
 		if err != nil {
 			vm.logger.Println(err)
 		} else {
@@ -2550,7 +2802,7 @@ func (vm *vmInfoType) probeHealthAgent(cancel <-chan struct{}) {
 }
 
 func (vm *vmInfoType) processMonitorResponses(monitorSock net.Conn) {
-	io.Copy(ioutil.Discard, monitorSock) // Read all and drop.
+	guestShutdown := vm.processMonitorOutput(monitorSock)
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
 	close(vm.commandChannel)
@@ -2567,6 +2819,7 @@ func (vm *vmInfoType) processMonitorResponses(monitorSock net.Conn) {
 		case vm.stoppedNotifier <- struct{}{}:
 		default:
 		}
+		go vm.handleUnexpectedExit(guestShutdown)
 		return
 	case proto.StateFailedToStart:
 		return
@@ -2720,7 +2973,7 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 	if err != nil {
 		vm.logger.Debugf(1, "error connecting to: %s: %s\n",
 			vm.monitorSockname, err)
-		if err := vm.startVm(haveManagerLock); err != nil {
+		if err := vm.startVm(haveManagerLock, false); err != nil {
 			vm.logger.Println(err)
 			vm.setState(proto.StateFailedToStart)
 			return false, err
@@ -2736,6 +2989,10 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 	vm.commandChannel = commandChannel
 	go vm.monitor(monitorSock, commandChannel)
 	commandChannel <- "qmp_capabilities"
+	if vm.CrashInfo != nil {
+		vm.CrashInfo.RestartPending = false
+	}
+	vm.startTime = time.Now()
 	vm.setState(proto.StateRunning)
 	if len(vm.Address.IpAddress) < 1 {
 		// Must wait to see what IP address is given by external DHCP server.
@@ -2811,30 +3068,29 @@ func (vm *vmInfoType) getBridgesAndOptions(haveManagerLock bool) (
 	return bridges, options, nil
 }
 
-func (vm *vmInfoType) startVm(haveManagerLock bool) error {
+// startVm will start QEMU. If incoming is true, QEMU will wait for the VM
+// state to be migrated in from another QEMU.
+func (vm *vmInfoType) startVm(haveManagerLock, incoming bool) error {
 	if err := checkAvailableMemory(vm.MemoryInMiB); err != nil {
 		return err
 	}
-	nCpus := vm.MilliCPUs / 1000
-	if nCpus < 1 {
-		nCpus = 1
-	}
-	if nCpus*1000 < vm.MilliCPUs {
-		nCpus++
-	}
+	nCpus := numVCPUs(vm.MilliCPUs)
 	bridges, netOptions, err := vm.getBridgesAndOptions(haveManagerLock)
 	if err != nil {
 		return err
 	}
 	var tapFiles []*os.File
+	var tapDevices []string
 	for _, bridge := range bridges {
-		tapFile, err := createTapDevice(bridge)
+		tapFile, tapName, err := createTapDevice(bridge)
 		if err != nil {
 			return fmt.Errorf("error creating tap device: %s", err)
 		}
 		defer tapFile.Close()
 		tapFiles = append(tapFiles, tapFile)
+		tapDevices = append(tapDevices, tapName)
 	}
+	vm.TapDevices = tapDevices
 	cmd := exec.Command("qemu-system-x86_64", "-machine", "pc,accel=kvm",
 		"-cpu", "host", // Allow the VM to take full advantage of host CPU.
 		"-nodefaults",
@@ -2847,6 +3103,11 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 		"-runas", vm.manager.Username,
 		"-qmp", "unix:"+vm.monitorSockname+",server,nowait",
 		"-daemonize")
+	if vm.CloudInitSeed {
+		// Point cloud-init in unmodified images to the metadata server.
+		cmd.Args = append(cmd.Args,
+			"-smbios", "type=1,serial=ds=nocloud-net;s="+cloudInitSeedURL)
+	}
 	if kernelPath := vm.getActiveKernelPath(); kernelPath != "" {
 		cmd.Args = append(cmd.Args, "-kernel", kernelPath)
 		if initrdPath := vm.getActiveInitrdPath(); initrdPath != "" {
@@ -2862,6 +3123,10 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 		}
 	}
 	cmd.Args = append(cmd.Args, netOptions...)
+	if incoming {
+		cmd.Args = append(cmd.Args, "-S", "-incoming",
+			"unix:"+filepath.Join(vm.dirname, migrationSockFilename))
+	}
 	if vm.manager.ShowVgaConsole {
 		cmd.Args = append(cmd.Args, "-vga", "std")
 	} else {
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -24,6 +26,30 @@ type mountInfo struct {
 	size       uint64
 }
 
+// createVolume will create an empty volume file.
+func createVolume(filename string, volume proto.Volume) error {
+	switch volume.Format {
+	case proto.VolumeFormatRaw:
+		cFlags := os.O_CREATE | os.O_EXCL | os.O_RDWR
+		file, err := os.OpenFile(filename, cFlags, privateFilePerms)
+		if err != nil {
+			return err
+		}
+		file.Close()
+		return setVolumeSize(filename, volume.Size)
+	case proto.VolumeFormatQCOW2:
+		err := runQemuImg("create", "-f", "qcow2", filename,
+			strconv.FormatUint(volume.Size, 10))
+		if err != nil {
+			return err
+		}
+		return os.Chmod(filename, privateFilePerms)
+	default:
+		return errors.New("unsupported volume format: " +
+			volume.Format.String())
+	}
+}
+
 func getFreeSpace(dirname string, freeSpaceTable map[string]uint64) (
 	uint64, error) {
 	if freeSpace, ok := freeSpaceTable[dirname]; ok {
@@ -131,6 +157,28 @@ func getVolumeDirectories() ([]string, error) {
 	return volumeDirectories, nil
 }
 
+// growVolume will grow the volume file for a stopped VM.
+func growVolume(filename string, volume proto.Volume, size uint64) error {
+	switch volume.Format {
+	case proto.VolumeFormatRaw:
+		return setVolumeSize(filename, size)
+	case proto.VolumeFormatQCOW2:
+		return runQemuImg("resize", "-f", "qcow2", filename,
+			strconv.FormatUint(size, 10))
+	default:
+		return errors.New("unsupported volume format: " +
+			volume.Format.String())
+	}
+}
+
+func runQemuImg(args ...string) error {
+	cmd := exec.Command("qemu-img", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running qemu-img: %s: %s", err, output)
+	}
+	return nil
+}
+
 func (m *Manager) findFreeSpace(size uint64, freeSpaceTable map[string]uint64,
 	position *int) (string, error) {
 	if *position >= len(m.volumeDirectories) {
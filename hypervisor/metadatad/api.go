@@ -32,12 +32,29 @@ func StartServer(hypervisorPortNum uint, bridges []net.Interface,
 		logger:            logger,
 	}
 	s.infoHandlers = map[string]metadataWriter{
-		"/latest/dynamic/epoch-time":                 s.showTime,
-		"/latest/dynamic/instance-identity/document": s.showVM,
+		"/latest/dynamic/epoch-time":                  s.showTime,
+		"/latest/dynamic/instance-identity/document":  s.showVM,
+		"/latest/meta-data/hostname":                  s.showHostname,
+		"/latest/meta-data/instance-id":               s.showInstanceId,
+		"/latest/meta-data/local-hostname":            s.showHostname,
+		"/latest/meta-data/local-ipv4":                s.showLocalIpv4,
+		"/latest/meta-data/mac":                       s.showMac,
+		"/latest/meta-data/public-keys/0/openssh-key": s.showPublicKey,
+		"/nocloud/meta-data":                          s.showNoCloudMetadata,
+		"/nocloud/network-config":                     s.showNetworkConfig,
+		"/nocloud/vendor-data":                        s.showVendorData,
+		"/openstack/latest/meta_data.json":            s.showOpenStackMetadata,
+		"/openstack/latest/network_data.json":         s.showNetworkData,
+		"/openstack/latest/vendor_data.json":          s.showVendorDataJson,
 	}
 	s.rawHandlers = map[string]rawHandlerFunc{
-		"/datasource/SmallStack": s.showSmallStack,
-		"/latest/user-data":      s.showUserData,
+		"/datasource/SmallStack":      s.showSmallStack,
+		"/identity/ca.pem":            s.showIdentityCa,
+		"/identity/cert.pem":          s.showIdentityCert,
+		"/identity/key.pem":           s.showIdentityKey,
+		"/latest/user-data":           s.showUserData,
+		"/nocloud/user-data":          s.showUserDataOrEmpty,
+		"/openstack/latest/user_data": s.showUserData,
 	}
 	s.computePaths()
 	return s.startServer()
@@ -0,0 +1,310 @@
+package metadatad
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/Symantec/Dominator/lib/json"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const sshPublicKeyTag = "SshPublicKey"
+
+type interfaceInfo struct {
+	address proto.Address
+	name    string
+	subnet  *proto.Subnet
+}
+
+// The network-config and meta-data files are YAML for NoCloud, but since
+// JSON is a subset of YAML they are written as JSON.
+
+type netConfigEthernet struct {
+	Addresses   []string             `json:"addresses,omitempty"`
+	Dhcp4       bool                 `json:"dhcp4,omitempty"`
+	Gateway4    string               `json:"gateway4,omitempty"`
+	Match       netConfigMatch       `json:"match"`
+	Nameservers *netConfigNameserver `json:"nameservers,omitempty"`
+	SetName     string               `json:"set-name"`
+}
+
+type netConfigMatch struct {
+	MacAddress string `json:"macaddress"`
+}
+
+type netConfigNameserver struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Search    []string `json:"search,omitempty"`
+}
+
+type netConfigV2 struct {
+	Ethernets map[string]netConfigEthernet `json:"ethernets"`
+	Version   uint                         `json:"version"`
+}
+
+type openStackLink struct {
+	EthernetMacAddress string `json:"ethernet_mac_address"`
+	Id                 string `json:"id"`
+	Type               string `json:"type"`
+}
+
+type openStackMetadata struct {
+	AvailabilityZone string            `json:"availability_zone"`
+	Hostname         string            `json:"hostname"`
+	Meta             map[string]string `json:"meta,omitempty"`
+	Name             string            `json:"name"`
+	PublicKeys       map[string]string `json:"public_keys,omitempty"`
+	Uuid             string            `json:"uuid"`
+}
+
+type openStackNetwork struct {
+	Id        string           `json:"id"`
+	IpAddress string           `json:"ip_address,omitempty"`
+	Link      string           `json:"link"`
+	Netmask   string           `json:"netmask,omitempty"`
+	NetworkId string           `json:"network_id"`
+	Routes    []openStackRoute `json:"routes,omitempty"`
+	Type      string           `json:"type"`
+}
+
+type openStackNetworkData struct {
+	Links    []openStackLink    `json:"links"`
+	Networks []openStackNetwork `json:"networks"`
+	Services []openStackService `json:"services"`
+}
+
+type openStackRoute struct {
+	Gateway string `json:"gateway"`
+	Netmask string `json:"netmask"`
+	Network string `json:"network"`
+}
+
+type openStackService struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+}
+
+func getHostname(vmInfo proto.VmInfo) string {
+	if vmInfo.Hostname != "" {
+		return vmInfo.Hostname
+	}
+	if ip := vmInfo.Address.IpAddress.To4(); ip != nil {
+		return fmt.Sprintf("ip-%d-%d-%d-%d", ip[0], ip[1], ip[2], ip[3])
+	}
+	return getInstanceId(vmInfo)
+}
+
+// getInstanceId returns an instance ID derived from the MAC address, which is
+// stable across migrations.
+func getInstanceId(vmInfo proto.VmInfo) string {
+	hwAddr, err := net.ParseMAC(vmInfo.Address.MacAddress)
+	if err != nil {
+		return "i-" + vmInfo.Address.MacAddress
+	}
+	return fmt.Sprintf("i-%x", []byte(hwAddr))
+}
+
+func maskLength(subnet *proto.Subnet) int {
+	ones, _ := net.IPMask(subnet.IpMask.To4()).Size()
+	return ones
+}
+
+func (s *server) getInterfaces(vmInfo proto.VmInfo) []interfaceInfo {
+	subnets := make(map[string]*proto.Subnet)
+	var subnetList []*proto.Subnet
+	for _, subnet := range s.manager.ListSubnets(false) {
+		subnet := subnet
+		subnets[subnet.Id] = &subnet
+		subnetList = append(subnetList, &subnet)
+	}
+	findSubnet := func(subnetId string, ipAddr net.IP) *proto.Subnet {
+		if subnet, ok := subnets[subnetId]; ok {
+			return subnet
+		}
+		for _, subnet := range subnetList {
+			mask := net.IPMask(subnet.IpMask.To4())
+			if ipAddr.Mask(mask).Equal(subnet.IpGateway.Mask(mask)) {
+				return subnet
+			}
+		}
+		return nil
+	}
+	interfaces := make([]interfaceInfo, 0, len(vmInfo.SecondaryAddresses)+1)
+	interfaces = append(interfaces, interfaceInfo{
+		address: vmInfo.Address,
+		name:    "eth0",
+		subnet:  findSubnet(vmInfo.SubnetId, vmInfo.Address.IpAddress),
+	})
+	for index, address := range vmInfo.SecondaryAddresses {
+		var subnetId string
+		if index < len(vmInfo.SecondarySubnetIDs) {
+			subnetId = vmInfo.SecondarySubnetIDs[index]
+		}
+		interfaces = append(interfaces, interfaceInfo{
+			address: address,
+			name:    fmt.Sprintf("eth%d", index+1),
+			subnet:  findSubnet(subnetId, address.IpAddress),
+		})
+	}
+	return interfaces
+}
+
+func (s *server) showHostname(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, getHostname(vmInfo))
+	return err
+}
+
+func (s *server) showInstanceId(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, getInstanceId(vmInfo))
+	return err
+}
+
+func (s *server) showLocalIpv4(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, vmInfo.Address.IpAddress)
+	return err
+}
+
+func (s *server) showMac(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, vmInfo.Address.MacAddress)
+	return err
+}
+
+func (s *server) showNoCloudMetadata(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	metadata := map[string]string{
+		"instance-id":    getInstanceId(vmInfo),
+		"local-hostname": getHostname(vmInfo),
+	}
+	if key := vmInfo.Tags[sshPublicKeyTag]; key != "" {
+		metadata["public-keys"] = key
+	}
+	return json.WriteWithIndent(writer, "    ", metadata)
+}
+
+func (s *server) showNetworkConfig(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	config := netConfigV2{
+		Ethernets: make(map[string]netConfigEthernet),
+		Version:   2,
+	}
+	for _, iface := range s.getInterfaces(vmInfo) {
+		ethernet := netConfigEthernet{
+			Match:   netConfigMatch{iface.address.MacAddress},
+			SetName: iface.name,
+		}
+		if len(iface.address.IpAddress) < 1 || iface.subnet == nil {
+			ethernet.Dhcp4 = true
+		} else {
+			ethernet.Addresses = []string{fmt.Sprintf("%s/%d",
+				iface.address.IpAddress, maskLength(iface.subnet))}
+			if iface.name == "eth0" {
+				ethernet.Gateway4 = iface.subnet.IpGateway.String()
+			}
+			nameservers := &netConfigNameserver{}
+			for _, ipAddr := range iface.subnet.DomainNameServers {
+				nameservers.Addresses = append(nameservers.Addresses,
+					ipAddr.String())
+			}
+			if iface.subnet.DomainName != "" {
+				nameservers.Search = []string{iface.subnet.DomainName}
+			}
+			if len(nameservers.Addresses) > 0 {
+				ethernet.Nameservers = nameservers
+			}
+		}
+		config.Ethernets[iface.name] = ethernet
+	}
+	return json.WriteWithIndent(writer, "    ", config)
+}
+
+func (s *server) showOpenStackMetadata(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	metadata := openStackMetadata{
+		AvailabilityZone: "SmallStack",
+		Hostname:         getHostname(vmInfo),
+		Meta:             vmInfo.Tags,
+		Name:             getHostname(vmInfo),
+		Uuid:             getInstanceId(vmInfo),
+	}
+	if key := vmInfo.Tags[sshPublicKeyTag]; key != "" {
+		metadata.PublicKeys = map[string]string{"default": key}
+	}
+	return json.WriteWithIndent(writer, "    ", metadata)
+}
+
+func (s *server) showNetworkData(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	data := openStackNetworkData{
+		Links:    make([]openStackLink, 0),
+		Networks: make([]openStackNetwork, 0),
+		Services: make([]openStackService, 0),
+	}
+	dnsServers := make(map[string]struct{})
+	for index, iface := range s.getInterfaces(vmInfo) {
+		data.Links = append(data.Links, openStackLink{
+			EthernetMacAddress: iface.address.MacAddress,
+			Id:                 iface.name,
+			Type:               "phy",
+		})
+		network := openStackNetwork{
+			Id:   fmt.Sprintf("network%d", index),
+			Link: iface.name,
+			Type: "ipv4_dhcp",
+		}
+		if len(iface.address.IpAddress) > 0 && iface.subnet != nil {
+			network.IpAddress = iface.address.IpAddress.String()
+			network.Netmask = iface.subnet.IpMask.String()
+			network.NetworkId = iface.subnet.Id
+			network.Type = "ipv4"
+			if index == 0 {
+				network.Routes = []openStackRoute{{
+					Gateway: iface.subnet.IpGateway.String(),
+					Netmask: "0.0.0.0",
+					Network: "0.0.0.0",
+				}}
+			}
+			for _, ipAddr := range iface.subnet.DomainNameServers {
+				address := ipAddr.String()
+				if _, ok := dnsServers[address]; !ok {
+					dnsServers[address] = struct{}{}
+					data.Services = append(data.Services,
+						openStackService{address, "dns"})
+				}
+			}
+		}
+		data.Networks = append(data.Networks, network)
+	}
+	return json.WriteWithIndent(writer, "    ", data)
+}
+
+func (s *server) showVendorDataJson(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, "{}")
+	return err
+}
+
+func (s *server) showPublicKey(writer io.Writer, vmInfo proto.VmInfo) error {
+	if key := vmInfo.Tags[sshPublicKeyTag]; key != "" {
+		_, err := fmt.Fprintln(writer, key)
+		return err
+	}
+	return nil
+}
+
+func (s *server) showVendorData(writer io.Writer, vmInfo proto.VmInfo) error {
+	return nil
+}
+
+// showUserDataOrEmpty is used for NoCloud, which requires the user-data file
+// to exist.
+func (s *server) showUserDataOrEmpty(w http.ResponseWriter, ipAddr net.IP) {
+	if file, err := s.manager.GetVmUserData(ipAddr); err == nil {
+		defer file.Close()
+		writer := bufio.NewWriter(w)
+		defer writer.Flush()
+		io.Copy(writer, file)
+	}
+}
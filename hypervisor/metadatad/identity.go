@@ -0,0 +1,42 @@
+package metadatad
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+)
+
+// writeIdentity writes the selected part of the VM identity. Since the
+// server identifies the VM by the source IP address, a VM can only obtain
+// its own identity.
+func (s *server) writeIdentity(w http.ResponseWriter, ipAddr net.IP,
+	selector func(certPEM, keyPEM []byte) []byte) {
+	certPEM, keyPEM, err := s.manager.GetVmIdentityCertificate(ipAddr)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		s.logger.Debugf(0, "%s: %s\n", ipAddr, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(selector(certPEM, keyPEM))
+}
+
+func (s *server) showIdentityCa(w http.ResponseWriter, ipAddr net.IP) {
+	s.writeIdentity(w, ipAddr, func(certPEM, keyPEM []byte) []byte {
+		_, rest := pem.Decode(certPEM)
+		return rest
+	})
+}
+
+func (s *server) showIdentityCert(w http.ResponseWriter, ipAddr net.IP) {
+	s.writeIdentity(w, ipAddr, func(certPEM, keyPEM []byte) []byte {
+		return certPEM
+	})
+}
+
+func (s *server) showIdentityKey(w http.ResponseWriter, ipAddr net.IP) {
+	s.writeIdentity(w, ipAddr, func(certPEM, keyPEM []byte) []byte {
+		return keyPEM
+	})
+}
@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) AddVmVolumes(conn *srpc.Conn,
+	request hypervisor.AddVmVolumesRequest,
+	reply *hypervisor.AddVmVolumesResponse) error {
+	*reply = hypervisor.AddVmVolumesResponse{
+		errors.ErrorToString(t.manager.AddVmVolumes(request.IpAddress,
+			conn.GetAuthInformation(), request.AccessToken,
+			request.Volumes))}
+	return nil
+}
@@ -51,10 +51,14 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 	srpc.RegisterNameWithOptions("Hypervisor", srpcObj, srpc.ReceiverOptions{
 		PublicMethods: []string{
 			"AcknowledgeVm",
+			"AddVmVolumes",
 			"BecomePrimaryVmOwner",
 			"ChangeVmConsoleType",
 			"ChangeVmDestroyProtection",
+			"ChangeVmFirewallRules",
 			"ChangeVmOwnerUsers",
+			"ChangeVmRestartPolicy",
+			"ChangeVmSize",
 			"ChangeVmTags",
 			"CommitImportedVm",
 			"ConnectToVmConsole",
@@ -71,16 +75,20 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 			"GetUpdates",
 			"GetVmAccessToken",
 			"GetVmInfo",
+			"GetVmMemory",
+			"GetVmStats",
 			"GetVmUserData",
 			"GetVmVolume",
 			"ImportLocalVm",
 			"ListVMs",
+			"ListVmSnapshots",
 			"ListVolumeDirectories",
 			"MigrateVm",
 			"PatchVmImage",
 			"ProbeVmPort",
 			"ReplaceVmImage",
 			"ReplaceVmUserData",
+			"ResizeVmVolume",
 			"RestoreVmFromSnapshot",
 			"RestoreVmImage",
 			"RestoreVmUserData",
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmFirewallRules(conn *srpc.Conn,
+	request hypervisor.ChangeVmFirewallRulesRequest,
+	reply *hypervisor.ChangeVmFirewallRulesResponse) error {
+	response := hypervisor.ChangeVmFirewallRulesResponse{
+		errors.ErrorToString(
+			t.manager.ChangeVmFirewallRules(request.IpAddress,
+				conn.GetAuthInformation(), request.FirewallRules))}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmRestartPolicy(conn *srpc.Conn,
+	request hypervisor.ChangeVmRestartPolicyRequest,
+	reply *hypervisor.ChangeVmRestartPolicyResponse) error {
+	response := hypervisor.ChangeVmRestartPolicyResponse{
+		errors.ErrorToString(
+			t.manager.ChangeVmRestartPolicy(request.IpAddress,
+				conn.GetAuthInformation(), request.RestartPolicy,
+				request.MaxRestarts))}
+	*reply = response
+	return nil
+}
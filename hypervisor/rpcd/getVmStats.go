@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) GetVmStats(conn *srpc.Conn,
+	request hypervisor.GetVmStatsRequest,
+	reply *hypervisor.GetVmStatsResponse) error {
+	stats, err := t.manager.GetVmStats(request.IpAddress)
+	*reply = hypervisor.GetVmStatsResponse{
+		Error: errors.ErrorToString(err),
+		Stats: stats,
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ListVmSnapshots(conn *srpc.Conn,
+	request hypervisor.ListVmSnapshotsRequest,
+	reply *hypervisor.ListVmSnapshotsResponse) error {
+	snapshots, err := t.manager.ListVmSnapshots(request.IpAddress)
+	*reply = hypervisor.ListVmSnapshotsResponse{
+		Error:     errors.ErrorToString(err),
+		Snapshots: snapshots,
+	}
+	return nil
+}
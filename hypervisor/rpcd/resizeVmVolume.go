@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ResizeVmVolume(conn *srpc.Conn,
+	request hypervisor.ResizeVmVolumeRequest,
+	reply *hypervisor.ResizeVmVolumeResponse) error {
+	*reply = hypervisor.ResizeVmVolumeResponse{
+		errors.ErrorToString(t.manager.ResizeVmVolume(request.IpAddress,
+			conn.GetAuthInformation(), request.AccessToken,
+			request.VolumeIndex, request.VolumeSize))}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/sbom"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
 	proto "github.com/Symantec/Dominator/proto/imaginator"
@@ -61,6 +62,27 @@ func addImage(client *srpc.Client, request proto.BuildImageRequest,
 	return name, nil
 }
 
+// addSbom will generate a software bill of materials for the file-system tree
+// rooted at rootDir and upload it. If there is no package database, nil is
+// returned.
+func addSbom(objClient *objectclient.ObjectClient, name, rootDir string,
+	fs *filesystem.FileSystem) (*image.Annotation, error) {
+	doc, err := sbom.Generate(name, rootDir)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	doc.AddFileHashes(fs)
+	buffer := &bytes.Buffer{}
+	if err := doc.Encode(buffer); err != nil {
+		return nil, err
+	}
+	hashVal, _, err := objClient.AddObject(buffer, uint64(buffer.Len()), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &image.Annotation{Object: &hashVal}, nil
+}
+
 func buildFileSystem(client *srpc.Client, dirname string,
 	scanFilter *filter.Filter) (
 	*filesystem.FileSystem, error) {
@@ -92,9 +114,10 @@ func buildFileSystemWithHasher(dirname string, h *hasher,
 	return &fs.FileSystem, nil
 }
 
-func listPackages(rootDir string) ([]image.Package, error) {
+func listPackages(rootDir string,
+	cgroup *buildCgroup) ([]image.Package, error) {
 	output := new(bytes.Buffer)
-	err := runInTarget(nil, output, rootDir, packagerPathname,
+	err := runInTarget(nil, output, rootDir, cgroup, nil, packagerPathname,
 		"show-size-multiplier")
 	if err != nil {
 		return nil, fmt.Errorf("error getting size multiplier: %s", err)
@@ -110,7 +133,8 @@ func listPackages(rootDir string) ([]image.Package, error) {
 		return nil, errors.New("malformed size multiplier")
 	}
 	output.Reset()
-	err = runInTarget(nil, output, rootDir, packagerPathname, "list")
+	err = runInTarget(nil, output, rootDir, cgroup, nil, packagerPathname,
+		"list")
 	if err != nil {
 		return nil, err
 	}
@@ -148,10 +172,11 @@ func listPackages(rootDir string) ([]image.Package, error) {
 }
 
 func packImage(client *srpc.Client, request proto.BuildImageRequest,
-	dirname string, scanFilter *filter.Filter,
+	dirname string, cgroup *buildCgroup, scanFilter *filter.Filter,
 	computedFilesList []util.ComputedFile, imageFilter *filter.Filter,
-	trig *triggers.Triggers, buildLog buildLogger) (*image.Image, error) {
-	packages, err := listPackages(dirname)
+	trig *triggers.Triggers, testResults []image.TestResult,
+	sourceDateEpoch time.Time, buildLog buildLogger) (*image.Image, error) {
+	packages, err := listPackages(dirname, cgroup)
 	if err != nil {
 		return nil, fmt.Errorf("error listing packages: %s", err)
 	}
@@ -160,6 +185,9 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	if err != nil {
 		return nil, fmt.Errorf("error building file-system: %s", err)
 	}
+	if !sourceDateEpoch.IsZero() {
+		renumberInodes(fs)
+	}
 	if err := util.SpliceComputedFiles(fs, computedFilesList); err != nil {
 		return nil, fmt.Errorf("error splicing computed files: %s", err)
 	}
@@ -170,8 +198,12 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		"Scanned file-system and uploaded %d objects (%s) in %s (%s/s)\n",
 		len(fs.InodeTable), format.FormatBytes(fs.TotalDataBytes),
 		format.Duration(duration), format.FormatBytes(speed))
-	_, oldImage, err := getLatestImage(client, request.StreamName, buildLog)
-	if err != nil {
+	if !sourceDateEpoch.IsZero() {
+		numClamped := clampMtimes(fs, sourceDateEpoch)
+		fmt.Fprintf(buildLog, "Clamped mtimes for %d inodes to: %s\n",
+			numClamped, sourceDateEpoch.UTC())
+	} else if _, oldImage, err := getLatestImage(client, request.StreamName,
+		buildLog); err != nil {
 		return nil, fmt.Errorf("error getting latest image: %s", err)
 	} else if oldImage != nil {
 		patchStartTime := time.Now()
@@ -179,10 +211,11 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		fmt.Fprintf(buildLog, "Copied mtimes in %s\n",
 			format.Duration(time.Since(patchStartTime)))
 	}
-	if err := runTests(dirname, buildLog); err != nil {
-		return nil, err
-	}
 	objClient := objectclient.AttachObjectClient(client)
+	sbomAnnotation, err := addSbom(objClient, request.StreamName, dirname, fs)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error generating SBOM: %s\n", err)
+	}
 	// Make a copy of the build log because AddObject() drains the buffer.
 	logReader := bytes.NewBuffer(buildLog.Bytes())
 	hashVal, _, err := objClient.AddObject(logReader, uint64(logReader.Len()),
@@ -194,11 +227,13 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		return nil, err
 	}
 	img := &image.Image{
-		BuildLog:   &image.Annotation{Object: &hashVal},
-		FileSystem: fs,
-		Filter:     imageFilter,
-		Triggers:   trig,
-		Packages:   packages,
+		BuildLog:    &image.Annotation{Object: &hashVal},
+		FileSystem:  fs,
+		Filter:      imageFilter,
+		Triggers:    trig,
+		Packages:    packages,
+		Sbom:        sbomAnnotation,
+		TestResults: testResults,
 	}
 	if err := img.Verify(); err != nil {
 		return nil, err
@@ -206,7 +241,12 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	return img, nil
 }
 
-func runTests(rootDir string, buildLog buildLogger) error {
+// runTests will run the test programmes in the /tests directory tree of the
+// image (with the bind mounts available) and will return the results. An error
+// is returned if any test fails.
+func runTests(rootDir string, bindMounts []string, cgroup *buildCgroup,
+	envVars map[string]string,
+	buildLog buildLogger) ([]image.TestResult, error) {
 	var testProgrammes []string
 	err := filepath.Walk(filepath.Join(rootDir, "tests"),
 		func(path string, fi os.FileInfo, err error) error {
@@ -217,39 +257,55 @@ func runTests(rootDir string, buildLog buildLogger) error {
 			return nil
 		})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(testProgrammes) < 1 {
-		return nil
+		return nil, nil
 	}
+	directoriesToDelete, err := makeMountPoints(rootDir, bindMounts, buildLog)
+	if err != nil {
+		return nil, err
+	}
+	defer deleteDirectories(directoriesToDelete)
 	fmt.Fprintf(buildLog, "Running %d tests\n", len(testProgrammes))
 	results := make(chan testResultType, 1)
 	for _, prog := range testProgrammes {
 		go func(prog string) {
-			results <- runTest(rootDir, prog)
+			results <- runTest(rootDir, bindMounts, cgroup, envVars, prog)
 		}(prog)
 	}
+	testResults := make([]image.TestResult, 0, len(testProgrammes))
 	numFailures := 0
 	for range testProgrammes {
 		result := <-results
 		io.Copy(buildLog, &result)
+		testResult := image.TestResult{
+			Name:     result.prog,
+			Duration: result.duration,
+		}
 		if result.err != nil {
 			fmt.Fprintf(buildLog, "error running: %s: %s\n",
 				result.prog, result.err)
+			testResult.Error = result.err.Error()
 			numFailures++
 		} else {
 			fmt.Fprintf(buildLog, "%s passed in %s\n",
 				result.prog, format.Duration(result.duration))
 		}
 		fmt.Fprintln(buildLog)
+		testResults = append(testResults, testResult)
 	}
 	if numFailures > 0 {
-		return fmt.Errorf("%d tests failed", numFailures)
+		return nil, fmt.Errorf("%d tests failed", numFailures)
 	}
-	return nil
+	sort.Slice(testResults, func(left, right int) bool {
+		return testResults[left].Name < testResults[right].Name
+	})
+	return testResults, nil
 }
 
-func runTest(rootDir, prog string) testResultType {
+func runTest(rootDir string, bindMounts []string, cgroup *buildCgroup,
+	envVars map[string]string, prog string) testResultType {
 	startTime := time.Now()
 	result := testResultType{
 		buffer: make(chan byte, 4096),
@@ -258,14 +314,15 @@ func runTest(rootDir, prog string) testResultType {
 	errChannel := make(chan error, 1)
 	timer := time.NewTimer(time.Second * 10)
 	go func() {
-		errChannel <- runInTarget(nil, &result, rootDir, packagerPathname,
-			"run", prog)
+		errChannel <- runInTargetWithBindMounts(nil, &result, rootDir,
+			bindMounts, cgroup, envVars, packagerPathname, "run", prog)
 	}()
 	select {
 	case result.err = <-errChannel:
 		result.duration = time.Since(startTime)
 	case <-timer.C:
 		result.err = errorTestTimedOut
+		result.duration = time.Since(startTime)
 	}
 	return result
 }
@@ -22,7 +22,7 @@ type buildLogger interface {
 
 type imageBuilder interface {
 	build(b *Builder, client *srpc.Client, request proto.BuildImageRequest,
-		buildLog buildLogger) (*image.Image, error)
+		cgroup *buildCgroup, buildLog buildLogger) (*image.Image, error)
 }
 
 type bootstrapStream struct {
@@ -30,7 +30,15 @@ type bootstrapStream struct {
 	name             string
 	BootstrapCommand []string
 	*filter.Filter
-	PackagerType string
+	OciImage        string
+	PackagerType    string
+	RebuildSchedule *rebuildScheduleType `json:",omitempty"`
+	ResourceLimits  *resourceLimitsType  `json:",omitempty"`
+}
+
+type buildCacheType struct {
+	key    string
+	result string
 }
 
 type buildResultType struct {
@@ -38,19 +46,34 @@ type buildResultType struct {
 	startTime  time.Time
 	finishTime time.Time
 	buildLog   []byte
+	cache      buildCacheType
+	usage      *proto.BuildResourceUsage
 	error      error
 }
 
 type masterConfigurationType struct {
 	BindMounts                []string                    `json:",omitempty"`
 	BootstrapStreams          map[string]*bootstrapStream `json:",omitempty"`
+	BuildHistoryMaxAgeDays    uint                        `json:",omitempty"`
+	BuildHistoryMaxBuilds     uint                        `json:",omitempty"`
+	CgroupDirectory           string                      `json:",omitempty"`
+	DefaultResourceLimits     resourceLimitsType          `json:",omitempty"`
 	ImageStreamsCheckInterval uint                        `json:",omitempty"`
 	ImageStreamsToAutoRebuild []string                    `json:",omitempty"`
 	ImageStreamsUrl           string                      `json:",omitempty"`
+	MaxConcurrentBuilds       uint                        `json:",omitempty"`
 	PackagerTypes             map[string]packagerType     `json:",omitempty"`
+	ReproducibleFilterLines   []string                    `json:",omitempty"`
+}
+
+type includeType struct {
+	GitBranch         string `json:",omitempty"`
+	ManifestDirectory string
+	ManifestUrl       string `json:",omitempty"`
 }
 
 type manifestConfigType struct {
+	Includes    []includeType `json:",omitempty"`
 	SourceImage string
 	*filter.Filter
 }
@@ -66,6 +89,9 @@ type imageStreamType struct {
 	BuilderGroups     []string
 	ManifestUrl       string
 	ManifestDirectory string
+	RebuildSchedule   *rebuildScheduleType `json:",omitempty"`
+	Reproducible      bool
+	ResourceLimits    *resourceLimitsType `json:",omitempty"`
 }
 
 type imageStreamsConfigurationType struct {
@@ -90,8 +116,9 @@ type packagerType struct {
 }
 
 type sourceImageInfoType struct {
-	filter   *filter.Filter
-	triggers *triggers.Triggers
+	createdOn time.Time
+	filter    *filter.Filter
+	triggers  *triggers.Triggers
 }
 
 type Builder struct {
@@ -108,7 +135,25 @@ type Builder struct {
 	buildResultsLock          sync.RWMutex
 	currentBuildLogs          map[string]*bytes.Buffer   // Key: stream name.
 	lastBuildResults          map[string]buildResultType // Key: stream name.
+	buildCacheHits            uint64
+	buildCacheMisses          uint64
+	buildHistoryMaxAge        time.Duration
+	buildHistoryMaxBuilds     uint // Per stream.
+	cgroupDirectory           string
+	defaultResourceLimits     resourceLimitsType
+	buildQueueLock            sync.Mutex
+	buildQueue                []*buildQueueEntry // Queued and running.
+	imageRebuildInterval      time.Duration
+	maxConcurrentBuilds       uint
+	nextBuildId               uint64
+	numRunningBuilds          uint
+	streamIncludes            map[string][]manifestLocation // Key: stream.
+	streamSources             map[string]string             // Key: stream.
 	packagerTypes             map[string]packagerType
+	scheduleLock              sync.Mutex
+	nextAutoRebuild           time.Time
+	scheduledBuilds           map[string]*scheduledBuildType // Key: stream.
+	reproducibleFilter        *filter.Filter
 	variables                 map[string]string
 }
 
@@ -125,6 +170,29 @@ func (b *Builder) BuildImage(request proto.BuildImageRequest,
 	return b.buildImage(request, authInfo, logWriter)
 }
 
+func (b *Builder) BuildStreamsForPush(repositoryUrls []string,
+	gitBranch string, changedPaths []string) []string {
+	return b.buildStreamsForPush(repositoryUrls, gitBranch, changedPaths)
+}
+
+func (b *Builder) CancelBuild(id uint64,
+	authInfo *srpc.AuthInformation) error {
+	return b.cancelBuild(id, authInfo)
+}
+
+func (b *Builder) GetBuildHistory(streamName string,
+	maxRecords uint) ([]proto.BuildRecord, error) {
+	return b.getBuildHistory(streamName, maxRecords)
+}
+
+func (b *Builder) GetBuildLog(streamName, id string) ([]byte, error) {
+	return b.getBuildLog(streamName, id)
+}
+
+func (b *Builder) GetBuildQueue() []proto.BuildQueueEntry {
+	return b.getBuildQueue()
+}
+
 func (b *Builder) GetCurrentBuildLog(streamName string) ([]byte, error) {
 	return b.getCurrentBuildLog(streamName)
 }
@@ -166,12 +234,13 @@ func BuildTreeFromManifest(client *srpc.Client, manifestDir string,
 
 func ProcessManifest(manifestDir, rootDir string, bindMounts []string,
 	buildLog io.Writer) error {
-	return processManifest(manifestDir, rootDir, bindMounts, buildLog)
+	return processManifest(manifestDir, rootDir, bindMounts, nil, nil,
+		buildLog)
 }
 
 func UnpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 	rootDir string, bindMounts []string, buildLog io.Writer) error {
 	_, err := unpackImageAndProcessManifest(client, manifestDir, rootDir,
-		bindMounts, true, buildLog)
+		bindMounts, nil, true, false, buildLog)
 	return err
 }
@@ -44,10 +44,12 @@ var environmentToSet = map[string]string{
 	"USER":    "root",
 }
 
-func cleanPackages(rootDir string, buildLog io.Writer) error {
+func cleanPackages(rootDir string, cgroup *buildCgroup,
+	buildLog io.Writer) error {
 	fmt.Fprintln(buildLog, "\nCleaning packages:")
 	startTime := time.Now()
-	err := runInTarget(nil, buildLog, rootDir, packagerPathname, "clean")
+	err := runInTarget(nil, buildLog, rootDir, cgroup, nil, packagerPathname,
+		"clean")
 	if err != nil {
 		return errors.New("error cleaning: " + err.Error())
 	}
@@ -69,7 +71,7 @@ func makeTempDirectory(dir, prefix string) (string, error) {
 }
 
 func (stream *bootstrapStream) build(b *Builder, client *srpc.Client,
-	request proto.BuildImageRequest,
+	request proto.BuildImageRequest, cgroup *buildCgroup,
 	buildLog buildLogger) (*image.Image, error) {
 	startTime := time.Now()
 	args := make([]string, 0, len(stream.BootstrapCommand))
@@ -80,34 +82,40 @@ func (stream *bootstrapStream) build(b *Builder, client *srpc.Client,
 	}
 	defer os.RemoveAll(rootDir)
 	fmt.Fprintf(buildLog, "Created image working directory: %s\n", rootDir)
-	for _, arg := range stream.BootstrapCommand {
-		if arg == "$dir" {
-			arg = rootDir
-		}
-		args = append(args, arg)
-	}
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Stdout = buildLog
-	cmd.Stderr = buildLog
-	if err := cmd.Run(); err != nil {
-		return nil, err
-	} else {
-		packager := b.packagerTypes[stream.PackagerType]
-		if err := packager.writePackageInstaller(rootDir); err != nil {
+	if stream.OciImage != "" {
+		err := unpackOciImage(stream.OciImage, rootDir, buildLog)
+		if err != nil {
 			return nil, err
 		}
-		if err := clearResolvConf(buildLog, rootDir); err != nil {
-			return nil, err
+	} else {
+		for _, arg := range stream.BootstrapCommand {
+			if arg == "$dir" {
+				arg = rootDir
+			}
+			args = append(args, arg)
 		}
-		buildDuration := time.Since(startTime)
-		fmt.Fprintf(buildLog, "\nBuild time: %s\n",
-			format.Duration(buildDuration))
-		if err := cleanPackages(rootDir, buildLog); err != nil {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = buildLog
+		cmd.Stderr = buildLog
+		if err := cgroup.run(cmd); err != nil {
 			return nil, err
 		}
-		return packImage(client, request, rootDir,
-			stream.Filter, nil, &filter.Filter{}, nil, buildLog)
 	}
+	packager := b.packagerTypes[stream.PackagerType]
+	if err := packager.writePackageInstaller(rootDir); err != nil {
+		return nil, err
+	}
+	if err := clearResolvConf(buildLog, rootDir, cgroup); err != nil {
+		return nil, err
+	}
+	buildDuration := time.Since(startTime)
+	fmt.Fprintf(buildLog, "\nBuild time: %s\n",
+		format.Duration(buildDuration))
+	if err := cleanPackages(rootDir, cgroup, buildLog); err != nil {
+		return nil, err
+	}
+	return packImage(client, request, rootDir, cgroup,
+		stream.Filter, nil, &filter.Filter{}, nil, nil, time.Time{}, buildLog)
 }
 
 func (packager *packagerType) writePackageInstaller(rootDir string) error {
@@ -174,15 +182,17 @@ func writeArgument(writer io.Writer, arg string) {
 	}
 }
 
-func clearResolvConf(writer io.Writer, rootDir string) error {
-	return runInTarget(nil, writer, rootDir, "cp", "/dev/null",
+func clearResolvConf(writer io.Writer, rootDir string,
+	cgroup *buildCgroup) error {
+	return runInTarget(nil, writer, rootDir, cgroup, nil, "cp", "/dev/null",
 		"/etc/resolv.conf")
 }
 
-func runInTarget(input io.Reader, output io.Writer, rootDir, prog string,
+func runInTarget(input io.Reader, output io.Writer, rootDir string,
+	cgroup *buildCgroup, envVars map[string]string, prog string,
 	args ...string) error {
 	cmd := exec.Command(prog, args...)
-	cmd.Env = stripVariables(os.Environ(), environmentToCopy)
+	cmd.Env = stripVariables(os.Environ(), environmentToCopy, envVars)
 	cmd.Dir = "/"
 	cmd.Stdin = input
 	cmd.Stdout = output
@@ -192,13 +202,15 @@ func runInTarget(input io.Reader, output io.Writer, rootDir, prog string,
 		Setsid:     true,
 		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
 	}
-	return cmd.Run()
+	return cgroup.run(cmd)
 }
 
 func runInTargetWithBindMounts(input io.Reader, output io.Writer,
-	rootDir string, bindMounts []string, prog string, args ...string) error {
+	rootDir string, bindMounts []string, cgroup *buildCgroup,
+	envVars map[string]string, prog string, args ...string) error {
 	if len(bindMounts) < 1 {
-		return runInTarget(input, output, rootDir, prog, args...)
+		return runInTarget(input, output, rootDir, cgroup, envVars, prog,
+			args...)
 	}
 	errChannel := make(chan error)
 	go func() {
@@ -215,14 +227,16 @@ func runInTargetWithBindMounts(input io.Reader, output io.Writer,
 						bindMount, err)
 				}
 			}
-			return runInTarget(input, output, rootDir, prog, args...)
+			return runInTarget(input, output, rootDir, cgroup, envVars, prog,
+				args...)
 		}()
 		errChannel <- err
 	}()
 	return <-errChannel
 }
 
-func stripVariables(input []string, varsToCopy map[string]struct{}) []string {
+func stripVariables(input []string, varsToCopy map[string]struct{},
+	extraVars map[string]string) []string {
 	output := make([]string, 0)
 	for _, nameValue := range os.Environ() {
 		split := strings.SplitN(nameValue, "=", 2)
@@ -235,6 +249,9 @@ func stripVariables(input []string, varsToCopy map[string]struct{}) []string {
 	for name, value := range environmentToSet {
 		output = append(output, name+"="+value)
 	}
+	for name, value := range extraVars {
+		output = append(output, name+"="+value)
+	}
 	sort.Strings(output)
 	return output
 }
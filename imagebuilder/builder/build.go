@@ -31,6 +31,9 @@ func checkPermission(builder imageBuilder, request proto.BuildImageRequest,
 	if request.ExpiresIn > time.Hour*24 {
 		return errors.New("maximum expiration time is 1 day")
 	}
+	if request.Priority > 0 {
+		return errors.New("no permission to raise priority")
+	}
 	if builder, ok := builder.(*imageStreamType); ok {
 		for _, group := range builder.BuilderGroups {
 			if _, ok := authInfo.GroupList[group]; ok {
@@ -67,23 +70,36 @@ func (b *Builder) rebuildImages(minInterval time.Duration) {
 	var sleepUntil time.Time
 	for ; ; time.Sleep(time.Until(sleepUntil)) {
 		sleepUntil = time.Now().Add(minInterval)
-		client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
-		if err != nil {
-			b.logger.Println(err)
-			continue
-		}
-		for _, streamName := range b.listStreamsToAutoRebuild() {
-			_, _, err := b.build(client, proto.BuildImageRequest{
+		b.scheduleLock.Lock()
+		b.nextAutoRebuild = sleepUntil
+		b.scheduleLock.Unlock()
+		streamNames := b.listStreamsToAutoRebuild()
+		resultChannels := make([]<-chan queuedBuildResult, len(streamNames))
+		for index, streamName := range streamNames {
+			if b.getRebuildSchedule(streamName) != nil {
+				continue // Built by runSchedules.
+			}
+			resultChannel, err := b.enqueueBuild(proto.BuildImageRequest{
 				StreamName: streamName,
 				ExpiresIn:  minInterval * 2,
 			},
 				nil, nil)
 			if err != nil {
-				b.logger.Printf("Error building image: %s: %s\n",
+				b.logger.Printf("Error queueing build: %s: %s\n",
 					streamName, err)
+				continue
+			}
+			resultChannels[index] = resultChannel
+		}
+		for index, resultChannel := range resultChannels {
+			if resultChannel == nil {
+				continue
+			}
+			if result := <-resultChannel; result.err != nil {
+				b.logger.Printf("Error building image: %s: %s\n",
+					streamNames[index], result.err)
 			}
 		}
-		client.Close()
 	}
 }
 
@@ -93,16 +109,26 @@ func (b *Builder) buildImage(request proto.BuildImageRequest,
 	if request.ExpiresIn < time.Minute*15 {
 		return nil, "", errors.New("minimum expiration time is 15 minutes")
 	}
-	client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
+	resultChannel, err := b.enqueueBuild(request, authInfo, logWriter)
 	if err != nil {
 		return nil, "", err
 	}
-	defer client.Close()
-	img, name, err := b.build(client, request, authInfo, logWriter)
+	result := <-resultChannel
 	if request.ReturnImage {
-		return img, "", err
+		return result.image, "", result.err
 	}
-	return nil, name, err
+	return nil, result.name, result.err
+}
+
+func (b *Builder) buildWithNewClient(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation,
+	logWriter io.Writer) (*image.Image, string, error) {
+	client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	defer client.Close()
+	return b.build(client, request, authInfo, logWriter)
 }
 
 func (b *Builder) build(client *srpc.Client, request proto.BuildImageRequest,
@@ -113,8 +139,8 @@ func (b *Builder) build(client *srpc.Client, request proto.BuildImageRequest,
 	if builder == nil {
 		return nil, "", errors.New("unknown stream: " + request.StreamName)
 	}
-	if err := checkPermission(builder, request, authInfo); err != nil {
-		return nil, "", err
+	if stream, ok := builder.(*imageStreamType); ok && stream.Reproducible {
+		request.Reproducible = true
 	}
 	buildLogBuffer := &bytes.Buffer{}
 	b.buildResultsLock.Lock()
@@ -129,23 +155,34 @@ func (b *Builder) build(client *srpc.Client, request proto.BuildImageRequest,
 			writer: io.MultiWriter(buildLogBuffer, logWriter),
 		}
 	}
+	var cache buildCacheType
+	var usage proto.BuildResourceUsage
 	img, name, err := b.buildWithLogger(builder, client, request, authInfo,
-		startTime, buildLog)
+		startTime, &cache, &usage, buildLog)
 	finishTime := time.Now()
+	result := buildResultType{
+		name, startTime, finishTime, buildLog.Bytes(), cache, nil, err}
+	if usage != (proto.BuildResourceUsage{}) {
+		result.usage = &usage
+	}
 	b.buildResultsLock.Lock()
-	defer b.buildResultsLock.Unlock()
 	delete(b.currentBuildLogs, request.StreamName)
-	b.lastBuildResults[request.StreamName] = buildResultType{
-		name, startTime, finishTime, buildLog.Bytes(), err}
+	b.lastBuildResults[request.StreamName] = result
+	b.buildResultsLock.Unlock()
 	if err == nil {
 		b.logger.Printf("Built image for stream: %s in %s\n",
 			request.StreamName, format.Duration(finishTime.Sub(startTime)))
 	}
+	if err := b.recordBuild(request, authInfo, result); err != nil {
+		b.logger.Printf("Error recording build history for: %s: %s\n",
+			request.StreamName, err)
+	}
 	return img, name, err
 }
 
 func (b *Builder) buildSomewhere(builder imageBuilder, client *srpc.Client,
 	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
+	usage *proto.BuildResourceUsage,
 	buildLog buildLogger) (*image.Image, error) {
 	if b.slaveDriver == nil {
 		if authInfo == nil {
@@ -155,7 +192,19 @@ func (b *Builder) buildSomewhere(builder imageBuilder, client *srpc.Client,
 			b.logger.Printf("%s requested building image for stream: %s\n",
 				authInfo.Username, request.StreamName)
 		}
-		img, err := builder.build(b, client, request, buildLog)
+		cgroup, err := b.newBuildCgroup(builder, request.StreamName, buildLog)
+		if err != nil {
+			fmt.Fprintf(buildLog, "Error creating cgroup: %s\n", err)
+			return nil, err
+		}
+		img, err := builder.build(b, client, request, cgroup, buildLog)
+		if cgroupUsage := cgroup.release(buildLog); cgroupUsage != nil {
+			*usage = *cgroupUsage
+			if err == nil && cgroupUsage.TimedOut {
+				err = fmt.Errorf("build timeout of %s exceeded",
+					format.Duration(cgroup.timeout()))
+			}
+		}
 		if err != nil {
 			fmt.Fprintf(buildLog, "Error building image: %s\n", err)
 		}
@@ -167,13 +216,29 @@ func (b *Builder) buildSomewhere(builder imageBuilder, client *srpc.Client,
 
 func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
 	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
-	startTime time.Time, buildLog buildLogger) (*image.Image, string, error) {
-	img, err := b.buildSomewhere(builder, client, request, authInfo, buildLog)
+	startTime time.Time, cache *buildCacheType,
+	usage *proto.BuildResourceUsage,
+	buildLog buildLogger) (*image.Image, string, error) {
+	name, err := b.checkBuildCache(builder, client, request, cache, buildLog)
+	if err != nil {
+		fmt.Fprintf(buildLog, "Error checking build cache: %s\n", err)
+		return nil, "", err
+	}
+	if name != "" {
+		if err := reuseImage(client, request, name, buildLog); err != nil {
+			fmt.Fprintln(buildLog, err)
+			return nil, "", err
+		}
+		return nil, name, nil
+	}
+	img, err := b.buildSomewhere(builder, client, request, authInfo, usage,
+		buildLog)
 	if err != nil {
 		if needSource, sourceImage := needSourceImage(err); needSource {
 			if request.DisableRecursiveBuild {
 				return nil, "", err
 			}
+			b.setStreamSource(request.StreamName, sourceImage)
 			// Try to build source image.
 			expiresIn := time.Hour
 			if request.ExpiresIn > 0 {
@@ -183,13 +248,14 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
 				StreamName:   sourceImage,
 				ExpiresIn:    expiresIn,
 				MaxSourceAge: request.MaxSourceAge,
+				Priority:     request.Priority,
 				Variables:    request.Variables,
 			}
-			if _, _, e := b.build(client, sourceReq, nil, buildLog); e != nil {
+			if _, _, e := b.buildAndWait(sourceReq, buildLog); e != nil {
 				return nil, "", e
 			}
 			img, err = b.buildSomewhere(builder, client, request, authInfo,
-				buildLog)
+				usage, buildLog)
 		}
 	}
 	if err != nil {
@@ -198,6 +264,24 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
 	if request.ReturnImage {
 		return img, "", nil
 	}
+	if cache.key != "" {
+		name, err := findIdenticalImage(client, request.StreamName, img,
+			buildLog)
+		if err != nil {
+			fmt.Fprintln(buildLog, err)
+			return nil, "", err
+		}
+		if name != "" {
+			cache.result = cacheResultIdentical
+			fmt.Fprintf(buildLog,
+				"Image identical to: %s, skipping upload\n", name)
+			if err := reuseImage(client, request, name, buildLog); err != nil {
+				fmt.Fprintln(buildLog, err)
+				return nil, "", err
+			}
+			return img, name, nil
+		}
+	}
 	uploadStartTime := time.Now()
 	if name, err := addImage(client, request, img); err != nil {
 		fmt.Fprintln(buildLog, err)
@@ -218,19 +302,7 @@ func (b *Builder) buildOnSlave(client *srpc.Client,
 	request.DisableRecursiveBuild = true
 	request.ReturnImage = true
 	request.StreamBuildLog = true
-	if len(request.Variables) < 1 {
-		request.Variables = b.variables
-	} else if len(b.variables) > 0 {
-		variables := make(map[string]string,
-			len(b.variables)+len(request.Variables))
-		for key, value := range b.variables {
-			variables[key] = value
-		}
-		for key, value := range request.Variables {
-			variables[key] = value
-		}
-		request.Variables = variables
-	}
+	request.Variables = b.mergeVariables(request.Variables)
 	slave, err := b.slaveDriver.GetSlave()
 	if err != nil {
 		return nil, fmt.Errorf("error getting slave: %s", err)
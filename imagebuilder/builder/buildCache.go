@@ -0,0 +1,212 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	imageclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+const (
+	cacheResultHit       = "hit"
+	cacheResultIdentical = "identical"
+	cacheResultMiss      = "miss"
+)
+
+// computeBuildKey will compute a key from the contents of the manifest tree,
+// the name of the latest source image, the variables and whether the build is
+// reproducible. If there is no source image, an empty key is returned.
+func computeBuildKey(client *srpc.Client, manifestDir string,
+	variables map[string]string, reproducible bool) (string, error) {
+	var manifestConfig manifestConfigType
+	err := json.ReadFromFile(filepath.Join(manifestDir, "manifest"),
+		&manifestConfig)
+	if err != nil {
+		return "", errors.New("error reading manifest file: " + err.Error())
+	}
+	sourceImageName, err := imageclient.FindLatestImage(client,
+		manifestConfig.SourceImage, false)
+	if err != nil {
+		return "", err
+	}
+	if sourceImageName == "" {
+		return "", nil
+	}
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "SourceImage: %s\n", sourceImageName)
+	fmt.Fprintf(hasher, "Reproducible: %t\n", reproducible)
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(hasher, "Variable: %s=%s\n", name, variables[name])
+	}
+	err = filepath.Walk(manifestDir,
+		func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hasher, "%s %s\n", path[len(manifestDir):], fi.Mode())
+			if fi.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(hasher, "-> %s\n", target)
+			} else if fi.Mode().IsRegular() {
+				file, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				fmt.Fprintf(hasher, "%d\n", fi.Size())
+				if _, err := io.Copy(hasher, file); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// checkBuildCache will compute the build key for a normal image stream and
+// compare it with the key for the last successful build of the stream. If they
+// match and the image still exists, the image name is returned.
+func (b *Builder) checkBuildCache(builder imageBuilder, client *srpc.Client,
+	request proto.BuildImageRequest, cache *buildCacheType,
+	buildLog io.Writer) (string, error) {
+	stream, ok := builder.(*imageStreamType)
+	if !ok || request.ReturnImage {
+		return "", nil
+	}
+	manifestDirectory, err := stream.getManifest(b, request.StreamName,
+		request.GitBranch, request.Variables, buildLog)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(manifestDirectory)
+	buildKey, err := computeBuildKey(client, manifestDirectory,
+		b.mergeVariables(request.Variables), request.Reproducible)
+	if err != nil {
+		return "", err
+	}
+	if buildKey == "" {
+		fmt.Fprintln(buildLog, "No source image, build cache not used")
+		return "", nil
+	}
+	fmt.Fprintf(buildLog, "Build key: %s\n", buildKey)
+	cache.key = buildKey
+	b.buildResultsLock.RLock()
+	result := b.lastBuildResults[request.StreamName]
+	b.buildResultsLock.RUnlock()
+	if result.error == nil && result.cache.key == buildKey &&
+		result.imageName != "" {
+		exists, err := imageclient.CheckImage(client, result.imageName)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			cache.result = cacheResultHit
+			b.countCacheResult(true)
+			fmt.Fprintf(buildLog, "Build cache hit: %s, skipping build\n",
+				result.imageName)
+			return result.imageName, nil
+		}
+	}
+	cache.result = cacheResultMiss
+	b.countCacheResult(false)
+	fmt.Fprintln(buildLog, "Build cache miss")
+	return "", nil
+}
+
+func (b *Builder) countCacheResult(hit bool) {
+	b.buildResultsLock.Lock()
+	defer b.buildResultsLock.Unlock()
+	if hit {
+		b.buildCacheHits++
+	} else {
+		b.buildCacheMisses++
+	}
+}
+
+// findIdenticalImage will return the name of the latest image in the stream if
+// it is identical to img, else it returns the empty string.
+func findIdenticalImage(client *srpc.Client, streamName string,
+	img *image.Image, buildLog io.Writer) (string, error) {
+	name, oldImage, err := getLatestImage(client, streamName, buildLog)
+	if err != nil || oldImage == nil {
+		return "", err
+	}
+	if !filesystem.CompareFileSystems(oldImage.FileSystem, img.FileSystem,
+		nil) {
+		return "", nil
+	}
+	var oldFilter, newFilter []string
+	if oldImage.Filter != nil {
+		oldFilter = oldImage.Filter.FilterLines
+	}
+	if img.Filter != nil {
+		newFilter = img.Filter.FilterLines
+	}
+	if !jsonEqual(oldFilter, newFilter) ||
+		!jsonEqual(oldImage.Triggers, img.Triggers) {
+		return "", nil
+	}
+	return name, nil
+}
+
+func jsonEqual(left, right interface{}) bool {
+	leftData, err := stdjson.Marshal(left)
+	if err != nil {
+		return false
+	}
+	rightData, err := stdjson.Marshal(right)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(leftData, rightData)
+}
+
+// reuseImage will extend the expiration time of an existing image so that it
+// lasts as long as a newly built image would have.
+func reuseImage(client *srpc.Client, request proto.BuildImageRequest,
+	name string, buildLog io.Writer) error {
+	expiresAt, err := imageclient.GetImageExpiration(client, name)
+	if err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return nil
+	}
+	var newExpiresAt time.Time
+	if request.ExpiresIn > 0 {
+		newExpiresAt = time.Now().Add(request.ExpiresIn)
+		if !newExpiresAt.After(expiresAt) {
+			return nil
+		}
+	}
+	err = imageclient.ChangeImageExpiration(client, name, newExpiresAt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buildLog, "Extended expiration time of: %s\n", name)
+	return nil
+}
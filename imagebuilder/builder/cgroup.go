@@ -0,0 +1,410 @@
+package builder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+const (
+	cgroupCpuPeriod        = 100000 // Microseconds.
+	defaultCgroupDirectory = "/sys/fs/cgroup/imaginator-builds"
+)
+
+var cgroupControllers = []string{"cpu", "io", "memory", "pids"}
+
+type buildCgroup struct {
+	dirname  string
+	limits   resourceLimitsType
+	timer    *time.Timer
+	mutex    sync.Mutex // Protect everything below.
+	timedOut bool
+}
+
+type resourceLimitsType struct {
+	CpuLimit       float64 `json:",omitempty"` // Number of CPUs.
+	IoWeight       uint    `json:",omitempty"` // 1-10000, default 100.
+	MemoryLimitMiB uint64  `json:",omitempty"`
+	PidsLimit      uint    `json:",omitempty"`
+	TimeoutSeconds uint    `json:",omitempty"`
+}
+
+// setupCgroups will create the parent cgroup for builds, enable the
+// controllers for its children and remove cgroups left over from previous
+// builds.
+func setupCgroups(dirname string) error {
+	data, err := ioutil.ReadFile(
+		filepath.Join(filepath.Dir(dirname), "cgroup.controllers"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("cgroup v2 not mounted")
+		}
+		return err
+	}
+	err = os.Mkdir(dirname, dirPerms)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	available := make(map[string]struct{})
+	for _, controller := range strings.Fields(string(data)) {
+		available[controller] = struct{}{}
+	}
+	var enable []string
+	for _, controller := range cgroupControllers {
+		if _, ok := available[controller]; ok {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(dirname, "cgroup.subtree_control"),
+		[]byte(strings.Join(enable, " ")), 0644)
+	if err != nil {
+		return fmt.Errorf("error enabling controllers: %s", err)
+	}
+	names, err := listDirectory(dirname)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		pathname := filepath.Join(dirname, name)
+		if fi, err := os.Stat(pathname); err == nil && fi.IsDir() {
+			killCgroup(pathname)
+			removeCgroup(pathname)
+		}
+	}
+	return nil
+}
+
+func killCgroup(dirname string) {
+	err := ioutil.WriteFile(filepath.Join(dirname, "cgroup.kill"),
+		[]byte("1"), 0644)
+	if err == nil {
+		return
+	}
+	// Kernels before 5.14 do not have cgroup.kill.
+	data, err := ioutil.ReadFile(filepath.Join(dirname, "cgroup.procs"))
+	if err != nil {
+		return
+	}
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+// removeCgroup will remove a cgroup, waiting up to 5 seconds for the killed
+// processes in it to exit.
+func removeCgroup(dirname string) error {
+	var err error
+	for count := 0; count < 50; count++ {
+		if err = syscall.Rmdir(dirname); err != syscall.EBUSY {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return err
+}
+
+// mergeLimits returns the default limits overridden by the non-zero stream
+// specific limits.
+func mergeLimits(defaults resourceLimitsType,
+	limits *resourceLimitsType) resourceLimitsType {
+	if limits == nil {
+		return defaults
+	}
+	if limits.CpuLimit > 0 {
+		defaults.CpuLimit = limits.CpuLimit
+	}
+	if limits.IoWeight > 0 {
+		defaults.IoWeight = limits.IoWeight
+	}
+	if limits.MemoryLimitMiB > 0 {
+		defaults.MemoryLimitMiB = limits.MemoryLimitMiB
+	}
+	if limits.PidsLimit > 0 {
+		defaults.PidsLimit = limits.PidsLimit
+	}
+	if limits.TimeoutSeconds > 0 {
+		defaults.TimeoutSeconds = limits.TimeoutSeconds
+	}
+	return defaults
+}
+
+// newBuildCgroup will create a cgroup with the resource limits for the image
+// stream, in which all the programmes for the build are run. If cgroups are
+// not available, nil is returned.
+func (b *Builder) newBuildCgroup(builder imageBuilder, streamName string,
+	buildLog io.Writer) (*buildCgroup, error) {
+	if b.cgroupDirectory == "" {
+		return nil, nil
+	}
+	var streamLimits *resourceLimitsType
+	switch stream := builder.(type) {
+	case *bootstrapStream:
+		streamLimits = stream.ResourceLimits
+	case *imageStreamType:
+		streamLimits = stream.ResourceLimits
+	}
+	cgroup := &buildCgroup{
+		dirname: filepath.Join(b.cgroupDirectory, fmt.Sprintf("%s.%d",
+			strings.Replace(streamName, "/", "_", -1),
+			time.Now().UnixNano())),
+		limits: mergeLimits(b.defaultResourceLimits, streamLimits),
+	}
+	if err := os.Mkdir(cgroup.dirname, dirPerms); err != nil {
+		return nil, err
+	}
+	if err := cgroup.writeLimits(buildLog); err != nil {
+		removeCgroup(cgroup.dirname)
+		return nil, err
+	}
+	if timeout := cgroup.timeout(); timeout > 0 {
+		cgroup.timer = time.AfterFunc(timeout, func() {
+			cgroup.mutex.Lock()
+			cgroup.timedOut = true
+			cgroup.mutex.Unlock()
+			killCgroup(cgroup.dirname)
+		})
+	}
+	return cgroup, nil
+}
+
+// checkError will add the reason a programme was killed to the error returned
+// from running it, if it was killed for exceeding a resource limit.
+func (cgroup *buildCgroup) checkError(err error, oomKills uint64) error {
+	if err == nil || cgroup == nil {
+		return err
+	}
+	if cgroup.isTimedOut() {
+		return fmt.Errorf("%s: killed after build timeout of %s",
+			err, format.Duration(cgroup.timeout()))
+	}
+	events := cgroup.readKeyedFile("memory.events")
+	if events["oom_kill"] > oomKills {
+		return fmt.Errorf("%s: killed by OOM killer, memory limit: %s",
+			err, format.FormatBytes(cgroup.limits.MemoryLimitMiB<<20))
+	}
+	return err
+}
+
+func (cgroup *buildCgroup) isTimedOut() bool {
+	cgroup.mutex.Lock()
+	defer cgroup.mutex.Unlock()
+	return cgroup.timedOut
+}
+
+// run will run cmd in the cgroup, or directly if cgroup is nil. An error is
+// returned if the build has timed out.
+func (cgroup *buildCgroup) run(cmd *exec.Cmd) error {
+	if cgroup == nil {
+		return cmd.Run()
+	}
+	if cgroup.isTimedOut() {
+		return fmt.Errorf("build timeout of %s exceeded",
+			format.Duration(cgroup.timeout()))
+	}
+	oomKills := cgroup.readKeyedFile("memory.events")["oom_kill"]
+	if err := cgroup.start(cmd); err != nil {
+		return err
+	}
+	return cgroup.checkError(cmd.Wait(), oomKills)
+}
+
+// start will start cmd and move it into the cgroup. The programme is stopped
+// by ptrace after it is executed and is only resumed once it has been moved,
+// so that none of its children can escape the cgroup.
+func (cgroup *buildCgroup) start(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+	// The tracer is the thread which started the process.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+	err := func() error {
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &status, 0, nil); err != nil {
+			return err
+		}
+		if !status.Stopped() {
+			return errors.New("programme did not stop after starting")
+		}
+		err := ioutil.WriteFile(filepath.Join(cgroup.dirname, "cgroup.procs"),
+			[]byte(strconv.Itoa(pid)), 0644)
+		if err != nil {
+			return fmt.Errorf("error moving programme into cgroup: %s", err)
+		}
+		return syscall.PtraceDetach(pid)
+	}()
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return nil
+}
+
+func (cgroup *buildCgroup) readKeyedFile(filename string) map[string]uint64 {
+	values := make(map[string]uint64)
+	file, err := os.Open(filepath.Join(cgroup.dirname, filename))
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values
+}
+
+func (cgroup *buildCgroup) readValue(filename string) uint64 {
+	data, err := ioutil.ReadFile(filepath.Join(cgroup.dirname, filename))
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return value
+}
+
+// release will kill any remaining processes in the cgroup, write the resource
+// usage to the build log and remove the cgroup. The resource usage is
+// returned, or nil if the build did not use a cgroup.
+func (cgroup *buildCgroup) release(
+	buildLog io.Writer) *proto.BuildResourceUsage {
+	if cgroup == nil {
+		return nil
+	}
+	if cgroup.timer != nil {
+		cgroup.timer.Stop()
+	}
+	killCgroup(cgroup.dirname)
+	usage := &proto.BuildResourceUsage{
+		CpuTime: time.Duration(cgroup.readKeyedFile("cpu.stat")["usage_usec"]) *
+			time.Microsecond,
+		MaxMemory: cgroup.readValue("memory.peak"),
+		MaxPids:   cgroup.readValue("pids.peak"),
+		OomKills:  cgroup.readKeyedFile("memory.events")["oom_kill"],
+		TimedOut:  cgroup.isTimedOut(),
+	}
+	if data, err := ioutil.ReadFile(
+		filepath.Join(cgroup.dirname, "io.stat")); err == nil {
+		for _, field := range strings.Fields(string(data)) {
+			if strings.HasPrefix(field, "rbytes=") {
+				value, _ := strconv.ParseUint(field[7:], 10, 64)
+				usage.IoReadBytes += value
+			} else if strings.HasPrefix(field, "wbytes=") {
+				value, _ := strconv.ParseUint(field[7:], 10, 64)
+				usage.IoWriteBytes += value
+			}
+		}
+	}
+	if err := removeCgroup(cgroup.dirname); err != nil {
+		fmt.Fprintf(buildLog, "Error removing cgroup: %s\n", err)
+	}
+	fmt.Fprintf(buildLog,
+		"Resource usage: CPU time: %s, peak memory: %s, peak processes: %d, ",
+		format.Duration(usage.CpuTime), format.FormatBytes(usage.MaxMemory),
+		usage.MaxPids)
+	fmt.Fprintf(buildLog, "I/O read: %s, written: %s\n",
+		format.FormatBytes(usage.IoReadBytes),
+		format.FormatBytes(usage.IoWriteBytes))
+	if usage.OomKills > 0 {
+		fmt.Fprintf(buildLog, "Processes killed by OOM killer: %d\n",
+			usage.OomKills)
+	}
+	if usage.TimedOut {
+		fmt.Fprintf(buildLog, "Build exceeded timeout of: %s\n",
+			format.Duration(cgroup.timeout()))
+	}
+	return usage
+}
+
+func (cgroup *buildCgroup) timeout() time.Duration {
+	return time.Second * time.Duration(cgroup.limits.TimeoutSeconds)
+}
+
+func (cgroup *buildCgroup) writeLimits(buildLog io.Writer) error {
+	limits := cgroup.limits
+	var descriptions []string
+	if limits.CpuLimit > 0 {
+		err := cgroup.writeValue("cpu.max", fmt.Sprintf("%d %d",
+			uint64(limits.CpuLimit*cgroupCpuPeriod), cgroupCpuPeriod))
+		if err != nil {
+			return err
+		}
+		descriptions = append(descriptions,
+			fmt.Sprintf("CPUs: %g", limits.CpuLimit))
+	}
+	if limits.IoWeight > 0 {
+		err := cgroup.writeValue("io.weight",
+			fmt.Sprintf("default %d", limits.IoWeight))
+		if err != nil {
+			return err
+		}
+		descriptions = append(descriptions,
+			fmt.Sprintf("I/O weight: %d", limits.IoWeight))
+	}
+	if limits.MemoryLimitMiB > 0 {
+		err := cgroup.writeValue("memory.max",
+			strconv.FormatUint(limits.MemoryLimitMiB<<20, 10))
+		if err != nil {
+			return err
+		}
+		// Prevent swapping instead of triggering the OOM killer.
+		cgroup.writeValue("memory.swap.max", "0")
+		descriptions = append(descriptions,
+			"memory: "+format.FormatBytes(limits.MemoryLimitMiB<<20))
+	}
+	if limits.PidsLimit > 0 {
+		err := cgroup.writeValue("pids.max",
+			strconv.FormatUint(uint64(limits.PidsLimit), 10))
+		if err != nil {
+			return err
+		}
+		descriptions = append(descriptions,
+			fmt.Sprintf("processes: %d", limits.PidsLimit))
+	}
+	if limits.TimeoutSeconds > 0 {
+		descriptions = append(descriptions,
+			"timeout: "+format.Duration(cgroup.timeout()))
+	}
+	if len(descriptions) > 0 {
+		fmt.Fprintf(buildLog, "Resource limits: %s\n",
+			strings.Join(descriptions, ", "))
+	}
+	return nil
+}
+
+func (cgroup *buildCgroup) writeValue(filename, value string) error {
+	err := ioutil.WriteFile(filepath.Join(cgroup.dirname, filename),
+		[]byte(value), 0644)
+	if err != nil {
+		return fmt.Errorf("error setting %s: %s", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	liberrors "github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+const (
+	historyDirectory  = "build-history"
+	historyIdFormat   = "2006-01-02:15:04:05.000000"
+	historyLogFile    = "build.log"
+	historyRecordFile = "record.json"
+)
+
+type buildRecordType struct {
+	proto.BuildRecord
+	BuildKey string `json:",omitempty"`
+}
+
+// buildHistoryDirectory returns the directory containing the build history for
+// a stream, or an error if the stream name is not safe to use as a path.
+func (b *Builder) buildHistoryDirectory(streamName string) (string, error) {
+	if streamName == "" || streamName[0] == '/' ||
+		filepath.Clean(streamName) != streamName ||
+		strings.HasPrefix(streamName, "..") {
+		return "", errors.New("bad stream name: " + streamName)
+	}
+	return filepath.Join(b.stateDir, historyDirectory, streamName), nil
+}
+
+func (b *Builder) getBuildHistory(streamName string,
+	maxRecords uint) ([]proto.BuildRecord, error) {
+	dirname, err := b.buildHistoryDirectory(streamName)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := listBuildIds(dirname)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]proto.BuildRecord, 0, len(ids))
+	for index := len(ids) - 1; index >= 0; index-- {
+		if maxRecords > 0 && uint(len(records)) >= maxRecords {
+			break
+		}
+		var record buildRecordType
+		err := json.ReadFromFile(
+			filepath.Join(dirname, ids[index], historyRecordFile), &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record.BuildRecord)
+	}
+	return records, nil
+}
+
+func (b *Builder) getBuildLog(streamName, id string) ([]byte, error) {
+	dirname, err := b.buildHistoryDirectory(streamName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse(historyIdFormat, id); err != nil {
+		return nil, errors.New("bad build ID: " + id)
+	}
+	buildLog, err := ioutil.ReadFile(
+		filepath.Join(dirname, id, historyLogFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("unknown build: %s for stream: %s",
+			id, streamName)
+	}
+	return buildLog, err
+}
+
+// listBuildIds returns the IDs of the recorded builds in dirname, oldest
+// first.
+func listBuildIds(dirname string) ([]string, error) {
+	names, err := fsutil.ReadDirnames(dirname, true)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, err := time.Parse(historyIdFormat, name); err == nil {
+			ids = append(ids, name)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// loadBuildHistory will load the most recent recorded build for each stream
+// into lastBuildResults.
+func (b *Builder) loadBuildHistory() error {
+	topDir := filepath.Join(b.stateDir, historyDirectory)
+	latestRecords := make(map[string]buildRecordType)
+	err := filepath.Walk(topDir,
+		func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if fi.IsDir() || fi.Name() != historyRecordFile {
+				return nil
+			}
+			var record buildRecordType
+			if err := json.ReadFromFile(path, &record); err != nil {
+				return err
+			}
+			latest, ok := latestRecords[record.StreamName]
+			if !ok || record.Id > latest.Id {
+				latestRecords[record.StreamName] = record
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	b.buildResultsLock.Lock()
+	defer b.buildResultsLock.Unlock()
+	for streamName, record := range latestRecords {
+		buildLog, _ := b.getBuildLog(streamName, record.Id)
+		b.lastBuildResults[streamName] = buildResultType{
+			record.ImageName,
+			record.StartTime,
+			record.FinishTime,
+			buildLog,
+			buildCacheType{record.BuildKey, record.CacheResult},
+			record.ResourceUsage,
+			liberrors.New(record.Error),
+		}
+	}
+	return nil
+}
+
+// pruneBuildHistory will delete recorded builds for a stream which are older
+// than the retention time or exceed the maximum number of builds to keep.
+func (b *Builder) pruneBuildHistory(dirname string) error {
+	ids, err := listBuildIds(dirname)
+	if err != nil {
+		return err
+	}
+	for index, id := range ids {
+		remaining := uint(len(ids) - index)
+		startTime, _ := time.Parse(historyIdFormat, id)
+		if (b.buildHistoryMaxBuilds < 1 ||
+			remaining <= b.buildHistoryMaxBuilds) &&
+			(b.buildHistoryMaxAge < 1 ||
+				time.Since(startTime) <= b.buildHistoryMaxAge) {
+			break
+		}
+		if err := os.RemoveAll(filepath.Join(dirname, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordBuild will save a build result and its log in the build history.
+func (b *Builder) recordBuild(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation, result buildResultType) error {
+	dirname, err := b.buildHistoryDirectory(request.StreamName)
+	if err != nil {
+		return err
+	}
+	record := buildRecordType{
+		BuildRecord: proto.BuildRecord{
+			CacheResult:   result.cache.result,
+			Error:         liberrors.ErrorToString(result.error),
+			FinishTime:    result.finishTime,
+			Id:            result.startTime.UTC().Format(historyIdFormat),
+			ImageName:     result.imageName,
+			Request:       request,
+			ResourceUsage: result.usage,
+			StartTime:     result.startTime,
+			StreamName:    request.StreamName,
+		},
+		BuildKey: result.cache.key,
+	}
+	if authInfo != nil {
+		record.Username = authInfo.Username
+	}
+	buildDir := filepath.Join(dirname, record.Id)
+	if err := os.MkdirAll(buildDir, fsutil.DirPerms); err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(buildDir, historyLogFile),
+		result.buildLog, fsutil.PublicFilePerms)
+	if err != nil {
+		return err
+	}
+	err = json.WriteToFile(filepath.Join(buildDir, historyRecordFile),
+		fsutil.PublicFilePerms, "    ", record)
+	if err != nil {
+		return err
+	}
+	return b.pruneBuildHistory(dirname)
+}
@@ -20,8 +20,13 @@ import (
 const codeStyle = `background-color: #eee; border: 1px solid #999; display: block; float: left;`
 
 func (stream *bootstrapStream) WriteHtml(writer io.Writer) {
-	fmt.Fprintf(writer, "Bootstrap command: <code>%s</code><br>\n",
-		strings.Join(stream.BootstrapCommand, " "))
+	if stream.OciImage != "" {
+		fmt.Fprintf(writer, "OCI image: <code>%s</code><br>\n",
+			stream.OciImage)
+	} else {
+		fmt.Fprintf(writer, "Bootstrap command: <code>%s</code><br>\n",
+			strings.Join(stream.BootstrapCommand, " "))
+	}
 	if len(stream.FilterLines) > 0 {
 		fmt.Fprintln(writer, "Filter lines:<br>")
 		fmt.Fprintf(writer, "<pre style=\"%s\">\n", codeStyle)
@@ -51,6 +56,9 @@ func (b *Builder) showImageStream(writer io.Writer, streamName string) {
 	}
 	fmt.Fprintf(writer, "<h3>Information for stream: %s</h3>\n", streamName)
 	stream.WriteHtml(writer)
+	fmt.Fprintf(writer,
+		"<p><a href=\"showBuildHistory?%s\">Build history</a><br>\n",
+		streamName)
 }
 
 func (b *Builder) showImageStreams(writer io.Writer) {
@@ -98,7 +106,37 @@ func (b *Builder) writeHtml(writer io.Writer) {
 			failedBuilds[name] = result
 		}
 	}
+	cacheHits := b.buildCacheHits
+	cacheMisses := b.buildCacheMisses
 	b.buildResultsLock.RUnlock()
+	fmt.Fprintf(writer, "Build cache: %d hits, %d misses<p>\n",
+		cacheHits, cacheMisses)
+	if queue := b.getBuildQueue(); len(queue) > 0 {
+		fmt.Fprintln(writer, "Build queue:<br>")
+		fmt.Fprintln(writer, `<table border="1">`)
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintln(writer, "    <th>ID</th>")
+		fmt.Fprintln(writer, "    <th>Image Stream</th>")
+		fmt.Fprintln(writer, "    <th>Priority</th>")
+		fmt.Fprintln(writer, "    <th>State</th>")
+		fmt.Fprintln(writer, "  </tr>")
+		for _, entry := range queue {
+			fmt.Fprintf(writer, "  <tr>\n")
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.Id)
+			fmt.Fprintf(writer, "    <td>%s</td>\n", entry.StreamName)
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.Priority)
+			if entry.StartedAt.IsZero() {
+				fmt.Fprintf(writer, "    <td>queued for %s</td>\n",
+					format.Duration(time.Since(entry.QueuedAt)))
+			} else {
+				fmt.Fprintf(writer, "    <td>building for %s</td>\n",
+					format.Duration(time.Since(entry.StartedAt)))
+			}
+			fmt.Fprintf(writer, "  </tr>\n")
+		}
+		fmt.Fprintln(writer, "</table><br>")
+	}
+	b.writeScheduleHtml(writer)
 	currentTime := time.Now()
 	if len(currentBuilds) > 0 {
 		fmt.Fprintln(writer, "Current image builds:<br>")
@@ -159,6 +197,7 @@ func (b *Builder) writeHtml(writer io.Writer) {
 		fmt.Fprintln(writer, "    <th>Build log</th>")
 		fmt.Fprintln(writer, "    <th>Duration</th>")
 		fmt.Fprintln(writer, "    <th>Age</th>")
+		fmt.Fprintln(writer, "    <th>Cache</th>")
 		fmt.Fprintln(writer, "  </tr>")
 		for _, streamName := range streamNames {
 			result := goodBuilds[streamName]
@@ -174,6 +213,7 @@ func (b *Builder) writeHtml(writer io.Writer) {
 				format.Duration(result.finishTime.Sub(result.startTime)))
 			fmt.Fprintf(writer, "    <td>%s</td>\n",
 				format.Duration(currentTime.Sub(result.finishTime)))
+			fmt.Fprintf(writer, "    <td>%s</td>\n", result.cache.result)
 			fmt.Fprintf(writer, "  </tr>\n")
 		}
 		fmt.Fprintln(writer, "</table><br>")
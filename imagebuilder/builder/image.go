@@ -19,6 +19,7 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/json"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
@@ -26,8 +27,8 @@ import (
 )
 
 func (stream *imageStreamType) build(b *Builder, client *srpc.Client,
-	request proto.BuildImageRequest, buildLog buildLogger) (
-	*image.Image, error) {
+	request proto.BuildImageRequest, cgroup *buildCgroup,
+	buildLog buildLogger) (*image.Image, error) {
 	manifestDirectory, err := stream.getManifest(b, request.StreamName,
 		request.GitBranch, request.Variables, buildLog)
 	if err != nil {
@@ -35,7 +36,7 @@ func (stream *imageStreamType) build(b *Builder, client *srpc.Client,
 	}
 	defer os.RemoveAll(manifestDirectory)
 	img, err := buildImageFromManifest(client, manifestDirectory, request,
-		b.bindMounts, buildLog)
+		b.bindMounts, cgroup, b.reproducibleFilter, buildLog)
 	if err != nil {
 		return nil, err
 	}
@@ -52,8 +53,43 @@ func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 		"IMAGE_STREAM": streamName,
 	},
 		variables)
+	manifestRoot, err := fetchManifest(streamName, stream.ManifestUrl,
+		stream.ManifestDirectory, gitBranch, variableFunc, buildLog)
+	if err != nil {
+		return "", err
+	}
+	var manifestConfig manifestConfigType
+	err = json.ReadFromFile(filepath.Join(manifestRoot, "manifest"),
+		&manifestConfig)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifestRoot, nil
+		}
+		os.RemoveAll(manifestRoot)
+		return "", fmt.Errorf("error reading manifest file: %s", err)
+	}
+	b.setStreamSource(streamName, manifestConfig.SourceImage)
+	if gitBranch == "master" {
+		b.setStreamIncludes(streamName,
+			stream.includeLocations(manifestConfig.Includes, variableFunc))
+	}
+	err = stream.mergeIncludes(streamName, manifestRoot,
+		manifestConfig.Includes, gitBranch, variableFunc, buildLog)
+	if err != nil {
+		os.RemoveAll(manifestRoot)
+		return "", err
+	}
+	return manifestRoot, nil
+}
+
+// fetchManifest will fetch the manifest tree in the manifestDirectory
+// directory of the repository at manifestUrl into a new temporary directory.
+// Variables in manifestUrl and manifestDirectory are expanded.
+func fetchManifest(name, rawManifestUrl, rawManifestDirectory,
+	gitBranch string, variableFunc func(string) string,
+	buildLog io.Writer) (string, error) {
 	manifestRoot, err := makeTempDirectory("",
-		strings.Replace(streamName, "/", "_", -1)+".manifest")
+		strings.Replace(name, "/", "_", -1)+".manifest")
 	if err != nil {
 		return "", err
 	}
@@ -63,8 +99,8 @@ func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 			os.RemoveAll(manifestRoot)
 		}
 	}()
-	manifestDirectory := os.Expand(stream.ManifestDirectory, variableFunc)
-	manifestUrl := os.Expand(stream.ManifestUrl, variableFunc)
+	manifestDirectory := os.Expand(rawManifestDirectory, variableFunc)
+	manifestUrl := os.Expand(rawManifestUrl, variableFunc)
 	if parsedUrl, err := url.Parse(manifestUrl); err == nil {
 		if parsedUrl.Scheme == "dir" {
 			if parsedUrl.Path[0] != '/' {
@@ -84,7 +120,7 @@ func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 		}
 	}
 	fmt.Fprintf(buildLog, "Cloning repository: %s branch: %s\n",
-		stream.ManifestUrl, gitBranch)
+		rawManifestUrl, gitBranch)
 	err = runCommand(buildLog, "", "git", "init", manifestRoot)
 	if err != nil {
 		return "", err
@@ -212,8 +248,9 @@ func runCommand(buildLog io.Writer, cwd string, args ...string) error {
 }
 
 func buildImageFromManifest(client *srpc.Client, manifestDir string,
-	request proto.BuildImageRequest, bindMounts []string,
-	buildLog buildLogger) (*image.Image, error) {
+	request proto.BuildImageRequest, bindMounts []string, cgroup *buildCgroup,
+	reproducibleFilter *filter.Filter, buildLog buildLogger) (
+	*image.Image, error) {
 	// First load all the various manifest files (fail early on error).
 	computedFilesList, err := util.LoadComputedFiles(
 		path.Join(manifestDir, "computed-files.json"))
@@ -240,10 +277,16 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 	defer os.RemoveAll(rootDir)
 	fmt.Fprintf(buildLog, "Created image working directory: %s\n", rootDir)
 	manifest, err := unpackImageAndProcessManifest(client, manifestDir,
-		rootDir, bindMounts, false, buildLog)
+		rootDir, bindMounts, cgroup, false, request.Reproducible, buildLog)
 	if err != nil {
 		return nil, err
 	}
+	var envVars map[string]string
+	var sourceDateEpoch time.Time
+	if request.Reproducible {
+		sourceDateEpoch = manifest.sourceImageInfo.createdOn
+		envVars = sourceDateEpochVariables(sourceDateEpoch)
+	}
 	if fi, err := os.Lstat(filepath.Join(manifestDir, "tests")); err == nil {
 		if fi.IsDir() {
 			testsDir := filepath.Join(rootDir, "tests", request.StreamName)
@@ -256,6 +299,18 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 			}
 		}
 	}
+	testResults, err := runTests(rootDir, bindMounts, cgroup, envVars,
+		buildLog)
+	if err != nil {
+		return nil, err
+	}
+	if request.Reproducible {
+		err := deleteNondeterministicFiles(rootDir, reproducibleFilter,
+			buildLog)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if addFilter {
 		mergeableFilter := &filter.MergeableFilter{}
 		mergeableFilter.Merge(manifest.sourceImageInfo.filter)
@@ -268,15 +323,16 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 		mergeableTriggers.Merge(imageTriggers)
 		imageTriggers = mergeableTriggers.ExportTriggers()
 	}
-	return packImage(client, request, rootDir, manifest.filter,
-		computedFilesList, imageFilter, imageTriggers, buildLog)
+	return packImage(client, request, rootDir, cgroup, manifest.filter,
+		computedFilesList, imageFilter, imageTriggers, testResults,
+		sourceDateEpoch, buildLog)
 }
 
 func buildImageFromManifestAndUpload(client *srpc.Client, manifestDir string,
 	request proto.BuildImageRequest, bindMounts []string,
 	buildLog buildLogger) (*image.Image, string, error) {
 	img, err := buildImageFromManifest(client, manifestDir, request, bindMounts,
-		buildLog)
+		nil, nil, buildLog)
 	if err != nil {
 		return nil, "", err
 	}
@@ -294,7 +350,7 @@ func buildTreeFromManifest(client *srpc.Client, manifestDir string,
 		return "", err
 	}
 	_, err = unpackImageAndProcessManifest(client, manifestDir, rootDir,
-		bindMounts, true, buildLog)
+		bindMounts, nil, true, false, buildLog)
 	if err != nil {
 		os.RemoveAll(rootDir)
 		return "", err
@@ -365,5 +421,6 @@ func unpackImage(client *srpc.Client, streamName string,
 		return nil, err
 	}
 	fmt.Fprintf(buildLog, "Source image: %s\n", imageName)
-	return &sourceImageInfoType{sourceImage.Filter, sourceImage.Triggers}, nil
+	return &sourceImageInfoType{sourceImage.CreatedOn, sourceImage.Filter,
+		sourceImage.Triggers}, nil
 }
@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+)
+
+// Directory trees in a fragment which are merged file by file.
+var fragmentTrees = map[string]struct{}{
+	"files":               {},
+	"post-install-files":  {},
+	"post-scripts-files":  {},
+	"pre-install-scripts": {},
+	"scripts":             {},
+	"tests":               {},
+}
+
+// mergeIncludes will fetch the fragments listed in includes and merge them into
+// manifestDir, in the order they are listed. Fragments without a ManifestUrl
+// are fetched from the repository for the stream, using the same branch.
+func (stream *imageStreamType) mergeIncludes(streamName, manifestDir string,
+	includes []includeType, gitBranch string,
+	variableFunc func(string) string, buildLog io.Writer) error {
+	for index, include := range includes {
+		if include.ManifestDirectory == "" {
+			return fmt.Errorf("include[%d]: no ManifestDirectory", index)
+		}
+		manifestUrl := include.ManifestUrl
+		branch := include.GitBranch
+		if manifestUrl == "" {
+			manifestUrl = stream.ManifestUrl
+			if branch == "" {
+				branch = gitBranch
+			}
+		} else if branch == "" {
+			branch = "master"
+		}
+		fragmentName := os.Expand(include.ManifestDirectory, variableFunc)
+		fmt.Fprintf(buildLog, "Including fragment: %s\n", fragmentName)
+		fragmentDir, err := fetchManifest(streamName, manifestUrl,
+			include.ManifestDirectory, branch, variableFunc, buildLog)
+		if err != nil {
+			return fmt.Errorf("error fetching fragment: %s: %s",
+				fragmentName, err)
+		}
+		err = mergeFragment(manifestDir, fragmentDir)
+		os.RemoveAll(fragmentDir)
+		if err != nil {
+			return fmt.Errorf("error merging fragment: %s: %s",
+				fragmentName, err)
+		}
+	}
+	return nil
+}
+
+// mergeFragment will merge the fragment in fragmentDir into manifestDir. Files
+// which exist in both with different contents are reported as conflicts.
+func mergeFragment(manifestDir, fragmentDir string) error {
+	names, err := listDirectory(fragmentDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := fragmentTrees[name]; ok {
+			err := mergeFragmentTree(filepath.Join(manifestDir, name),
+				filepath.Join(fragmentDir, name), len(fragmentDir))
+			if err != nil {
+				return err
+			}
+		} else if name == "package-list" {
+			err := mergePackageList(filepath.Join(manifestDir, name),
+				filepath.Join(fragmentDir, name))
+			if err != nil {
+				return err
+			}
+		} else if len(name) > 0 && name[0] == '.' {
+			continue
+		} else {
+			return fmt.Errorf("%s: not supported in a fragment", name)
+		}
+	}
+	return nil
+}
+
+func mergeFragmentTree(destDir, sourceDir string, prefixLength int) error {
+	if err := os.MkdirAll(destDir, fsutil.DirPerms); err != nil {
+		return err
+	}
+	return fsutil.CopyTreeWithCopyFunc(destDir, sourceDir,
+		func(destFilename, sourceFilename string, mode os.FileMode) error {
+			same, err := fsutil.CompareFiles(destFilename, sourceFilename)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return fsutil.CopyFile(destFilename, sourceFilename, mode)
+				}
+				return err
+			}
+			if !same {
+				return fmt.Errorf("conflict for: %s",
+					sourceFilename[prefixLength:])
+			}
+			return nil
+		})
+}
+
+// mergePackageList will append the packages in sourceFilename which are not
+// already listed in destFilename.
+func mergePackageList(destFilename, sourceFilename string) error {
+	sourcePackages, err := fsutil.LoadLines(sourceFilename)
+	if err != nil {
+		return err
+	}
+	destPackages, err := fsutil.LoadLines(destFilename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	packages := make(map[string]struct{}, len(destPackages))
+	for _, pkg := range destPackages {
+		packages[pkg] = struct{}{}
+	}
+	for _, pkg := range sourcePackages {
+		if _, ok := packages[pkg]; !ok {
+			packages[pkg] = struct{}{}
+			destPackages = append(destPackages, pkg)
+		}
+	}
+	return ioutil.WriteFile(destFilename,
+		[]byte(strings.Join(destPackages, "\n")+"\n"), fsutil.PublicFilePerms)
+}
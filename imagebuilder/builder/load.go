@@ -14,6 +14,7 @@ import (
 
 	"github.com/Symantec/Dominator/imageserver/client"
 	"github.com/Symantec/Dominator/lib/configwatch"
+	"github.com/Symantec/Dominator/lib/filter"
 	libjson "github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/slavedriver"
@@ -58,6 +59,37 @@ func load(confUrl, variablesFile, stateDir, imageServerAddress string,
 	if variables == nil {
 		variables = make(map[string]string)
 	}
+	maxConcurrentBuilds := masterConfiguration.MaxConcurrentBuilds
+	if maxConcurrentBuilds < 1 {
+		maxConcurrentBuilds = 1
+	}
+	buildHistoryMaxAgeDays := masterConfiguration.BuildHistoryMaxAgeDays
+	if buildHistoryMaxAgeDays < 1 {
+		buildHistoryMaxAgeDays = 30
+	}
+	buildHistoryMaxAge := time.Hour * 24 * time.Duration(buildHistoryMaxAgeDays)
+	buildHistoryMaxBuilds := masterConfiguration.BuildHistoryMaxBuilds
+	if buildHistoryMaxBuilds < 1 {
+		buildHistoryMaxBuilds = 100
+	}
+	cgroupDirectory := masterConfiguration.CgroupDirectory
+	if cgroupDirectory == "" {
+		cgroupDirectory = defaultCgroupDirectory
+	}
+	if err := setupCgroups(cgroupDirectory); err != nil {
+		logger.Printf("Error setting up cgroups, resource limits disabled: %s\n",
+			err)
+		cgroupDirectory = ""
+	}
+	reproducibleFilterLines := masterConfiguration.ReproducibleFilterLines
+	if len(reproducibleFilterLines) < 1 {
+		reproducibleFilterLines = defaultReproducibleFilterLines
+	}
+	reproducibleFilter, err := filter.New(reproducibleFilterLines)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling ReproducibleFilterLines: %s",
+			err)
+	}
 	b := &Builder{
 		bindMounts:                masterConfiguration.BindMounts,
 		stateDir:                  stateDir,
@@ -69,13 +101,26 @@ func load(confUrl, variablesFile, stateDir, imageServerAddress string,
 		slaveDriver:               slaveDriver,
 		currentBuildLogs:          make(map[string]*bytes.Buffer),
 		lastBuildResults:          make(map[string]buildResultType),
+		buildHistoryMaxAge:        buildHistoryMaxAge,
+		buildHistoryMaxBuilds:     buildHistoryMaxBuilds,
+		cgroupDirectory:           cgroupDirectory,
+		defaultResourceLimits:     masterConfiguration.DefaultResourceLimits,
 		packagerTypes:             masterConfiguration.PackagerTypes,
+		reproducibleFilter:        reproducibleFilter,
+		scheduledBuilds:           make(map[string]*scheduledBuildType),
+		imageRebuildInterval:      imageRebuildInterval,
+		maxConcurrentBuilds:       maxConcurrentBuilds,
+		streamIncludes:            make(map[string][]manifestLocation),
+		streamSources:             make(map[string]string),
 		variables:                 variables,
 	}
 	for name, stream := range b.bootstrapStreams {
 		stream.builder = b
 		stream.name = name
 	}
+	if err := b.loadBuildHistory(); err != nil {
+		logger.Printf("Error loading build history: %s\n", err)
+	}
 	imageStreamsConfigChannel, err := configwatch.WatchWithCache(
 		masterConfiguration.ImageStreamsUrl,
 		time.Second*time.Duration(
@@ -87,6 +132,7 @@ func load(confUrl, variablesFile, stateDir, imageServerAddress string,
 	}
 	go b.watchConfigLoop(imageStreamsConfigChannel)
 	go b.rebuildImages(imageRebuildInterval)
+	go b.runSchedules()
 	return b, nil
 }
 
@@ -120,16 +166,26 @@ func masterConfiguration(url string) (*masterConfigurationType, error) {
 		return nil, fmt.Errorf("error reading configuration from: %s: %s",
 			url, err)
 	}
-	for _, stream := range configuration.BootstrapStreams {
+	for name, stream := range configuration.BootstrapStreams {
 		if _, ok := configuration.PackagerTypes[stream.PackagerType]; !ok {
 			return nil, fmt.Errorf("packager type: \"%s\" unknown",
 				stream.PackagerType)
 		}
+		if (stream.OciImage == "") == (len(stream.BootstrapCommand) < 1) {
+			return nil, fmt.Errorf(
+				"stream: %s: specify one of BootstrapCommand or OciImage",
+				name)
+		}
 		if stream.Filter != nil {
 			if err := stream.Filter.Compile(); err != nil {
 				return nil, err
 			}
 		}
+		if stream.RebuildSchedule != nil {
+			if err := stream.RebuildSchedule.check(); err != nil {
+				return nil, fmt.Errorf("stream: %s: %s", name, err)
+			}
+		}
 	}
 	return &configuration, nil
 }
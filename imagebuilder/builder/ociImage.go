@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem/util"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+	"github.com/Symantec/Dominator/lib/oci"
+)
+
+type objectAdder struct {
+	objectServer *filesystem.ObjectServer
+}
+
+func (h *objectAdder) Hash(reader io.Reader, length uint64) (
+	hash.Hash, error) {
+	hashVal, _, err := h.objectServer.AddObject(reader, length, nil)
+	return hashVal, err
+}
+
+// unpackOciImage will unpack the OCI image layout or docker save tarball in
+// source into rootDir. Objects are staged in a temporary object store.
+func unpackOciImage(source, rootDir string, buildLog io.Writer) error {
+	startTime := time.Now()
+	fmt.Fprintf(buildLog, "Importing OCI image: %s\n", source)
+	objectsDir, err := makeTempDirectory("", "oci-objects")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(objectsDir)
+	logger := stdlog.New(buildLog, "", 0)
+	objectServer, err := filesystem.NewObjectServer(objectsDir, logger)
+	if err != nil {
+		return err
+	}
+	img, err := oci.Decode(source, &objectAdder{objectServer}, nil, logger)
+	if err != nil {
+		return fmt.Errorf("error decoding OCI image: %s", err)
+	}
+	if err := util.Unpack(img.FileSystem, objectServer, rootDir,
+		logger); err != nil {
+		return fmt.Errorf("error unpacking OCI image: %s", err)
+	}
+	fmt.Fprintf(buildLog, "Imported OCI image (%s) in %s\n",
+		format.FormatBytes(img.FileSystem.TotalDataBytes),
+		format.Duration(time.Since(startTime)))
+	return nil
+}
@@ -89,8 +89,8 @@ func makeMountPoints(rootDir string, bindMounts []string,
 }
 
 func unpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
-	rootDir string, bindMounts []string, applyFilter bool,
-	buildLog io.Writer) (manifestType, error) {
+	rootDir string, bindMounts []string, cgroup *buildCgroup,
+	applyFilter, reproducible bool, buildLog io.Writer) (manifestType, error) {
 	manifestFile := filepath.Join(manifestDir, "manifest")
 	var manifestConfig manifestConfigType
 	if err := json.ReadFromFile(manifestFile, &manifestConfig); err != nil {
@@ -103,8 +103,13 @@ func unpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 		return manifestType{},
 			errors.New("error unpacking image: " + err.Error())
 	}
+	var envVars map[string]string
+	if reproducible {
+		envVars = sourceDateEpochVariables(sourceImageInfo.createdOn)
+	}
 	startTime := time.Now()
-	err = processManifest(manifestDir, rootDir, bindMounts, buildLog)
+	err = processManifest(manifestDir, rootDir, bindMounts, cgroup, envVars,
+		buildLog)
 	if err != nil {
 		return manifestType{},
 			errors.New("error processing manifest: " + err.Error())
@@ -121,7 +126,7 @@ func unpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 }
 
 func processManifest(manifestDir, rootDir string, bindMounts []string,
-	buildLog io.Writer) error {
+	cgroup *buildCgroup, envVars map[string]string, buildLog io.Writer) error {
 	if err := copyFiles(manifestDir, "files", rootDir, buildLog); err != nil {
 		return err
 	}
@@ -139,8 +144,8 @@ func processManifest(manifestDir, rootDir string, bindMounts []string,
 		return err
 	}
 	defer file.Close()
-	err = runInTarget(file, buildLog, rootDir, packagerPathname, "copy-in",
-		"/etc/resolv.conf")
+	err = runInTarget(file, buildLog, rootDir, cgroup, nil, packagerPathname,
+		"copy-in", "/etc/resolv.conf")
 	if err != nil {
 		return fmt.Errorf("error copying in /etc/resolv.conf: %s", err)
 	}
@@ -152,17 +157,19 @@ func processManifest(manifestDir, rootDir string, bindMounts []string,
 		}
 	}
 	if len(packageList) > 0 {
-		err := updatePackageDatabase(rootDir, bindMounts, buildLog)
+		err := updatePackageDatabase(rootDir, bindMounts, cgroup, envVars,
+			buildLog)
 		if err != nil {
 			return err
 		}
 	}
 	err = runScripts(manifestDir, "pre-install-scripts", rootDir, bindMounts,
-		buildLog)
+		cgroup, envVars, buildLog)
 	if err != nil {
 		return err
 	}
-	err = installPackages(packageList, rootDir, bindMounts, buildLog)
+	err = installPackages(packageList, rootDir, bindMounts, cgroup, envVars,
+		buildLog)
 	if err != nil {
 		return errors.New("error installing packages: " + err.Error())
 	}
@@ -170,7 +177,8 @@ func processManifest(manifestDir, rootDir string, bindMounts []string,
 	if err != nil {
 		return err
 	}
-	err = runScripts(manifestDir, "scripts", rootDir, bindMounts, buildLog)
+	err = runScripts(manifestDir, "scripts", rootDir, bindMounts, cgroup,
+		envVars, buildLog)
 	if err != nil {
 		return err
 	}
@@ -178,10 +186,10 @@ func processManifest(manifestDir, rootDir string, bindMounts []string,
 	if err != nil {
 		return err
 	}
-	if err := cleanPackages(rootDir, buildLog); err != nil {
+	if err := cleanPackages(rootDir, cgroup, buildLog); err != nil {
 		return err
 	}
-	if err := clearResolvConf(buildLog, rootDir); err != nil {
+	if err := clearResolvConf(buildLog, rootDir, cgroup); err != nil {
 		return err
 	}
 	return deleteDirectories(directoriesToDelete)
@@ -217,7 +225,7 @@ func copyFile(destFilename, sourceFilename string, mode os.FileMode,
 }
 
 func installPackages(packageList []string, rootDir string, bindMounts []string,
-	buildLog io.Writer) error {
+	cgroup *buildCgroup, envVars map[string]string, buildLog io.Writer) error {
 	if len(packageList) < 1 { // Nothing to do.
 		fmt.Fprintln(buildLog, "\nNo packages to install")
 		return nil
@@ -225,7 +233,7 @@ func installPackages(packageList []string, rootDir string, bindMounts []string,
 	fmt.Fprintln(buildLog, "\nUpgrading packages:")
 	startTime := time.Now()
 	err := runInTargetWithBindMounts(nil, buildLog, rootDir, bindMounts,
-		packagerPathname, "upgrade")
+		cgroup, envVars, packagerPathname, "upgrade")
 	if err != nil {
 		return errors.New("error upgrading: " + err.Error())
 	}
@@ -238,7 +246,7 @@ func installPackages(packageList []string, rootDir string, bindMounts []string,
 	args := []string{"install"}
 	args = append(args, packageList...)
 	err = runInTargetWithBindMounts(nil, buildLog, rootDir, bindMounts,
-		packagerPathname, args...)
+		cgroup, envVars, packagerPathname, args...)
 	if err != nil {
 		return errors.New("error installing: " + err.Error())
 	}
@@ -248,7 +256,7 @@ func installPackages(packageList []string, rootDir string, bindMounts []string,
 }
 
 func runScripts(manifestDir, dirname, rootDir string, bindMounts []string,
-	buildLog io.Writer) error {
+	cgroup *buildCgroup, envVars map[string]string, buildLog io.Writer) error {
 	scriptsDir := filepath.Join(manifestDir, dirname)
 	file, err := os.Open(scriptsDir)
 	if err != nil {
@@ -290,7 +298,8 @@ func runScripts(manifestDir, dirname, rootDir string, bindMounts []string,
 		fmt.Fprintf(buildLog, "Running script: %s\n", name)
 		startTime := time.Now()
 		err := runInTargetWithBindMounts(nil, buildLog, rootDir, bindMounts,
-			packagerPathname, "run", filepath.Join("/.scripts", name))
+			cgroup, envVars, packagerPathname, "run",
+			filepath.Join("/.scripts", name))
 		if err != nil {
 			return errors.New("error running script: " + name + ": " +
 				err.Error())
@@ -307,11 +316,11 @@ func runScripts(manifestDir, dirname, rootDir string, bindMounts []string,
 }
 
 func updatePackageDatabase(rootDir string, bindMounts []string,
-	buildLog io.Writer) error {
+	cgroup *buildCgroup, envVars map[string]string, buildLog io.Writer) error {
 	fmt.Fprintln(buildLog, "\nUpdating package database:")
 	startTime := time.Now()
 	err := runInTargetWithBindMounts(nil, buildLog, rootDir, bindMounts,
-		packagerPathname, "update")
+		cgroup, envVars, packagerPathname, "update")
 	if err != nil {
 		return errors.New("error updating: " + err.Error())
 	}
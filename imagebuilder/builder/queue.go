@@ -0,0 +1,356 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+type buildQueueEntry struct {
+	id         uint64
+	request    proto.BuildImageRequest
+	authInfo   *srpc.AuthInformation
+	requesters []string
+	queuedAt   time.Time
+	startedAt  time.Time // Zero if not yet started.
+	logWriters []io.Writer
+	waiters    []chan<- queuedBuildResult
+}
+
+type queuedBuildResult struct {
+	image *image.Image
+	name  string
+	err   error
+}
+
+// enqueueBuild will add a build request to the queue, or join an identical
+// request which has not yet started. The result is sent to the returned
+// channel when the build completes.
+func (b *Builder) enqueueBuild(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation,
+	logWriter io.Writer) (<-chan queuedBuildResult, error) {
+	builder := b.getImageBuilderWithReload(request.StreamName)
+	if builder == nil {
+		return nil, errors.New("unknown stream: " + request.StreamName)
+	}
+	if err := checkPermission(builder, request, authInfo); err != nil {
+		return nil, err
+	}
+	resultChannel := make(chan queuedBuildResult, 1)
+	b.buildQueueLock.Lock()
+	entry := b.findQueuedBuild(request)
+	if entry == nil {
+		b.nextBuildId++
+		entry = &buildQueueEntry{
+			id:       b.nextBuildId,
+			request:  request,
+			authInfo: authInfo,
+			queuedAt: time.Now(),
+		}
+		b.buildQueue = append(b.buildQueue, entry)
+	} else {
+		entry.merge(request)
+	}
+	if authInfo != nil {
+		entry.requesters = append(entry.requesters, authInfo.Username)
+	}
+	if logWriter != nil {
+		entry.logWriters = append(entry.logWriters, logWriter)
+	}
+	entry.waiters = append(entry.waiters, resultChannel)
+	id := entry.id
+	b.scheduleBuilds()
+	b.buildQueueLock.Unlock()
+	if logWriter != nil {
+		fmt.Fprintf(logWriter, "Queued build: %d for stream: %s\n",
+			id, request.StreamName)
+	}
+	return resultChannel, nil
+}
+
+// buildAndWait will queue a build and wait for it to complete. It must be
+// called from a running build. The build slot of the caller is released while
+// waiting, so that the queued build may run.
+func (b *Builder) buildAndWait(request proto.BuildImageRequest,
+	logWriter io.Writer) (*image.Image, string, error) {
+	resultChannel, err := b.enqueueBuild(request, nil, logWriter)
+	if err != nil {
+		return nil, "", err
+	}
+	b.buildQueueLock.Lock()
+	b.numRunningBuilds--
+	b.scheduleBuilds()
+	b.buildQueueLock.Unlock()
+	result := <-resultChannel
+	b.buildQueueLock.Lock()
+	b.numRunningBuilds++
+	b.buildQueueLock.Unlock()
+	return result.image, result.name, result.err
+}
+
+func (b *Builder) cancelBuild(id uint64,
+	authInfo *srpc.AuthInformation) error {
+	b.buildQueueLock.Lock()
+	defer b.buildQueueLock.Unlock()
+	for index, entry := range b.buildQueue {
+		if entry.id != id {
+			continue
+		}
+		if !entry.startedAt.IsZero() {
+			return fmt.Errorf("build: %d has already started", id)
+		}
+		if !entry.canCancel(authInfo) {
+			return fmt.Errorf("no permission to cancel build: %d", id)
+		}
+		b.removeQueuedBuild(index)
+		for _, waiter := range entry.waiters {
+			waiter <- queuedBuildResult{err: fmt.Errorf(
+				"build: %d for stream: %s cancelled",
+				id, entry.request.StreamName)}
+		}
+		b.scheduleBuilds()
+		return nil
+	}
+	return fmt.Errorf("unknown build: %d", id)
+}
+
+// cascadeBuilds will queue builds for the image streams which are
+// automatically rebuilt and which use streamName as their source, if a new
+// image was built for streamName.
+func (b *Builder) cascadeBuilds(streamName string) {
+	b.buildResultsLock.RLock()
+	result := b.lastBuildResults[streamName]
+	b.buildResultsLock.RUnlock()
+	if result.error != nil || result.imageName == "" ||
+		result.cache.result == cacheResultHit ||
+		result.cache.result == cacheResultIdentical {
+		return
+	}
+	for _, childName := range b.listStreamsToAutoRebuild() {
+		b.buildQueueLock.Lock()
+		sourceName := b.streamSources[childName]
+		b.buildQueueLock.Unlock()
+		if sourceName != streamName {
+			continue
+		}
+		if err := b.queueAutomaticBuild(childName, 0); err != nil {
+			b.logger.Printf("Error queueing build for: %s: %s\n",
+				childName, err)
+		} else {
+			b.logger.Printf("Queued build for: %s after new image for: %s\n",
+				childName, streamName)
+		}
+	}
+}
+
+// findQueuedBuild will find a build which has not started and which will
+// build the same image as request. The lock must be held.
+func (b *Builder) findQueuedBuild(
+	request proto.BuildImageRequest) *buildQueueEntry {
+	for _, entry := range b.buildQueue {
+		if !entry.startedAt.IsZero() {
+			continue
+		}
+		queued := entry.request
+		if queued.StreamName != request.StreamName ||
+			queued.GitBranch != request.GitBranch ||
+			queued.DisableRecursiveBuild != request.DisableRecursiveBuild ||
+			queued.Reproducible != request.Reproducible ||
+			queued.ReturnImage != request.ReturnImage {
+			continue
+		}
+		if len(queued.Variables) > 0 || len(request.Variables) > 0 {
+			if !reflect.DeepEqual(queued.Variables, request.Variables) {
+				continue
+			}
+		}
+		return entry
+	}
+	return nil
+}
+
+func (b *Builder) getBuildQueue() []proto.BuildQueueEntry {
+	b.buildQueueLock.Lock()
+	defer b.buildQueueLock.Unlock()
+	entries := make([]proto.BuildQueueEntry, 0, len(b.buildQueue))
+	for _, entry := range b.buildQueue {
+		entries = append(entries, proto.BuildQueueEntry{
+			Id:         entry.id,
+			StreamName: entry.request.StreamName,
+			GitBranch:  entry.request.GitBranch,
+			Priority:   entry.request.Priority,
+			QueuedAt:   entry.queuedAt,
+			StartedAt:  entry.startedAt,
+			Requesters: entry.requesters,
+		})
+	}
+	return entries
+}
+
+// isSourcePending returns true if a build for any of the (transitive) source
+// image streams for streamName is queued or running. The lock must be held.
+func (b *Builder) isSourcePending(streamName string) bool {
+	visited := make(map[string]struct{})
+	for {
+		visited[streamName] = struct{}{}
+		streamName = b.streamSources[streamName]
+		if streamName == "" {
+			return false
+		}
+		if _, ok := visited[streamName]; ok {
+			return false // Dependency loop.
+		}
+		for _, entry := range b.buildQueue {
+			if entry.request.StreamName == streamName {
+				return true
+			}
+		}
+	}
+}
+
+// queueAutomaticBuild will queue a build for an image stream which was not
+// requested by a user. If expiresIn is zero, the image expires after two
+// rebuild intervals.
+func (b *Builder) queueAutomaticBuild(streamName string,
+	expiresIn time.Duration) error {
+	if expiresIn < 1 {
+		expiresIn = b.imageRebuildInterval * 2
+	}
+	if expiresIn < 1 {
+		expiresIn = time.Hour
+	}
+	_, err := b.enqueueBuild(proto.BuildImageRequest{
+		StreamName: streamName,
+		ExpiresIn:  expiresIn,
+	},
+		nil, nil)
+	return err
+}
+
+// removeQueuedBuild will remove an entry from the queue. The lock must be
+// held.
+func (b *Builder) removeQueuedBuild(index int) {
+	copy(b.buildQueue[index:], b.buildQueue[index+1:])
+	b.buildQueue[len(b.buildQueue)-1] = nil
+	b.buildQueue = b.buildQueue[:len(b.buildQueue)-1]
+}
+
+func (b *Builder) runQueuedBuild(entry *buildQueueEntry) {
+	var logWriter io.Writer
+	switch len(entry.logWriters) {
+	case 0:
+	case 1:
+		logWriter = entry.logWriters[0]
+	default:
+		logWriter = io.MultiWriter(entry.logWriters...)
+	}
+	img, name, err := b.buildWithNewClient(entry.request, entry.authInfo,
+		logWriter)
+	b.buildQueueLock.Lock()
+	b.numRunningBuilds--
+	for index, queuedEntry := range b.buildQueue {
+		if queuedEntry == entry {
+			b.removeQueuedBuild(index)
+			break
+		}
+	}
+	b.scheduleBuilds()
+	waiters := entry.waiters
+	b.buildQueueLock.Unlock()
+	for _, waiter := range waiters {
+		waiter <- queuedBuildResult{img, name, err}
+	}
+	if err == nil {
+		b.cascadeBuilds(entry.request.StreamName)
+	}
+}
+
+// isStreamRunning returns true if a build for streamName has started. Builds
+// of the same stream share the current log and last result, so they must not
+// run concurrently. The lock must be held.
+func (b *Builder) isStreamRunning(streamName string) bool {
+	for _, entry := range b.buildQueue {
+		if !entry.startedAt.IsZero() && entry.request.StreamName == streamName {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleBuilds will start the highest priority builds which have no pending
+// source image builds and no running build for the same stream, up to the
+// concurrency limit. Builds with the same priority are started in the order
+// they were queued. The lock must be held.
+func (b *Builder) scheduleBuilds() {
+	for b.maxConcurrentBuilds < 1 ||
+		b.numRunningBuilds < b.maxConcurrentBuilds {
+		var selected *buildQueueEntry
+		for _, entry := range b.buildQueue {
+			if !entry.startedAt.IsZero() ||
+				b.isStreamRunning(entry.request.StreamName) ||
+				b.isSourcePending(entry.request.StreamName) {
+				continue
+			}
+			if selected == nil ||
+				entry.request.Priority > selected.request.Priority {
+				selected = entry
+			}
+		}
+		if selected == nil {
+			return
+		}
+		selected.startedAt = time.Now()
+		b.numRunningBuilds++
+		go b.runQueuedBuild(selected)
+	}
+}
+
+// setStreamIncludes will record the locations of the fragments included by
+// the manifest for an image stream.
+func (b *Builder) setStreamIncludes(streamName string,
+	locations []manifestLocation) {
+	b.buildQueueLock.Lock()
+	defer b.buildQueueLock.Unlock()
+	b.streamIncludes[streamName] = locations
+}
+
+// setStreamSource will record the source image stream for an image stream.
+func (b *Builder) setStreamSource(streamName, sourceName string) {
+	b.buildQueueLock.Lock()
+	defer b.buildQueueLock.Unlock()
+	b.streamSources[streamName] = sourceName
+}
+
+func (entry *buildQueueEntry) canCancel(authInfo *srpc.AuthInformation) bool {
+	if authInfo == nil || authInfo.HaveMethodAccess {
+		return true
+	}
+	for _, requester := range entry.requesters {
+		if requester == authInfo.Username {
+			return true
+		}
+	}
+	return false
+}
+
+// merge will merge the parameters of another request for the same image into
+// the entry, so that the build satisfies both requests.
+func (entry *buildQueueEntry) merge(request proto.BuildImageRequest) {
+	if request.Priority > entry.request.Priority {
+		entry.request.Priority = request.Priority
+	}
+	if entry.request.ExpiresIn > 0 && (request.ExpiresIn < 1 ||
+		request.ExpiresIn > entry.request.ExpiresIn) {
+		entry.request.ExpiresIn = request.ExpiresIn
+	}
+	if request.MaxSourceAge > 0 && (entry.request.MaxSourceAge < 1 ||
+		request.MaxSourceAge < entry.request.MaxSourceAge) {
+		entry.request.MaxSourceAge = request.MaxSourceAge
+	}
+}
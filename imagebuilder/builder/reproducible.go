@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filesystem/util"
+	"github.com/Symantec/Dominator/lib/filter"
+)
+
+// Files which are rewritten by package managers and system tools every time
+// they run and which would otherwise make every build differ.
+var defaultReproducibleFilterLines = []string{
+	"/var/cache/debconf/.*-old$",
+	"/var/cache/ldconfig/aux-cache$",
+	"/var/lib/dpkg/.*-old$",
+	"/var/lib/rpm/__db\\..*",
+	"/var/log/.*\\.log$",
+	"/var/log/apt/.*",
+	"/var/log/(btmp|lastlog|wtmp)$",
+}
+
+// clampMtimes will set the modification time of every inode which is newer
+// than sourceDateEpoch to sourceDateEpoch. The number of inodes changed is
+// returned.
+func clampMtimes(fs *filesystem.FileSystem, sourceDateEpoch time.Time) uint {
+	seconds := sourceDateEpoch.Unix()
+	var numClamped uint
+	for _, inode := range fs.InodeTable {
+		switch inode := inode.(type) {
+		case *filesystem.RegularInode:
+			if inode.MtimeSeconds > seconds ||
+				(inode.MtimeSeconds == seconds && inode.MtimeNanoSeconds > 0) {
+				inode.MtimeSeconds = seconds
+				inode.MtimeNanoSeconds = 0
+				numClamped++
+			}
+		case *filesystem.SpecialInode:
+			if inode.MtimeSeconds > seconds ||
+				(inode.MtimeSeconds == seconds && inode.MtimeNanoSeconds > 0) {
+				inode.MtimeSeconds = seconds
+				inode.MtimeNanoSeconds = 0
+				numClamped++
+			}
+		}
+	}
+	return numClamped
+}
+
+func deleteNondeterministicFiles(rootDir string, filt *filter.Filter,
+	buildLog io.Writer) error {
+	if filt == nil {
+		return nil
+	}
+	if err := util.DeletedFilteredFiles(rootDir, filt); err != nil {
+		return fmt.Errorf("error deleting nondeterministic files: %s", err)
+	}
+	fmt.Fprintln(buildLog, "Deleted nondeterministic files")
+	return nil
+}
+
+// renumberInodes will renumber the inodes in the order they are found in a
+// depth-first walk of the directory tree, so that the inode numbers do not
+// depend on the inode numbers in the build file-system. Inode pointers are not
+// changed. This must be called before any of the file-system tables are built.
+func renumberInodes(fs *filesystem.FileSystem) {
+	inodeTable := make(filesystem.InodeTable, len(fs.InodeTable))
+	inodeNumbers := make(map[uint64]uint64, len(fs.InodeTable))
+	renumberDirectory(&fs.DirectoryInode, fs.InodeTable, inodeTable,
+		inodeNumbers)
+	fs.InodeTable = inodeTable
+}
+
+func renumberDirectory(directory *filesystem.DirectoryInode,
+	oldTable, newTable filesystem.InodeTable, inodeNumbers map[uint64]uint64) {
+	for _, dirent := range directory.EntryList {
+		if inum, ok := inodeNumbers[dirent.InodeNumber]; ok {
+			dirent.InodeNumber = inum // Hard link to an inode already seen.
+			continue
+		}
+		inum := uint64(len(inodeNumbers)) + 1
+		inode := oldTable[dirent.InodeNumber]
+		inodeNumbers[dirent.InodeNumber] = inum
+		newTable[inum] = inode
+		dirent.InodeNumber = inum
+		if inode, ok := inode.(*filesystem.DirectoryInode); ok {
+			renumberDirectory(inode, oldTable, newTable, inodeNumbers)
+		}
+	}
+}
+
+func sourceDateEpochVariables(sourceDateEpoch time.Time) map[string]string {
+	return map[string]string{
+		"SOURCE_DATE_EPOCH": strconv.FormatInt(sourceDateEpoch.Unix(), 10),
+	}
+}
@@ -0,0 +1,309 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/cron"
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+type rebuildScheduleType struct {
+	Cron          string   `json:",omitempty"`
+	JitterSeconds uint     `json:",omitempty"`
+	TimeWindows   []string `json:",omitempty"` // HH:MM-HH:MM, local time.
+}
+
+type scheduledBuildType struct {
+	schedule rebuildScheduleType
+	nextTime time.Time
+	err      error
+}
+
+type timeWindow struct {
+	start time.Duration // Since midnight.
+	end   time.Duration // Since midnight, may be less than start.
+}
+
+// check will verify that the cron expression and time windows are valid.
+func (schedule rebuildScheduleType) check() error {
+	if schedule.Cron != "" {
+		if _, err := cron.Parse(schedule.Cron); err != nil {
+			return err
+		}
+	}
+	_, err := parseTimeWindows(schedule.TimeWindows)
+	return err
+}
+
+// next returns the time after t when the next build should start. If there
+// is no cron expression, builds are spaced interval apart. The time is moved
+// to the start of the next time window if needed and jitter is then added.
+func (schedule rebuildScheduleType) next(t time.Time,
+	interval time.Duration) (time.Time, error) {
+	var nextTime time.Time
+	if schedule.Cron == "" {
+		if interval < 1 {
+			return time.Time{},
+				fmt.Errorf("no cron expression and no rebuild interval")
+		}
+		nextTime = t.Add(interval)
+	} else {
+		cronSchedule, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if nextTime = cronSchedule.Next(t); nextTime.IsZero() {
+			return time.Time{},
+				fmt.Errorf("cron expression: \"%s\" never matches",
+					schedule.Cron)
+		}
+	}
+	windows, err := parseTimeWindows(schedule.TimeWindows)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(windows) > 0 {
+		var earliest time.Time
+		for _, window := range windows {
+			start := window.nextStart(nextTime)
+			if earliest.IsZero() || start.Before(earliest) {
+				earliest = start
+			}
+		}
+		nextTime = earliest
+	}
+	if schedule.JitterSeconds > 0 {
+		nextTime = nextTime.Add(time.Second *
+			time.Duration(rand.Int63n(int64(schedule.JitterSeconds))))
+	}
+	return nextTime, nil
+}
+
+func (schedule rebuildScheduleType) String() string {
+	var parts []string
+	if schedule.Cron != "" {
+		parts = append(parts, "cron: "+schedule.Cron)
+	}
+	if len(schedule.TimeWindows) > 0 {
+		parts = append(parts,
+			"windows: "+strings.Join(schedule.TimeWindows, ","))
+	}
+	if schedule.JitterSeconds > 0 {
+		parts = append(parts, "jitter: "+format.Duration(
+			time.Second*time.Duration(schedule.JitterSeconds)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseTimeOfDay(str string) (time.Duration, error) {
+	fields := strings.Split(str, ":")
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("bad time of day: %s", str)
+	}
+	hour, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil || hour > 23 {
+		return 0, fmt.Errorf("bad hour: %s", str)
+	}
+	minute, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil || minute > 59 {
+		return 0, fmt.Errorf("bad minute: %s", str)
+	}
+	return time.Hour*time.Duration(hour) + time.Minute*time.Duration(minute),
+		nil
+}
+
+func parseTimeWindows(windowStrings []string) ([]timeWindow, error) {
+	windows := make([]timeWindow, 0, len(windowStrings))
+	for _, windowString := range windowStrings {
+		times := strings.Split(windowString, "-")
+		if len(times) != 2 {
+			return nil, fmt.Errorf("bad time window: %s", windowString)
+		}
+		start, err := parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, timeWindow{start, end})
+	}
+	return windows, nil
+}
+
+// nextStart returns t if it is inside the window, else the next start of the
+// window after t.
+func (window timeWindow) nextStart(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0,
+		t.Location())
+	sinceMidnight := t.Sub(midnight)
+	if window.start <= window.end {
+		if sinceMidnight >= window.start && sinceMidnight < window.end {
+			return t
+		}
+	} else if sinceMidnight >= window.start || sinceMidnight < window.end {
+		return t // Window spans midnight.
+	}
+	start := midnight.Add(window.start)
+	if !start.After(t) {
+		start = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0,
+			t.Location()).Add(window.start)
+	}
+	return start
+}
+
+// checkSchedules will queue builds for the image streams with rebuild
+// schedules which are due, and will compute their next build times.
+func (b *Builder) checkSchedules(now time.Time) time.Time {
+	schedules := b.listRebuildSchedules()
+	type dueBuild struct {
+		streamName string
+		expiresIn  time.Duration
+	}
+	var dueBuilds []dueBuild
+	nextCheck := now.Add(time.Minute)
+	b.scheduleLock.Lock()
+	for streamName := range b.scheduledBuilds {
+		if _, ok := schedules[streamName]; !ok {
+			delete(b.scheduledBuilds, streamName)
+		}
+	}
+	for streamName, schedule := range schedules {
+		scheduled := b.scheduledBuilds[streamName]
+		if scheduled == nil ||
+			!reflect.DeepEqual(scheduled.schedule, *schedule) {
+			scheduled = &scheduledBuildType{schedule: *schedule}
+			scheduled.nextTime, scheduled.err = schedule.next(now,
+				b.imageRebuildInterval)
+			if scheduled.err != nil {
+				b.logger.Printf("Error scheduling rebuilds for: %s: %s\n",
+					streamName, scheduled.err)
+			}
+			b.scheduledBuilds[streamName] = scheduled
+		}
+		if scheduled.err != nil {
+			continue
+		}
+		if !now.Before(scheduled.nextTime) {
+			scheduled.nextTime, scheduled.err = schedule.next(now,
+				b.imageRebuildInterval)
+			expiresIn := scheduled.nextTime.Sub(now) * 2
+			if expiresIn < time.Hour {
+				expiresIn = time.Hour
+			}
+			dueBuilds = append(dueBuilds, dueBuild{streamName, expiresIn})
+		}
+		if scheduled.err == nil && scheduled.nextTime.Before(nextCheck) {
+			nextCheck = scheduled.nextTime
+		}
+	}
+	b.scheduleLock.Unlock()
+	for _, build := range dueBuilds {
+		if err := b.queueAutomaticBuild(build.streamName,
+			build.expiresIn); err != nil {
+			b.logger.Printf("Error queueing scheduled build for: %s: %s\n",
+				build.streamName, err)
+		} else {
+			b.logger.Printf("Queued scheduled build for: %s\n",
+				build.streamName)
+		}
+	}
+	return nextCheck
+}
+
+func (b *Builder) getRebuildSchedule(streamName string) *rebuildScheduleType {
+	b.streamsLock.RLock()
+	defer b.streamsLock.RUnlock()
+	if stream := b.bootstrapStreams[streamName]; stream != nil {
+		return stream.RebuildSchedule
+	}
+	if stream := b.imageStreams[streamName]; stream != nil {
+		return stream.RebuildSchedule
+	}
+	return nil
+}
+
+func (b *Builder) listRebuildSchedules() map[string]*rebuildScheduleType {
+	b.streamsLock.RLock()
+	defer b.streamsLock.RUnlock()
+	schedules := make(map[string]*rebuildScheduleType)
+	for name, stream := range b.bootstrapStreams {
+		if stream.RebuildSchedule != nil {
+			schedules[name] = stream.RebuildSchedule
+		}
+	}
+	for name, stream := range b.imageStreams {
+		if stream.RebuildSchedule != nil {
+			schedules[name] = stream.RebuildSchedule
+		}
+	}
+	return schedules
+}
+
+func (b *Builder) runSchedules() {
+	for {
+		nextCheck := b.checkSchedules(time.Now())
+		time.Sleep(time.Until(nextCheck))
+	}
+}
+
+func (b *Builder) writeScheduleHtml(writer io.Writer) {
+	type row struct {
+		streamName string
+		schedule   string
+		nextTime   time.Time
+		err        error
+	}
+	var rows []row
+	autoRebuildStreams := b.listStreamsToAutoRebuild()
+	b.scheduleLock.Lock()
+	for streamName, scheduled := range b.scheduledBuilds {
+		rows = append(rows, row{streamName, scheduled.schedule.String(),
+			scheduled.nextTime, scheduled.err})
+	}
+	for _, streamName := range autoRebuildStreams {
+		if _, ok := b.scheduledBuilds[streamName]; !ok {
+			rows = append(rows, row{streamName,
+				"every " + format.Duration(b.imageRebuildInterval),
+				b.nextAutoRebuild, nil})
+		}
+	}
+	b.scheduleLock.Unlock()
+	if len(rows) < 1 {
+		return
+	}
+	sort.Slice(rows, func(left, right int) bool {
+		return rows[left].streamName < rows[right].streamName
+	})
+	fmt.Fprintln(writer, "Scheduled rebuilds:<br>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Image Stream</th>")
+	fmt.Fprintln(writer, "    <th>Schedule</th>")
+	fmt.Fprintln(writer, "    <th>Next Build</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, row := range rows {
+		fmt.Fprintf(writer, "  <tr>\n")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", row.streamName)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", row.schedule)
+		if row.err != nil {
+			fmt.Fprintf(writer, "    <td>%s</td>\n", row.err)
+		} else if row.nextTime.IsZero() {
+			fmt.Fprintln(writer, "    <td></td>")
+		} else {
+			fmt.Fprintf(writer, "    <td>%s (in %s)</td>\n",
+				row.nextTime.Format(format.TimeFormatSeconds),
+				format.Duration(time.Until(row.nextTime)))
+		}
+		fmt.Fprintf(writer, "  </tr>\n")
+	}
+	fmt.Fprintln(writer, "</table><br>")
+}
@@ -16,3 +16,20 @@ func (b *Builder) getVariableFunc(
 		return b.variables[varName]
 	}
 }
+
+func (b *Builder) mergeVariables(variables map[string]string) map[string]string {
+	if len(variables) < 1 {
+		return b.variables
+	} else if len(b.variables) < 1 {
+		return variables
+	}
+	mergedVariables := make(map[string]string,
+		len(b.variables)+len(variables))
+	for key, value := range b.variables {
+		mergedVariables[key] = value
+	}
+	for key, value := range variables {
+		mergedVariables[key] = value
+	}
+	return mergedVariables
+}
@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type manifestLocation struct {
+	gitBranch string
+	directory string
+	url       string // Normalised.
+}
+
+// buildStreamsForPush will queue builds for the image streams with manifests
+// (or included fragments) in a repository which has changes pushed to it. The
+// names of the streams which were queued are returned.
+func (b *Builder) buildStreamsForPush(repositoryUrls []string,
+	gitBranch string, changedPaths []string) []string {
+	repositories := make(map[string]struct{}, len(repositoryUrls))
+	for _, repositoryUrl := range repositoryUrls {
+		if repositoryUrl != "" {
+			repositories[normaliseRepositoryUrl(repositoryUrl)] = struct{}{}
+		}
+	}
+	var streamNames []string
+	for streamName, locations := range b.listManifestLocations() {
+		for _, location := range locations {
+			if _, ok := repositories[location.url]; !ok {
+				continue
+			}
+			if location.gitBranch == gitBranch &&
+				location.containsChanges(changedPaths) {
+				streamNames = append(streamNames, streamName)
+				break
+			}
+		}
+	}
+	sort.Strings(streamNames)
+	queued := make([]string, 0, len(streamNames))
+	for _, streamName := range streamNames {
+		if err := b.queueAutomaticBuild(streamName, 0); err != nil {
+			b.logger.Printf("Error queueing build for: %s: %s\n",
+				streamName, err)
+			continue
+		}
+		b.logger.Printf("Queued build for: %s after push to: %s\n",
+			streamName, gitBranch)
+		queued = append(queued, streamName)
+	}
+	return queued
+}
+
+// listManifestLocations returns the locations of the manifests and the known
+// included fragments for each image stream.
+func (b *Builder) listManifestLocations() map[string][]manifestLocation {
+	locations := make(map[string][]manifestLocation)
+	b.streamsLock.RLock()
+	for streamName, stream := range b.imageStreams {
+		variableFunc := b.getVariableFunc(map[string]string{
+			"IMAGE_STREAM": streamName,
+		},
+			nil)
+		locations[streamName] = []manifestLocation{
+			newManifestLocation(stream.ManifestUrl, stream.ManifestDirectory,
+				"master", variableFunc),
+		}
+	}
+	b.streamsLock.RUnlock()
+	b.buildQueueLock.Lock()
+	defer b.buildQueueLock.Unlock()
+	for streamName, includes := range b.streamIncludes {
+		if _, ok := locations[streamName]; ok {
+			locations[streamName] = append(locations[streamName],
+				includes...)
+		}
+	}
+	return locations
+}
+
+// includeLocations returns the locations of the fragments in includes, for a
+// build of the master branch.
+func (stream *imageStreamType) includeLocations(includes []includeType,
+	variableFunc func(string) string) []manifestLocation {
+	locations := make([]manifestLocation, 0, len(includes))
+	for _, include := range includes {
+		manifestUrl := include.ManifestUrl
+		if manifestUrl == "" {
+			manifestUrl = stream.ManifestUrl
+		}
+		gitBranch := include.GitBranch
+		if gitBranch == "" {
+			gitBranch = "master"
+		}
+		locations = append(locations, newManifestLocation(manifestUrl,
+			include.ManifestDirectory, gitBranch, variableFunc))
+	}
+	return locations
+}
+
+func newManifestLocation(manifestUrl, manifestDirectory, gitBranch string,
+	variableFunc func(string) string) manifestLocation {
+	directory := path.Clean("/" + os.Expand(manifestDirectory, variableFunc))
+	return manifestLocation{
+		gitBranch: gitBranch,
+		directory: strings.TrimPrefix(directory, "/"),
+		url:       normaliseRepositoryUrl(os.Expand(manifestUrl, variableFunc)),
+	}
+}
+
+// normaliseRepositoryUrl will strip the scheme, credentials, port number and
+// .git suffix from a repository URL, so that the HTTP and SSH URLs for a
+// repository compare equal.
+func normaliseRepositoryUrl(repositoryUrl string) string {
+	var host, repoPath string
+	parsedUrl, err := url.Parse(repositoryUrl)
+	if err == nil && parsedUrl.Host != "" {
+		host = parsedUrl.Hostname()
+		repoPath = parsedUrl.Path
+	} else {
+		// SCP-like syntax: [user@]host:path.
+		fields := strings.SplitN(repositoryUrl, ":", 2)
+		if len(fields) != 2 || strings.Contains(fields[0], "/") {
+			return repositoryUrl
+		}
+		host = fields[0][strings.LastIndex(fields[0], "@")+1:]
+		repoPath = fields[1]
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	return strings.ToLower(host) + "/" + repoPath
+}
+
+// containsChanges returns true if any of changedPaths is in the directory for
+// the location.
+func (location manifestLocation) containsChanges(changedPaths []string) bool {
+	if location.directory == "" {
+		return len(changedPaths) > 0
+	}
+	for _, changedPath := range changedPaths {
+		if changedPath == location.directory ||
+			strings.HasPrefix(changedPath, location.directory+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -11,3 +11,20 @@ func BuildImage(client *srpc.Client, request proto.BuildImageRequest,
 	response *proto.BuildImageResponse, logWriter io.Writer) error {
 	return buildImage(client, request, response, logWriter)
 }
+
+func CancelBuild(client *srpc.Client, id uint64) error {
+	return cancelBuild(client, id)
+}
+
+func GetBuildHistory(client *srpc.Client, streamName string,
+	maxRecords uint) ([]proto.BuildRecord, error) {
+	return getBuildHistory(client, streamName, maxRecords)
+}
+
+func GetBuildLog(client *srpc.Client, streamName, id string) ([]byte, error) {
+	return getBuildLog(client, streamName, id)
+}
+
+func GetBuildQueue(client *srpc.Client) ([]proto.BuildQueueEntry, error) {
+	return getBuildQueue(client)
+}
@@ -45,3 +45,50 @@ func buildImage(client *srpc.Client, request proto.BuildImageRequest,
 		}
 	}
 }
+
+func cancelBuild(client *srpc.Client, id uint64) error {
+	request := proto.CancelBuildRequest{id}
+	var reply proto.CancelBuildResponse
+	err := client.RequestReply("Imaginator.CancelBuild", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+func getBuildHistory(client *srpc.Client, streamName string,
+	maxRecords uint) ([]proto.BuildRecord, error) {
+	request := proto.GetBuildHistoryRequest{streamName, maxRecords}
+	var reply proto.GetBuildHistoryResponse
+	err := client.RequestReply("Imaginator.GetBuildHistory", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Records, nil
+}
+
+func getBuildLog(client *srpc.Client, streamName, id string) ([]byte, error) {
+	request := proto.GetBuildLogRequest{streamName, id}
+	var reply proto.GetBuildLogResponse
+	err := client.RequestReply("Imaginator.GetBuildLog", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.BuildLog, nil
+}
+
+func getBuildQueue(client *srpc.Client) ([]proto.BuildQueueEntry, error) {
+	var reply proto.GetBuildQueueResponse
+	err := client.RequestReply("Imaginator.GetBuildQueue",
+		proto.GetBuildQueueRequest{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Entries, nil
+}
@@ -17,21 +17,25 @@ type HtmlWriter interface {
 var htmlWriters []HtmlWriter
 
 type state struct {
-	builder *builder.Builder
+	builder       *builder.Builder
+	webhookSecret []byte
 }
 
 func StartServer(portNum uint, builderObj *builder.Builder,
-	daemon bool) error {
+	webhookSecret []byte, daemon bool) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portNum))
 	if err != nil {
 		return err
 	}
-	myState := state{builderObj}
+	myState := state{builderObj, webhookSecret}
 	html.HandleFunc("/", myState.statusHandler)
+	html.HandleFunc("/showBuildHistory", myState.showBuildHistoryHandler)
+	html.HandleFunc("/showBuildLog", myState.showBuildLogHandler)
 	html.HandleFunc("/showCurrentBuildLog", myState.showCurrentBuildLogHandler)
 	html.HandleFunc("/showImageStream", myState.showImageStreamHandler)
 	html.HandleFunc("/showImageStreams", myState.showImageStreamsHandler)
 	html.HandleFunc("/showLastBuildLog", myState.showLastBuildLogHandler)
+	html.HandleFunc("/webhook", myState.webhookHandler)
 	if daemon {
 		go http.Serve(listener, nil)
 	} else {
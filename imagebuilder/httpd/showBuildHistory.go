@@ -0,0 +1,97 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+func (s state) showBuildHistoryHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	streamName := req.URL.RawQuery
+	fmt.Fprintf(writer, "<title>build history for stream %s</title>\n",
+		streamName)
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintf(writer, "<h3>Build history for stream: %s</h3>\n", streamName)
+	records, err := s.builder.GetBuildHistory(streamName, 0)
+	if err != nil {
+		fmt.Fprintln(writer, err)
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	if len(records) < 1 {
+		fmt.Fprintln(writer, "No builds recorded")
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Started</th>")
+	fmt.Fprintln(writer, "    <th>Build Time</th>")
+	fmt.Fprintln(writer, "    <th>User</th>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Cache</th>")
+	fmt.Fprintln(writer, "    <th>CPU Time</th>")
+	fmt.Fprintln(writer, "    <th>Peak Memory</th>")
+	fmt.Fprintln(writer, "    <th>Error</th>")
+	fmt.Fprintln(writer, "    <th>Build log</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, record := range records {
+		fmt.Fprintf(writer, "  <tr>\n")
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			record.StartTime.Format(format.TimeFormatSeconds))
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			format.Duration(record.FinishTime.Sub(record.StartTime)))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", record.Username)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", record.ImageName)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", record.CacheResult)
+		if usage := record.ResourceUsage; usage == nil {
+			fmt.Fprintln(writer, "    <td></td>")
+			fmt.Fprintln(writer, "    <td></td>")
+		} else {
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.Duration(usage.CpuTime))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.FormatBytes(usage.MaxMemory))
+		}
+		fmt.Fprintf(writer, "    <td>%s</td>\n", record.Error)
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showBuildLog?stream=%s&id=%s\">log</a></td>\n",
+			url.QueryEscape(streamName), url.QueryEscape(record.Id))
+		fmt.Fprintf(writer, "  </tr>\n")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func (s state) showBuildLogHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	streamName := req.URL.Query().Get("stream")
+	id := req.URL.Query().Get("id")
+	fmt.Fprintf(writer, "<title>build log for stream %s</title>\n", streamName)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	buildLog, err := s.builder.GetBuildLog(streamName, id)
+	if err != nil {
+		fmt.Fprintln(writer, err)
+	} else {
+		fmt.Fprintf(writer, "Build log for stream: %s, build: %s\n",
+			streamName, id)
+		fmt.Fprintln(writer, "</h3>")
+		fmt.Fprintln(writer, "<pre>")
+		writer.Write(buildLog)
+		fmt.Fprintln(writer, "</pre>")
+	}
+	fmt.Fprintln(writer, "</body>")
+}
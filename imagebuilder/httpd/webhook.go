@@ -0,0 +1,120 @@
+package httpd
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const maxWebhookPayloadSize = 25 << 20
+
+type pushCommit struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// pushPayload is the push event payload sent by GitHub, Gitea and Gogs.
+type pushPayload struct {
+	Commits    []pushCommit `json:"commits"`
+	Deleted    bool         `json:"deleted"`
+	Ref        string       `json:"ref"`
+	Repository struct {
+		CloneUrl string `json:"clone_url"`
+		GitUrl   string `json:"git_url"`
+		HtmlUrl  string `json:"html_url"`
+		SshUrl   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+// getWebhookEvent returns the event type and the hex-encoded HMAC-SHA256
+// signature of the payload from the request headers.
+func getWebhookEvent(header http.Header) (string, string) {
+	if event := header.Get("X-GitHub-Event"); event != "" {
+		return event,
+			strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	}
+	if event := header.Get("X-Gitea-Event"); event != "" {
+		return event, header.Get("X-Gitea-Signature")
+	}
+	if event := header.Get("X-Gogs-Event"); event != "" {
+		return event, header.Get("X-Gogs-Signature")
+	}
+	return "", ""
+}
+
+func (s state) webhookHandler(w http.ResponseWriter, req *http.Request) {
+	if len(s.webhookSecret) < 1 {
+		http.Error(w, "webhook not configured", http.StatusNotFound)
+		return
+	}
+	if req.Method != "POST" {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	event, signature := getWebhookEvent(req.Header)
+	if event == "" {
+		http.Error(w, "unsupported webhook", http.StatusBadRequest)
+		return
+	}
+	payload, err := ioutil.ReadAll(
+		io.LimitReader(req.Body, maxWebhookPayloadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.verifyWebhookSignature(payload, signature) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	if event != "push" {
+		fmt.Fprintf(writer, "Ignoring event: %s\n", event)
+		return
+	}
+	var push pushPayload
+	if err := json.Unmarshal(payload, &push); err != nil {
+		http.Error(w, "error decoding payload: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+	if push.Deleted || !strings.HasPrefix(push.Ref, "refs/heads/") {
+		fmt.Fprintf(writer, "Ignoring push to: %s\n", push.Ref)
+		return
+	}
+	var changedPaths []string
+	for _, commit := range push.Commits {
+		changedPaths = append(changedPaths, commit.Added...)
+		changedPaths = append(changedPaths, commit.Modified...)
+		changedPaths = append(changedPaths, commit.Removed...)
+	}
+	streamNames := s.builder.BuildStreamsForPush(
+		[]string{
+			push.Repository.CloneUrl,
+			push.Repository.GitUrl,
+			push.Repository.HtmlUrl,
+			push.Repository.SshUrl,
+		},
+		strings.TrimPrefix(push.Ref, "refs/heads/"), changedPaths)
+	for _, streamName := range streamNames {
+		fmt.Fprintf(writer, "Queued build for: %s\n", streamName)
+	}
+}
+
+func (s state) verifyWebhookSignature(payload []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) < 1 {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.webhookSecret)
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
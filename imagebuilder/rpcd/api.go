@@ -34,6 +34,10 @@ func Setup(builder *builder.Builder, logger log.Logger) (*htmlWriter, error) {
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"BuildImage",
+				"CancelBuild",
+				"GetBuildHistory",
+				"GetBuildLog",
+				"GetBuildQueue",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
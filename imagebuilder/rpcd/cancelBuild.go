@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func (t *srpcType) CancelBuild(conn *srpc.Conn,
+	request proto.CancelBuildRequest,
+	reply *proto.CancelBuildResponse) error {
+	*reply = proto.CancelBuildResponse{errors.ErrorToString(
+		t.builder.CancelBuild(request.Id, conn.GetAuthInformation()))}
+	return nil
+}
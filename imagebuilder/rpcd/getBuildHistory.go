@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func (t *srpcType) GetBuildHistory(conn *srpc.Conn,
+	request proto.GetBuildHistoryRequest,
+	reply *proto.GetBuildHistoryResponse) error {
+	records, err := t.builder.GetBuildHistory(request.StreamName,
+		request.MaxRecords)
+	*reply = proto.GetBuildHistoryResponse{errors.ErrorToString(err), records}
+	return nil
+}
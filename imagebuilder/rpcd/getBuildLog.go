@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func (t *srpcType) GetBuildLog(conn *srpc.Conn,
+	request proto.GetBuildLogRequest,
+	reply *proto.GetBuildLogResponse) error {
+	buildLog, err := t.builder.GetBuildLog(request.StreamName, request.Id)
+	*reply = proto.GetBuildLogResponse{buildLog, errors.ErrorToString(err)}
+	return nil
+}
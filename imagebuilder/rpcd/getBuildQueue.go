@@ -0,0 +1,13 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func (t *srpcType) GetBuildQueue(conn *srpc.Conn,
+	request proto.GetBuildQueueRequest,
+	reply *proto.GetBuildQueueResponse) error {
+	*reply = proto.GetBuildQueueResponse{t.builder.GetBuildQueue()}
+	return nil
+}
@@ -0,0 +1,90 @@
+package auditlog
+
+import (
+	"os"
+	"sync"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+type notifiers map[<-chan imageserver.AuditLogEntry]chan<- imageserver.AuditLogEntry
+
+// AuditLog is an append-only, hash-chained log of mutations.
+type AuditLog struct {
+	filename               string
+	logger                 log.Logger
+	lock                   sync.Mutex // Protect everything below.
+	entries                []imageserver.AuditLogEntry
+	file                   *os.File
+	lastReplicatedSequence uint64
+	notifiers              notifiers
+}
+
+// Open will open the audit log in the specified file, creating it if needed.
+// The hash chain of existing entries is verified.
+func Open(filename string, logger log.Logger) (*AuditLog, error) {
+	return openLog(filename, logger)
+}
+
+// ComputeHash will compute the hash for an entry, ignoring the existing
+// entry.Hash field.
+func ComputeHash(entry *imageserver.AuditLogEntry) hash.Hash {
+	return computeHash(entry)
+}
+
+// OriginalEntry will reconstruct the entry as recorded on the replication
+// source from a replicated entry. The result may be checked with ComputeHash.
+// If entry was not replicated, it is returned unchanged.
+func OriginalEntry(entry imageserver.AuditLogEntry) imageserver.AuditLogEntry {
+	return originalEntry(entry)
+}
+
+// VerifyEntries will verify the hash chain for a contiguous list of entries.
+// If the first entry is the first in the log, its PreviousHash must be zero.
+func VerifyEntries(entries []imageserver.AuditLogEntry) error {
+	return verifyEntries(entries)
+}
+
+// AddReplicatedEntry will append an entry received from the server at
+// sourceAddress. Entries which were previously replicated are ignored. An
+// error is returned if entry does not immediately follow the last replicated
+// entry (the first replicated entry must have sequence number 1), in which
+// case the missing entries should be fetched from the source.
+func (l *AuditLog) AddReplicatedEntry(entry imageserver.AuditLogEntry,
+	sourceAddress string) error {
+	return l.addReplicatedEntry(entry, sourceAddress)
+}
+
+// GetEntries will return up to maxEntries entries, starting with the entry
+// with sequence number startSequence.
+func (l *AuditLog) GetEntries(startSequence uint64,
+	maxEntries uint) []imageserver.AuditLogEntry {
+	return l.getEntries(startSequence, maxEntries)
+}
+
+// LastReplicatedSequence returns the sequence number (on the replication
+// source) of the last replicated entry.
+func (l *AuditLog) LastReplicatedSequence() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.lastReplicatedSequence
+}
+
+// Record will append an entry recording a call to method by the user in
+// authInfo, with the specified arguments and result.
+func (l *AuditLog) Record(authInfo *srpc.AuthInformation, method string,
+	arguments map[string]string, result error) error {
+	return l.record(authInfo, method, arguments, result)
+}
+
+func (l *AuditLog) RegisterNotifier() <-chan imageserver.AuditLogEntry {
+	return l.registerNotifier()
+}
+
+func (l *AuditLog) UnregisterNotifier(
+	channel <-chan imageserver.AuditLogEntry) {
+	l.unregisterNotifier(channel)
+}
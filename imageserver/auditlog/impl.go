@@ -0,0 +1,314 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const (
+	filePerms = syscall.S_IRUSR | syscall.S_IWUSR | syscall.S_IRGRP
+
+	maxRecordLength = 1 << 20
+)
+
+// Each record in the file is a 4 byte big-endian length followed by a Gob
+// encoded imageserver.AuditLogEntry.
+
+func openLog(filename string, logger log.Logger) (*AuditLog, error) {
+	entries, validLength, err := readEntries(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyEntries(entries); err != nil {
+		return nil, fmt.Errorf("audit log: %s is corrupt: %s", filename, err)
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, filePerms)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := file.Stat(); err != nil {
+		file.Close()
+		return nil, err
+	} else if fi.Size() > validLength {
+		logger.Printf("Truncating partial record at end of audit log: %s\n",
+			filename)
+		if err := file.Truncate(validLength); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, err
+	}
+	auditLog := &AuditLog{
+		filename:  filename,
+		logger:    logger,
+		entries:   entries,
+		file:      file,
+		notifiers: make(notifiers),
+	}
+	for _, entry := range entries {
+		if entry.ReplicatedFrom != "" {
+			auditLog.lastReplicatedSequence = entry.OriginalSequence
+		}
+	}
+	logger.Printf("Loaded %d audit log entries\n", len(entries))
+	return auditLog, nil
+}
+
+func readEntries(filename string) ([]imageserver.AuditLogEntry, int64,
+	error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var entries []imageserver.AuditLogEntry
+	var validLength int64
+	for {
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return entries, validLength, nil
+			}
+			return nil, 0, err
+		}
+		if length > maxRecordLength {
+			return nil, 0, fmt.Errorf("record length: %d too large", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return entries, validLength, nil
+			}
+			return nil, 0, err
+		}
+		var entry imageserver.AuditLogEntry
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+		validLength += 4 + int64(length)
+	}
+}
+
+func computeHash(entry *imageserver.AuditLogEntry) hash.Hash {
+	hasher := sha512.New()
+	writeUint := func(value uint64) {
+		binary.Write(hasher, binary.BigEndian, value)
+	}
+	writeString := func(value string) {
+		writeUint(uint64(len(value)))
+		io.WriteString(hasher, value)
+	}
+	writeTime := func(value time.Time) {
+		if value.IsZero() {
+			writeUint(0)
+		} else {
+			writeUint(uint64(value.UnixNano()))
+		}
+	}
+	writeUint(entry.Sequence)
+	writeTime(entry.Time)
+	writeString(entry.Username)
+	writeString(entry.Method)
+	keys := make([]string, 0, len(entry.Arguments))
+	for key := range entry.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	writeUint(uint64(len(keys)))
+	for _, key := range keys {
+		writeString(key)
+		writeString(entry.Arguments[key])
+	}
+	writeString(entry.Error)
+	writeString(entry.ReplicatedFrom)
+	writeUint(entry.OriginalSequence)
+	writeTime(entry.OriginalTime)
+	hasher.Write(entry.OriginalPreviousHash[:])
+	hasher.Write(entry.OriginalHash[:])
+	hasher.Write(entry.PreviousHash[:])
+	var hashVal hash.Hash
+	copy(hashVal[:], hasher.Sum(nil))
+	return hashVal
+}
+
+func verifyEntries(entries []imageserver.AuditLogEntry) error {
+	for index, entry := range entries {
+		if computedHash := computeHash(&entry); computedHash != entry.Hash {
+			return fmt.Errorf("entry: %d: hash mismatch", entry.Sequence)
+		}
+		if index == 0 {
+			if entry.Sequence == 1 && entry.PreviousHash != (hash.Hash{}) {
+				return fmt.Errorf("entry: 1: non-zero previous hash")
+			}
+			continue
+		}
+		previous := entries[index-1]
+		if entry.Sequence != previous.Sequence+1 {
+			return fmt.Errorf("entry: %d: follows entry: %d",
+				entry.Sequence, previous.Sequence)
+		}
+		if entry.PreviousHash != previous.Hash {
+			return fmt.Errorf("entry: %d: previous hash mismatch",
+				entry.Sequence)
+		}
+	}
+	return nil
+}
+
+func (l *AuditLog) addReplicatedEntry(entry imageserver.AuditLogEntry,
+	sourceAddress string) error {
+	if computedHash := computeHash(&entry); computedHash != entry.Hash {
+		return fmt.Errorf("replicated entry: %d: hash mismatch", entry.Sequence)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if entry.Sequence <= l.lastReplicatedSequence {
+		return nil
+	}
+	// Entries must be replicated in order, starting with the first entry, so
+	// that there are no gaps. The caller should fetch any missing entries.
+	if entry.Sequence != l.lastReplicatedSequence+1 {
+		return fmt.Errorf("replicated entry: %d does not follow: %d",
+			entry.Sequence, l.lastReplicatedSequence)
+	}
+	newEntry := entry
+	newEntry.ReplicatedFrom = sourceAddress
+	newEntry.OriginalSequence = entry.Sequence
+	newEntry.OriginalTime = entry.Time
+	newEntry.OriginalPreviousHash = entry.PreviousHash
+	newEntry.OriginalHash = entry.Hash
+	if err := l.appendWithLock(newEntry); err != nil {
+		return err
+	}
+	l.lastReplicatedSequence = entry.Sequence
+	return nil
+}
+
+func originalEntry(
+	entry imageserver.AuditLogEntry) imageserver.AuditLogEntry {
+	if entry.ReplicatedFrom == "" {
+		return entry
+	}
+	entry.Sequence = entry.OriginalSequence
+	entry.Time = entry.OriginalTime
+	entry.PreviousHash = entry.OriginalPreviousHash
+	entry.Hash = entry.OriginalHash
+	entry.ReplicatedFrom = ""
+	entry.OriginalSequence = 0
+	entry.OriginalTime = time.Time{}
+	entry.OriginalPreviousHash = hash.Hash{}
+	entry.OriginalHash = hash.Hash{}
+	return entry
+}
+
+func (l *AuditLog) getEntries(startSequence uint64,
+	maxEntries uint) []imageserver.AuditLogEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if startSequence < 1 {
+		startSequence = 1
+	}
+	if startSequence > uint64(len(l.entries)) {
+		return nil
+	}
+	entries := l.entries[startSequence-1:]
+	if maxEntries > 0 && uint(len(entries)) > maxEntries {
+		entries = entries[:maxEntries]
+	}
+	retval := make([]imageserver.AuditLogEntry, len(entries))
+	copy(retval, entries)
+	return retval
+}
+
+func (l *AuditLog) record(authInfo *srpc.AuthInformation, method string,
+	arguments map[string]string, result error) error {
+	entry := imageserver.AuditLogEntry{
+		Method:    method,
+		Arguments: arguments,
+		Error:     errors.ErrorToString(result),
+	}
+	if authInfo != nil {
+		entry.Username = authInfo.Username
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	err := l.appendWithLock(entry)
+	if err != nil {
+		l.logger.Printf("Error recording audit log entry for: %s: %s\n",
+			method, err)
+	}
+	return err
+}
+
+// appendWithLock will fill in the sequence number, time and hashes, write the
+// entry to the log file and send it to the notifiers. It must be called with
+// the lock held.
+func (l *AuditLog) appendWithLock(entry imageserver.AuditLogEntry) error {
+	entry.Sequence = uint64(len(l.entries)) + 1
+	entry.Time = time.Now()
+	if len(l.entries) > 0 {
+		entry.PreviousHash = l.entries[len(l.entries)-1].Hash
+	} else {
+		entry.PreviousHash = hash.Hash{}
+	}
+	entry.Hash = computeHash(&entry)
+	buffer := &bytes.Buffer{}
+	buffer.Write(make([]byte, 4)) // Space for the length.
+	if err := gob.NewEncoder(buffer).Encode(entry); err != nil {
+		return err
+	}
+	data := buffer.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+	if _, err := l.file.Write(data); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.entries = append(l.entries, entry)
+	for _, sendChannel := range l.notifiers {
+		go func(channel chan<- imageserver.AuditLogEntry) {
+			channel <- entry
+		}(sendChannel)
+	}
+	return nil
+}
+
+func (l *AuditLog) registerNotifier() <-chan imageserver.AuditLogEntry {
+	channel := make(chan imageserver.AuditLogEntry, 1)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.notifiers[channel] = channel
+	return channel
+}
+
+func (l *AuditLog) unregisterNotifier(
+	channel <-chan imageserver.AuditLogEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.notifiers, channel)
+}
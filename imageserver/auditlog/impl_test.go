@@ -0,0 +1,94 @@
+package auditlog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+func TestRecordAndReopen(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	filename := path.Join(dirname, "log")
+	logger := testlogger.New(t)
+	auditLog, err := Open(filename, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog.Record(nil, "AddImage", map[string]string{"ImageName": "a"}, nil)
+	auditLog.Record(nil, "DeleteImage", map[string]string{"ImageName": "a"},
+		errors.New("no such image"))
+	auditLog.file.Close()
+	auditLog, err = Open(filename, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := auditLog.GetEntries(1, 0)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got: %d", len(entries))
+	}
+	if entries[1].Error != "no such image" {
+		t.Errorf("unexpected error field: %s", entries[1].Error)
+	}
+	if err := VerifyEntries(entries); err != nil {
+		t.Error(err)
+	}
+	entries[0].Arguments["ImageName"] = "b"
+	if err := VerifyEntries(entries); err == nil {
+		t.Error("tampered entry not detected")
+	}
+	auditLog.file.Close()
+}
+
+func TestReplicate(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	logger := testlogger.New(t)
+	master, err := Open(path.Join(dirname, "master"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.file.Close()
+	replica, err := Open(path.Join(dirname, "replica"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.file.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		master.Record(nil, "AddImage", map[string]string{"ImageName": name},
+			nil)
+	}
+	masterEntries := master.GetEntries(1, 0)
+	if err := replica.AddReplicatedEntry(masterEntries[1], "m"); err == nil {
+		t.Fatal("gap before first replicated entry not detected")
+	}
+	for _, entry := range masterEntries {
+		if err := replica.AddReplicatedEntry(entry, "m"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replicaEntries := replica.GetEntries(1, 0)
+	if len(replicaEntries) != len(masterEntries) {
+		t.Fatalf("expected %d entries, got: %d",
+			len(masterEntries), len(replicaEntries))
+	}
+	for index, entry := range replicaEntries {
+		original := OriginalEntry(entry)
+		if !original.Time.Equal(masterEntries[index].Time) {
+			t.Errorf("entry: %d: original time not preserved", index+1)
+		}
+		if ComputeHash(&original) != masterEntries[index].Hash {
+			t.Errorf("entry: %d: original entry does not verify", index+1)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package client
 
 import (
+	"io"
 	"time"
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
 )
 
 func AddImage(client *srpc.Client, name string, img *image.Image) error {
@@ -42,11 +44,26 @@ func DeleteUnreferencedObjects(client *srpc.Client, percentage uint8,
 	return deleteUnreferencedObjects(client, percentage, bytes)
 }
 
+// ExportOciImage will have the imageserver generate an OCI image layout tarball
+// for the specified image and will write it to writer.
+func ExportOciImage(client *srpc.Client,
+	request imageserver.ExportOciImageRequest, writer io.Writer) error {
+	return exportOciImage(client, request, writer)
+}
+
 func FindLatestImage(client *srpc.Client, dirname string,
 	ignoreExpiring bool) (string, error) {
 	return findLatestImage(client, dirname, ignoreExpiring)
 }
 
+// GetAuditLog will get up to maxEntries audit log entries, starting with the
+// entry with sequence number startSequence. The server may return fewer
+// entries than requested. If maxEntries is zero, the server default is used.
+func GetAuditLog(client *srpc.Client, startSequence uint64,
+	maxEntries uint) ([]imageserver.AuditLogEntry, error) {
+	return getAuditLog(client, startSequence, maxEntries)
+}
+
 func GetImage(client *srpc.Client, name string) (*image.Image, error) {
 	return getImage(client, name, 0)
 }
@@ -55,6 +72,14 @@ func GetImageExpiration(client *srpc.Client, name string) (time.Time, error) {
 	return getImageExpiration(client, name)
 }
 
+// GetImageVulnerabilities will get the vulnerabilities for the packages in
+// each of the specified images. Errors for individual images are returned in
+// the results.
+func GetImageVulnerabilities(client *srpc.Client, names []string) (
+	[]imageserver.ImageVulnerabilities, error) {
+	return getImageVulnerabilities(client, names)
+}
+
 func GetImageWithTimeout(client *srpc.Client, name string,
 	timeout time.Duration) (*image.Image, error) {
 	return getImage(client, name, timeout)
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func exportOciImage(client *srpc.Client,
+	request imageserver.ExportOciImageRequest, writer io.Writer) error {
+	conn, err := client.Call("ImageServer.ExportOciImage")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return fmt.Errorf("error encoding request: %s", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var response imageserver.ExportOciImageResponse
+	if err := conn.Decode(&response); err != nil {
+		return err
+	}
+	if err := errors.New(response.Error); err != nil {
+		return err
+	}
+	_, err = io.CopyN(writer, conn, int64(response.Size))
+	return err
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func getAuditLog(client *srpc.Client, startSequence uint64,
+	maxEntries uint) ([]imageserver.AuditLogEntry, error) {
+	request := imageserver.GetAuditLogRequest{
+		StartSequence: startSequence,
+		MaxEntries:    maxEntries,
+	}
+	var reply imageserver.GetAuditLogResponse
+	err := client.RequestReply("ImageServer.GetAuditLog", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Entries, nil
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func getImageVulnerabilities(client *srpc.Client, names []string) (
+	[]imageserver.ImageVulnerabilities, error) {
+	request := imageserver.GetImageVulnerabilitiesRequest{ImageNames: names}
+	var reply imageserver.GetImageVulnerabilitiesResponse
+	err := client.RequestReply("ImageServer.GetImageVulnerabilities", request,
+		&reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Images, nil
+}
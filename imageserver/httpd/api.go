@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/imageserver/vulndb"
 	"github.com/Symantec/Dominator/lib/html"
 	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
 )
@@ -20,16 +21,19 @@ var htmlWriters []HtmlWriter
 type state struct {
 	imageDataBase *scanner.ImageDataBase
 	objectServer  *filesystem.ObjectServer
+	vulnDb        *vulndb.Manager // May be nil.
 }
 
 func StartServer(portNum uint, imdb *scanner.ImageDataBase,
-	objSrv *filesystem.ObjectServer, daemon bool) error {
+	objSrv *filesystem.ObjectServer, vulnDb *vulndb.Manager,
+	daemon bool) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portNum))
 	if err != nil {
 		return err
 	}
-	myState := state{imageDataBase: imdb, objectServer: objSrv}
+	myState := state{imageDataBase: imdb, objectServer: objSrv, vulnDb: vulnDb}
 	html.HandleFunc("/", statusHandler)
+	html.HandleFunc("/getSbom", myState.getSbomHandler)
 	html.HandleFunc("/listBuildLog", myState.listBuildLogHandler)
 	html.HandleFunc("/listComputedInodes", myState.listComputedInodesHandler)
 	html.HandleFunc("/listDirectories", myState.listDirectoriesHandler)
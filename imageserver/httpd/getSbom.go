@@ -0,0 +1,53 @@
+package httpd
+
+import (
+	"bufio"
+	"net/http"
+
+	"github.com/Symantec/Dominator/lib/sbom"
+	"github.com/Symantec/Dominator/lib/url"
+)
+
+func (s state) getSbomHandler(w http.ResponseWriter, req *http.Request) {
+	parsedQuery := url.ParseQuery(req.URL)
+	if len(parsedQuery.Flags) != 1 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var imageName string
+	for name := range parsedQuery.Flags {
+		imageName = name
+	}
+	image := s.imageDataBase.GetImage(imageName)
+	if image == nil || image.Sbom == nil || image.Sbom.Object == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, reader, err := s.objectServer.GetObject(*image.Sbom.Object)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+	doc, err := sbom.Decode(reader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	w.Header().Set("Content-Type", "application/json")
+	switch parsedQuery.Table["format"] {
+	case "", "native":
+		doc.Encode(writer)
+	case "cyclonedx":
+		doc.WriteCycloneDx(writer)
+	case "spdx":
+		doc.WriteSpdx(writer)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -3,11 +3,14 @@ package httpd
 import (
 	"bufio"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
 
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/url"
+	"github.com/Symantec/Dominator/lib/vulnerability"
 )
 
 func (s state) listPackagesHandler(w http.ResponseWriter, req *http.Request) {
@@ -62,19 +65,57 @@ func (s state) listPackagesHandler(w http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(writer, " <a href=\"listPackages?%s&output=json\">json</a>",
 		imageName)
 	fmt.Fprintln(writer, "</h3>")
+	var vulnsByPackage map[string][]vulnerability.Match
+	if s.vulnDb != nil {
+		vulns, err := s.vulnDb.GetImageVulnerabilities(imageName)
+		if err != nil {
+			fmt.Fprintf(writer, "Error matching vulnerabilities: %s<br>\n",
+				err)
+		} else {
+			fmt.Fprintf(writer, "Number of vulnerabilities: %d<br>\n",
+				len(vulns))
+			vulnsByPackage = make(map[string][]vulnerability.Match)
+			for _, vuln := range vulns {
+				vulnsByPackage[vuln.PackageName] = append(
+					vulnsByPackage[vuln.PackageName], vuln)
+			}
+		}
+	}
 	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
 	fmt.Fprintln(writer, "  <tr>")
 	fmt.Fprintln(writer, "    <th>Name</th>")
 	fmt.Fprintln(writer, "    <th>Version</th>")
 	fmt.Fprintln(writer, "    <th>Size</th>")
+	if vulnsByPackage != nil {
+		fmt.Fprintln(writer, "    <th>Vulnerabilities</th>")
+	}
 	fmt.Fprintln(writer, "  </tr>")
 	for _, pkg := range image.Packages {
 		fmt.Fprintf(writer, "  <tr>\n")
 		fmt.Fprintf(writer, "    <td>%s</td>\n", pkg.Name)
 		fmt.Fprintf(writer, "    <td>%s</td>\n", pkg.Version)
 		fmt.Fprintf(writer, "    <td>%s</td>\n", format.FormatBytes(pkg.Size))
+		if vulnsByPackage != nil {
+			fmt.Fprint(writer, "    <td>")
+			for _, vuln := range vulnsByPackage[pkg.Name] {
+				writeVulnerability(writer, vuln)
+			}
+			fmt.Fprintln(writer, "</td>")
+		}
 		fmt.Fprintf(writer, "  </tr>\n")
 	}
 	fmt.Fprintln(writer, "</table>")
 	fmt.Fprintln(writer, "</body>")
 }
+
+func writeVulnerability(writer io.Writer, vuln vulnerability.Match) {
+	fmt.Fprintf(writer, "<span title=\"%s\">%s</span>",
+		html.EscapeString(vuln.Summary), html.EscapeString(vuln.Id))
+	if vuln.Severity != "" {
+		fmt.Fprintf(writer, " (%s)", html.EscapeString(vuln.Severity))
+	}
+	if vuln.FixedVersion != "" {
+		fmt.Fprintf(writer, " fixed in %s", vuln.FixedVersion)
+	}
+	fmt.Fprint(writer, "<br>")
+}
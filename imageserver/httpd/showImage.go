@@ -75,6 +75,28 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 			"Packages: <a href=\"listPackages?%s\">%d</a><br>\n",
 			imageName, len(image.Packages))
 	}
+	if len(image.TestResults) > 0 {
+		var numFailed int
+		for _, result := range image.TestResults {
+			if result.Error != "" {
+				numFailed++
+			}
+		}
+		if numFailed > 0 {
+			fmt.Fprintf(writer,
+				"Tests: %d, <font color=\"red\">%d failed</font><br>\n",
+				len(image.TestResults), numFailed)
+		} else {
+			fmt.Fprintf(writer, "Tests: %d, all passed<br>\n",
+				len(image.TestResults))
+		}
+	}
+	if image.Sbom != nil && image.Sbom.Object != nil {
+		fmt.Fprintf(writer, "SBOM: <a href=\"getSbom?%s\">native</a>"+
+			" <a href=\"getSbom?%s&format=spdx\">SPDX</a>"+
+			" <a href=\"getSbom?%s&format=cyclonedx\">CycloneDX</a><br>\n",
+			imageName, imageName, imageName)
+	}
 	fmt.Fprintln(writer, "</body>")
 }
 
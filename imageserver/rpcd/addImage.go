@@ -2,6 +2,7 @@ package rpcd
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	iclient "github.com/Symantec/Dominator/imageserver/client"
@@ -14,12 +15,39 @@ func (t *srpcType) AddImage(conn *srpc.Conn,
 	reply *imageserver.AddImageResponse) error {
 	request.Image.CreatedBy = conn.Username() // Must always set this field.
 	request.Image.CreatedOn = time.Now()      // Must always set this field.
-	return t.AddImageTrusted(conn, request, reply)
+	err := t.addImageTrusted(conn, request)
+	t.recordAuditEntry(conn, "AddImage", addImageArguments(request), err)
+	return err
 }
 
 func (t *srpcType) AddImageTrusted(conn *srpc.Conn,
 	request imageserver.AddImageRequest,
 	reply *imageserver.AddImageResponse) error {
+	err := t.addImageTrusted(conn, request)
+	t.recordAuditEntry(conn, "AddImageTrusted", addImageArguments(request),
+		err)
+	return err
+}
+
+func addImageArguments(request imageserver.AddImageRequest) map[string]string {
+	arguments := map[string]string{"ImageName": request.ImageName}
+	if request.Image != nil {
+		arguments["CreatedBy"] = request.Image.CreatedBy
+		if !request.Image.ExpiresAt.IsZero() {
+			arguments["ExpiresAt"] = request.Image.ExpiresAt.String()
+		}
+		if request.Image.FileSystem != nil {
+			arguments["NumInodes"] = strconv.Itoa(
+				len(request.Image.FileSystem.InodeTable))
+			arguments["TotalDataBytes"] = strconv.FormatUint(
+				request.Image.FileSystem.TotalDataBytes, 10)
+		}
+	}
+	return arguments
+}
+
+func (t *srpcType) addImageTrusted(conn *srpc.Conn,
+	request imageserver.AddImageRequest) error {
 	if t.imageDataBase.CheckImage(request.ImageName) {
 		return errors.New("image already exists")
 	}
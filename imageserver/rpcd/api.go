@@ -6,7 +6,9 @@ import (
 	"io"
 	"sync"
 
+	"github.com/Symantec/Dominator/imageserver/auditlog"
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/imageserver/vulndb"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -21,12 +23,14 @@ var (
 
 type srpcType struct {
 	imageDataBase             *scanner.ImageDataBase
+	auditLog                  *auditlog.AuditLog
 	finishedReplication       <-chan struct{} // Closed when finished.
 	replicationMaster         string
 	imageserverResource       *srpc.ClientResource
 	objSrv                    objectserver.FullObjectServer
 	archiveMode               bool
 	logger                    log.Logger
+	vulnDb                    *vulndb.Manager
 	numReplicationClientsLock sync.RWMutex // Protect numReplicationClients.
 	numReplicationClients     uint
 	imagesBeingInjectedLock   sync.Mutex // Protect imagesBeingInjected.
@@ -42,7 +46,8 @@ func (hw *htmlWriter) WriteHtml(writer io.Writer) {
 var replicationMessage = "cannot make changes while under replication control" +
 	", go to master: "
 
-func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
+func Setup(imdb *scanner.ImageDataBase, auditLog *auditlog.AuditLog,
+	vulnDb *vulndb.Manager, replicationMaster string,
 	objSrv objectserver.FullObjectServer,
 	logger log.Logger) (*htmlWriter, error) {
 	if *archiveMode && replicationMaster == "" {
@@ -51,11 +56,13 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 	finishedReplication := make(chan struct{})
 	srpcObj := &srpcType{
 		imageDataBase:       imdb,
+		auditLog:            auditLog,
 		finishedReplication: finishedReplication,
 		replicationMaster:   replicationMaster,
 		imageserverResource: srpc.NewClientResource("tcp", replicationMaster),
 		objSrv:              objSrv,
 		logger:              logger,
+		vulnDb:              vulnDb,
 		archiveMode:         *archiveMode,
 		imagesBeingInjected: make(map[string]struct{}),
 	}
@@ -69,6 +76,7 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 			"FindLatestImage",
 			"GetImage",
 			"GetImageExpiration",
+			"GetImageVulnerabilities",
 			"ListDirectories",
 			"ListImages",
 		}})
@@ -11,6 +11,16 @@ import (
 func (t *srpcType) ChownDirectory(conn *srpc.Conn,
 	request imageserver.ChangeOwnerRequest,
 	reply *imageserver.ChangeOwnerResponse) error {
+	err := t.chownDirectory(conn, request)
+	t.recordAuditEntry(conn, "ChownDirectory", map[string]string{
+		"DirectoryName": request.DirectoryName,
+		"OwnerGroup":    request.OwnerGroup,
+	}, err)
+	return err
+}
+
+func (t *srpcType) chownDirectory(conn *srpc.Conn,
+	request imageserver.ChangeOwnerRequest) error {
 	username := conn.Username()
 	if username == "" {
 		return errors.New("no username: unauthenticated connection")
@@ -10,6 +10,14 @@ import (
 func (t *srpcType) DeleteImage(conn *srpc.Conn,
 	request imageserver.DeleteImageRequest,
 	reply *imageserver.DeleteImageResponse) error {
+	err := t.deleteImage(conn, request)
+	t.recordAuditEntry(conn, "DeleteImage",
+		map[string]string{"ImageName": request.ImageName}, err)
+	return err
+}
+
+func (t *srpcType) deleteImage(conn *srpc.Conn,
+	request imageserver.DeleteImageRequest) error {
 	username := conn.Username()
 	if err := t.checkMutability(); err != nil {
 		return err
@@ -1,6 +1,8 @@
 package rpcd
 
 import (
+	"strconv"
+
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/imageserver"
@@ -9,6 +11,16 @@ import (
 func (t *srpcType) DeleteUnreferencedObjects(conn *srpc.Conn,
 	request imageserver.DeleteUnreferencedObjectsRequest,
 	reply *imageserver.DeleteUnreferencedObjectsResponse) error {
+	err := t.deleteUnreferencedObjects(conn, request)
+	t.recordAuditEntry(conn, "DeleteUnreferencedObjects", map[string]string{
+		"Percentage": strconv.FormatUint(uint64(request.Percentage), 10),
+		"Bytes":      strconv.FormatUint(request.Bytes, 10),
+	}, err)
+	return err
+}
+
+func (t *srpcType) deleteUnreferencedObjects(conn *srpc.Conn,
+	request imageserver.DeleteUnreferencedObjectsRequest) error {
 	username := conn.Username()
 	if username == "" {
 		t.logger.Printf("DeleteUnreferencedObjects(%d%%, %s)\n",
@@ -9,14 +9,23 @@ import (
 func (t *srpcType) ChangeImageExpiration(conn *srpc.Conn,
 	request imageserver.ChangeImageExpirationRequest,
 	reply *imageserver.ChangeImageExpirationResponse) error {
+	err := t.changeImageExpiration(conn, request)
+	t.recordAuditEntry(conn, "ChangeImageExpiration", map[string]string{
+		"ImageName": request.ImageName,
+		"ExpiresAt": request.ExpiresAt.String(),
+	}, err)
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
+
+func (t *srpcType) changeImageExpiration(conn *srpc.Conn,
+	request imageserver.ChangeImageExpirationRequest) error {
 	if err := t.checkMutability(); err != nil {
-		reply.Error = errors.ErrorToString(err)
-		return nil
+		return err
 	}
 	_, err := t.imageDataBase.ChangeImageExpiration(
 		request.ImageName, request.ExpiresAt, conn.GetAuthInformation())
-	reply.Error = errors.ErrorToString(err)
-	return nil
+	return err
 }
 
 func (t *srpcType) GetImageExpiration(conn *srpc.Conn,
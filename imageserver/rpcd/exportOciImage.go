@@ -0,0 +1,71 @@
+package rpcd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/oci"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) ExportOciImage(conn *srpc.Conn) error {
+	var request imageserver.ExportOciImageRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	file, size, err := t.exportOciImage(request)
+	if err != nil {
+		return conn.Encode(imageserver.ExportOciImageResponse{
+			Error: err.Error()})
+	}
+	defer file.Close()
+	t.logger.Printf("ExportOciImage(%s) by %s: %d bytes\n",
+		request.ImageName, conn.Username(), size)
+	err = conn.Encode(imageserver.ExportOciImageResponse{Size: uint64(size)})
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(conn, file, size); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// exportOciImage will write the tarball to an unlinked temporary file so that
+// the size is known before sending and errors may be reported to the client.
+func (t *srpcType) exportOciImage(request imageserver.ExportOciImageRequest) (
+	*os.File, int64, error) {
+	img := t.imageDataBase.GetImage(request.ImageName)
+	if img == nil {
+		return nil, 0, errors.New("image not found")
+	}
+	file, err := ioutil.TempFile("", "imageserver.oci")
+	if err != nil {
+		return nil, 0, err
+	}
+	os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	err = oci.Encode(writer, img, t.objSrv, oci.EncodeOptions{
+		MaxLayerSize: request.MaxLayerSize,
+		Tag:          request.Tag,
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	var size int64
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, size, nil
+}
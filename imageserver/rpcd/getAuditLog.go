@@ -0,0 +1,23 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const maxAuditLogEntriesPerReply = 1000
+
+func (t *srpcType) GetAuditLog(conn *srpc.Conn,
+	request imageserver.GetAuditLogRequest,
+	reply *imageserver.GetAuditLogResponse) error {
+	if t.auditLog == nil {
+		reply.Error = "no audit log"
+		return nil
+	}
+	maxEntries := request.MaxEntries
+	if maxEntries < 1 || maxEntries > maxAuditLogEntriesPerReply {
+		maxEntries = maxAuditLogEntriesPerReply
+	}
+	reply.Entries = t.auditLog.GetEntries(request.StartSequence, maxEntries)
+	return nil
+}
@@ -28,6 +28,11 @@ func (t *srpcType) GetImageUpdates(conn *srpc.Conn) error {
 	defer t.imageDataBase.UnregisterAddNotifier(addChannel)
 	defer t.imageDataBase.UnregisterDeleteNotifier(deleteChannel)
 	defer t.imageDataBase.UnregisterMakeDirectoryNotifier(mkdirChannel)
+	var auditLogChannel <-chan imageserver.AuditLogEntry
+	if t.auditLog != nil {
+		auditLogChannel = t.auditLog.RegisterNotifier()
+		defer t.auditLog.UnregisterNotifier(auditLogChannel)
+	}
 	directories := t.imageDataBase.ListDirectories()
 	image.SortDirectories(directories)
 	for _, directory := range directories {
@@ -78,6 +83,15 @@ func (t *srpcType) GetImageUpdates(conn *srpc.Conn) error {
 				t.logger.Println(err)
 				return err
 			}
+		case entry := <-auditLogChannel:
+			imageUpdate := imageserver.ImageUpdate{
+				AuditLogEntry: &entry,
+				Operation:     imageserver.OperationAuditLogEntry,
+			}
+			if err := conn.Encode(imageUpdate); err != nil {
+				t.logger.Println(err)
+				return err
+			}
 		case err := <-closeChannel:
 			if err == nil {
 				t.logger.Printf("Image replication client disconnected: %s\n",
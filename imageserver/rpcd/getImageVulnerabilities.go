@@ -0,0 +1,27 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) GetImageVulnerabilities(conn *srpc.Conn,
+	request imageserver.GetImageVulnerabilitiesRequest,
+	reply *imageserver.GetImageVulnerabilitiesResponse) error {
+	if t.vulnDb == nil {
+		reply.Error = "no vulnerability feed"
+		return nil
+	}
+	reply.Images = make([]imageserver.ImageVulnerabilities, 0,
+		len(request.ImageNames))
+	for _, name := range request.ImageNames {
+		vulns, err := t.vulnDb.GetImageVulnerabilities(name)
+		reply.Images = append(reply.Images, imageserver.ImageVulnerabilities{
+			ImageName:       name,
+			Error:           errors.ErrorToString(err),
+			Vulnerabilities: vulns,
+		})
+	}
+	return nil
+}
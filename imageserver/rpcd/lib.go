@@ -2,6 +2,8 @@ package rpcd
 
 import (
 	"errors"
+
+	"github.com/Symantec/Dominator/lib/srpc"
 )
 
 func (t *srpcType) checkMutability() error {
@@ -10,3 +12,11 @@ func (t *srpcType) checkMutability() error {
 	}
 	return nil
 }
+
+func (t *srpcType) recordAuditEntry(conn *srpc.Conn, method string,
+	arguments map[string]string, result error) {
+	if t.auditLog == nil {
+		return
+	}
+	t.auditLog.Record(conn.GetAuthInformation(), method, arguments, result)
+}
@@ -8,6 +8,14 @@ import (
 func (t *srpcType) MakeDirectory(conn *srpc.Conn,
 	request imageserver.MakeDirectoryRequest,
 	reply *imageserver.MakeDirectoryResponse) error {
+	err := t.makeDirectory(conn, request)
+	t.recordAuditEntry(conn, "MakeDirectory",
+		map[string]string{"DirectoryName": request.DirectoryName}, err)
+	return err
+}
+
+func (t *srpcType) makeDirectory(conn *srpc.Conn,
+	request imageserver.MakeDirectoryRequest) error {
 	username := conn.Username()
 	if err := t.checkMutability(); err != nil {
 		return err
@@ -0,0 +1,63 @@
+package rpcd
+
+import (
+	"io"
+	"time"
+
+	imageclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) addReplicatedAuditLogEntry(
+	entry *imageserver.AuditLogEntry) error {
+	if t.auditLog == nil || entry == nil {
+		return nil
+	}
+	err := t.auditLog.AddReplicatedEntry(*entry, t.replicationMaster)
+	if err == nil {
+		return nil
+	}
+	t.logger.Printf("Replicator: %s, fetching missing audit log entries\n",
+		err)
+	return t.replicateAuditLog()
+}
+
+// replicateAuditLog will fetch all audit log entries from the replication
+// master which have not yet been replicated.
+func (t *srpcType) replicateAuditLog() error {
+	if t.auditLog == nil {
+		return nil
+	}
+	timeout := time.Second * 60
+	client, err := t.imageserverResource.GetHTTP(nil, timeout)
+	if err != nil {
+		return err
+	}
+	defer client.Put()
+	numReplicated := 0
+	for {
+		entries, err := imageclient.GetAuditLog(client,
+			t.auditLog.LastReplicatedSequence()+1, 0)
+		if err != nil {
+			if err == io.EOF {
+				client.Close()
+			}
+			return err
+		}
+		if len(entries) < 1 {
+			break
+		}
+		for _, entry := range entries {
+			err := t.auditLog.AddReplicatedEntry(entry, t.replicationMaster)
+			if err != nil {
+				return err
+			}
+		}
+		numReplicated += len(entries)
+	}
+	if numReplicated > 0 {
+		t.logger.Printf("Replicator: replicated %d audit log entries\n",
+			numReplicated)
+	}
+	return nil
+}
@@ -81,6 +81,9 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 				}
 				t.logger.Printf("Replicated all current images in %s\n",
 					format.Duration(time.Since(replicationStartTime)))
+				if err := t.replicateAuditLog(); err != nil {
+					t.logger.Printf("Error replicating audit log: %s\n", err)
+				}
 				continue
 			}
 			if initialImages != nil {
@@ -108,6 +111,11 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 			if err := t.imageDataBase.UpdateDirectory(*directory); err != nil {
 				return err
 			}
+		case imageserver.OperationAuditLogEntry:
+			err := t.addReplicatedAuditLogEntry(imageUpdate.AuditLogEntry)
+			if err != nil {
+				t.logger.Printf("Error replicating audit log: %s\n", err)
+			}
 		}
 	}
 }
@@ -0,0 +1,43 @@
+package vulndb
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/vulnerability"
+)
+
+// Manager matches images against a vulnerability feed directory, which is
+// reloaded when files in it change. Results are cached per image.
+type Manager struct {
+	dirname     string
+	imdb        *scanner.ImageDataBase
+	logger      log.Logger
+	lock        sync.Mutex // Protect everything below.
+	db          *vulnerability.Database
+	fingerprint string
+	loadedAt    time.Time
+	loadError   error
+	results     map[string][]vulnerability.Match // Key: image name.
+}
+
+// New will load the vulnerability feed in dirname and start a goroutine which
+// will reload the feed when it changes.
+func New(dirname string, imdb *scanner.ImageDataBase,
+	logger log.Logger) (*Manager, error) {
+	return newManager(dirname, imdb, logger)
+}
+
+// GetImageVulnerabilities will return the vulnerabilities for the packages in
+// the specified image. An error is returned if the image has no SBOM.
+func (m *Manager) GetImageVulnerabilities(imageName string) (
+	[]vulnerability.Match, error) {
+	return m.getImageVulnerabilities(imageName)
+}
+
+func (m *Manager) WriteHtml(writer io.Writer) {
+	m.writeHtml(writer)
+}
@@ -0,0 +1,172 @@
+package vulndb
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/sbom"
+	"github.com/Symantec/Dominator/lib/vulnerability"
+)
+
+const checkInterval = time.Minute
+
+func newManager(dirname string, imdb *scanner.ImageDataBase,
+	logger log.Logger) (*Manager, error) {
+	m := &Manager{
+		dirname: dirname,
+		imdb:    imdb,
+		logger:  logger,
+		results: make(map[string][]vulnerability.Match),
+	}
+	fingerprint, err := computeFingerprint(dirname)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.load(fingerprint); err != nil {
+		return nil, err
+	}
+	go m.watchLoop(imdb.RegisterDeleteNotifier())
+	return m, nil
+}
+
+// computeFingerprint will compute a checksum of the names, sizes and
+// modification times of the files in the directory tree.
+func computeFingerprint(dirname string) (string, error) {
+	hasher := sha256.New()
+	err := filepath.Walk(dirname,
+		func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hasher, "%s %d %d\n",
+				path, fi.Size(), fi.ModTime().UnixNano())
+			return nil
+		})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+func (m *Manager) load(fingerprint string) error {
+	startTime := time.Now()
+	db, err := vulnerability.LoadDirectory(m.dirname)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.fingerprint = fingerprint
+	m.loadError = err
+	if err != nil {
+		return err
+	}
+	m.db = db
+	m.loadedAt = time.Now()
+	m.results = make(map[string][]vulnerability.Match)
+	m.logger.Printf("Loaded %d vulnerability advisories in %s\n",
+		db.Count(), format.Duration(time.Since(startTime)))
+	return nil
+}
+
+func (m *Manager) watchLoop(deleteChannel <-chan string) {
+	ticker := time.NewTicker(checkInterval)
+	for {
+		select {
+		case name := <-deleteChannel:
+			m.lock.Lock()
+			delete(m.results, name)
+			m.lock.Unlock()
+		case <-ticker.C:
+			fingerprint, err := computeFingerprint(m.dirname)
+			if err != nil {
+				m.logger.Printf("Error checking vulnerability feed: %s\n", err)
+				continue
+			}
+			m.lock.Lock()
+			unchanged := fingerprint == m.fingerprint
+			m.lock.Unlock()
+			if unchanged {
+				continue
+			}
+			if err := m.load(fingerprint); err != nil {
+				m.logger.Printf("Error loading vulnerability feed: %s\n", err)
+			}
+		}
+	}
+}
+
+func (m *Manager) getImageVulnerabilities(imageName string) (
+	[]vulnerability.Match, error) {
+	img := m.imdb.GetImage(imageName)
+	if img == nil {
+		return nil, errors.New("image not found")
+	}
+	m.lock.Lock()
+	db := m.db
+	matches, ok := m.results[imageName]
+	m.lock.Unlock()
+	if ok {
+		return matches, nil
+	}
+	platform, err := m.getPlatform(img)
+	if err != nil {
+		return nil, err
+	}
+	matches = db.Match(platform, img.Packages)
+	m.lock.Lock()
+	if m.db == db {
+		m.results[imageName] = matches
+	}
+	m.lock.Unlock()
+	return matches, nil
+}
+
+// getPlatform will determine the distribution for an image from its SBOM.
+// Without the distribution and source packages from the SBOM, matching would
+// use advisories for the wrong distributions and miss advisories for source
+// packages, so images without an SBOM are rejected.
+func (m *Manager) getPlatform(img *image.Image) (
+	vulnerability.Platform, error) {
+	var platform vulnerability.Platform
+	if img.Sbom == nil || img.Sbom.Object == nil {
+		return platform, errors.New("no SBOM, cannot match vulnerabilities")
+	}
+	_, reader, err := m.imdb.ObjectServer().GetObject(*img.Sbom.Object)
+	if err != nil {
+		return platform, err
+	}
+	defer reader.Close()
+	doc, err := sbom.Decode(reader)
+	if err != nil {
+		return platform, err
+	}
+	platform.Distribution = doc.Distribution
+	platform.Release = doc.Release
+	platform.Scheme = doc.PackageType
+	platform.SourcePackages = make(map[string]string)
+	for _, pkg := range doc.Packages {
+		if pkg.Source != "" {
+			platform.SourcePackages[pkg.Name] = pkg.Source
+		}
+	}
+	return platform, nil
+}
+
+func (m *Manager) writeHtml(writer io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fmt.Fprintf(writer, "Vulnerability feed: %d advisories, loaded %s ago",
+		m.db.Count(), format.Duration(time.Since(m.loadedAt)))
+	if m.loadError != nil {
+		fmt.Fprintf(writer, ", <font color=\"red\">reload error: %s</font>",
+			m.loadError)
+	}
+	fmt.Fprintln(writer, "<br>")
+}
@@ -19,6 +19,9 @@ const (
 	AssignedOIDBase        = "1.3.6.1.4.1.9586.100.7"
 	PermittedMethodListOID = AssignedOIDBase + ".1"
 	GroupListOID           = AssignedOIDBase + ".2"
+	VmOwnerGroupsOID       = AssignedOIDBase + ".3"
+	VmOwnerUsersOID        = AssignedOIDBase + ".4"
+	VmTagsOID              = AssignedOIDBase + ".5"
 
 	DefaultMdbFile = "/var/lib/mdbd/mdb.json"
 )
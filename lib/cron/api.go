@@ -0,0 +1,47 @@
+/*
+	Package cron parses cron schedule expressions and computes the times they
+	match.
+
+	An expression has five space-separated fields: minute (0-59), hour (0-23),
+	day of month (1-31), month (1-12 or JAN-DEC) and day of week (0-7 or
+	SUN-SAT, where 0 and 7 are Sunday). Each field may be "*", a number or a
+	range "a-b", any of which may be followed by "/n" to select every nth
+	value, or a comma-separated list of these.
+	As with cron(8), if both the day of month and day of week are restricted, a
+	day matching either field matches. The shortcuts @yearly, @annually,
+	@monthly, @weekly, @daily, @midnight and @hourly are also supported.
+*/
+package cron
+
+import (
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expression  string
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	domStar     bool
+	dowStar     bool
+}
+
+// Parse parses a cron expression.
+func Parse(expression string) (*Schedule, error) {
+	return parse(expression)
+}
+
+// Next returns the first time after t matched by the schedule, in the
+// location of t. The zero time is returned if there is no match within the
+// next five years (such as for February 30).
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.next(t)
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expression
+}
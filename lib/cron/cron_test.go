@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2018, time.March, 14, 10, 30, 15, 0, time.UTC)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 14, 10, 31, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *",
+			time.Date(2018, time.March, 14, 10, 40, 0, 0, time.UTC)},
+		{"30 2 * * mon-fri",
+			time.Date(2018, time.March, 15, 2, 30, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2018, time.March, 18, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *",
+			time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * FRI",
+			time.Date(2018, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0,
+			time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("%s: %s", test.expression, err)
+			continue
+		}
+		if next := schedule.Next(start); !next.Equal(test.expected) {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.expression, test.expected, next)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("%s: no error", expression)
+		}
+	}
+}
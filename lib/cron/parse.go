@@ -0,0 +1,122 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type fieldType struct {
+	name    string
+	minimum uint
+	maximum uint
+	names   []string // Indexed from minimum.
+}
+
+var (
+	fields = []fieldType{
+		{"minute", 0, 59, nil},
+		{"hour", 0, 23, nil},
+		{"day of month", 1, 31, nil},
+		{"month", 1, 12, []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN",
+			"JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+		{"day of week", 0, 7, []string{"SUN", "MON", "TUE", "WED", "THU",
+			"FRI", "SAT"}},
+	}
+	shortcuts = map[string]string{
+		"@annually": "0 0 1 1 *",
+		"@daily":    "0 0 * * *",
+		"@hourly":   "0 * * * *",
+		"@midnight": "0 0 * * *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@yearly":   "0 0 1 1 *",
+	}
+)
+
+func parse(expression string) (*Schedule, error) {
+	expanded := expression
+	if shortcut, ok := shortcuts[strings.ToLower(expression)]; ok {
+		expanded = shortcut
+	}
+	words := strings.Fields(expanded)
+	if len(words) != len(fields) {
+		return nil, fmt.Errorf("cron expression: \"%s\" has %d fields, not %d",
+			expression, len(words), len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for index, word := range words {
+		var err error
+		bits[index], err = fields[index].parse(word)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression: \"%s\": %s",
+				expression, err)
+		}
+	}
+	if bits[4]&(1<<7) != 0 { // Sunday may be 0 or 7.
+		bits[4] |= 1
+	}
+	return &Schedule{
+		expression:  expression,
+		minutes:     bits[0],
+		hours:       bits[1],
+		daysOfMonth: bits[2],
+		months:      bits[3],
+		daysOfWeek:  bits[4],
+		domStar:     words[2] == "*",
+		dowStar:     words[4] == "*",
+	}, nil
+}
+
+func (field fieldType) parse(word string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(word, ",") {
+		rangeString := item
+		step := uint(1)
+		if index := strings.IndexByte(item, '/'); index >= 0 {
+			rangeString = item[:index]
+			value, err := strconv.ParseUint(item[index+1:], 10, 8)
+			if err != nil || value < 1 {
+				return 0, fmt.Errorf("bad step in %s: %s", field.name, item)
+			}
+			step = uint(value)
+		}
+		first, last := field.minimum, field.maximum
+		if rangeString != "*" {
+			bounds := strings.SplitN(rangeString, "-", 2)
+			var err error
+			if first, err = field.parseValue(bounds[0]); err != nil {
+				return 0, err
+			}
+			last = first
+			if len(bounds) == 2 {
+				if last, err = field.parseValue(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				last = field.maximum // "a/n" means "a-max/n".
+			}
+			if last < first {
+				return 0, fmt.Errorf("bad range in %s: %s", field.name, item)
+			}
+		}
+		for value := first; value <= last; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func (field fieldType) parseValue(word string) (uint, error) {
+	for index, name := range field.names {
+		if strings.EqualFold(word, name) {
+			return field.minimum + uint(index), nil
+		}
+	}
+	value, err := strconv.ParseUint(word, 10, 8)
+	if err != nil || uint(value) < field.minimum ||
+		uint(value) > field.maximum {
+		return 0, fmt.Errorf("bad %s: %s", field.name, word)
+	}
+	return uint(value), nil
+}
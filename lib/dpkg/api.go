@@ -0,0 +1,21 @@
+package dpkg
+
+import (
+	"io"
+)
+
+const StatusFile = "/var/lib/dpkg/status"
+
+type Package struct {
+	Architecture string
+	Name         string
+	Size         uint64 // Bytes.
+	Source       string // Name of source package, without version.
+	Version      string
+}
+
+// ParseStatus will read a dpkg status file (usually /var/lib/dpkg/status) and
+// will return the installed packages in the order they are listed.
+func ParseStatus(reader io.Reader) ([]Package, error) {
+	return parseStatus(reader)
+}
@@ -0,0 +1,62 @@
+package dpkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func parseStatus(reader io.Reader) ([]Package, error) {
+	var packages []Package
+	var pkg Package
+	var installed bool
+	addPackage := func() {
+		if installed && pkg.Name != "" {
+			packages = append(packages, pkg)
+		}
+		pkg = Package{}
+		installed = false
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			addPackage()
+			continue
+		}
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(split[1])
+		switch split[0] {
+		case "Architecture":
+			pkg.Architecture = value
+		case "Installed-Size":
+			size, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad size for: %s: %s", pkg.Name, err)
+			}
+			pkg.Size = size << 10
+		case "Package":
+			pkg.Name = value
+		case "Source":
+			// May include a version, such as: "glibc (2.28-10)".
+			if fields := strings.Fields(value); len(fields) > 0 {
+				pkg.Source = fields[0]
+			}
+		case "Status":
+			installed = strings.HasSuffix(value, " installed")
+		case "Version":
+			pkg.Version = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	addPackage()
+	return packages, nil
+}
@@ -0,0 +1,41 @@
+package dpkg
+
+import (
+	"strings"
+	"testing"
+)
+
+const testStatus = `Package: libc6
+Status: install ok installed
+Architecture: amd64
+Source: glibc (2.28-10)
+Version: 2.28-10
+Installed-Size: 12
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+
+Package: empty-source
+Status: install ok installed
+Source:
+Version: 1.0
+`
+
+func TestParseStatus(t *testing.T) {
+	packages, err := ParseStatus(strings.NewReader(testStatus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got: %v", packages)
+	}
+	if pkg := packages[0]; pkg.Name != "libc6" || pkg.Source != "glibc" ||
+		pkg.Architecture != "amd64" || pkg.Size != 12<<10 ||
+		pkg.Version != "2.28-10" {
+		t.Errorf("unexpected package: %v", pkg)
+	}
+	if pkg := packages[1]; pkg.Name != "empty-source" || pkg.Source != "" {
+		t.Errorf("unexpected package: %v", pkg)
+	}
+}
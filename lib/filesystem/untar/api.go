@@ -17,3 +17,21 @@ func Decode(tarReader *tar.Reader, hasher Hasher, filter *filter.Filter) (
 	*filesystem.FileSystem, error) {
 	return decode(tarReader, hasher, filter)
 }
+
+// IsExcluded returns true if the specified pathname (which must be absolute)
+// should never be included in an image (the subd private directory).
+func IsExcluded(pathname string) bool {
+	return isExcluded(pathname)
+}
+
+// MakeDirectoryInode will make a directory inode from the tar header.
+func MakeDirectoryInode(header *tar.Header) *filesystem.DirectoryInode {
+	return makeDirectoryInode(header)
+}
+
+// MakeInode will make a regular file, symlink or special inode from the tar
+// header. For regular files, hashVal is the hash of the file data.
+func MakeInode(header *tar.Header, hashVal hash.Hash) (
+	filesystem.GenericInode, error) {
+	return makeInode(header, hashVal)
+}
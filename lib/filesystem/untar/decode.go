@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 	"path"
-	"strings"
 	"syscall"
 
 	"github.com/Symantec/Dominator/lib/filesystem"
 	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/hash"
 )
 
 type decoderData struct {
@@ -41,8 +41,7 @@ func decode(tarReader *tar.Reader, hasher Hasher, filter *filter.Filter) (
 			return nil, err
 		}
 		header.Name = normaliseFilename(header.Name)
-		if header.Name == "/.subd" ||
-			strings.HasPrefix(header.Name, "/.subd/") {
+		if isExcluded(header.Name) {
 			continue
 		}
 		if filter != nil && filter.Match(header.Name) {
@@ -105,38 +104,28 @@ func (decoderData *decoderData) addHeader(tarReader *tar.Reader, hasher Hasher,
 func (decoderData *decoderData) addRegularFile(tarReader *tar.Reader,
 	hasher Hasher, header *tar.Header, parent *filesystem.DirectoryInode,
 	name string) error {
-	var newInode filesystem.RegularInode
-	newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
-		syscall.S_IFREG)
-	newInode.Uid = uint32(header.Uid)
-	newInode.Gid = uint32(header.Gid)
-	newInode.MtimeNanoSeconds = int32(header.ModTime.Nanosecond())
-	newInode.MtimeSeconds = header.ModTime.Unix()
-	newInode.Size = uint64(header.Size)
+	var hashVal hash.Hash
 	if header.Size > 0 {
 		var err error
-		newInode.Hash, err = hasher.Hash(tarReader, uint64(header.Size))
+		hashVal, err = hasher.Hash(tarReader, uint64(header.Size))
 		if err != nil {
 			return err
 		}
 	}
-	decoderData.addEntry(parent, header.Name, name, &newInode)
+	decoderData.addEntry(parent, header.Name, name,
+		makeRegularInode(header, hashVal))
 	return nil
 }
 
 func (decoderData *decoderData) addDirectory(header *tar.Header,
 	parent *filesystem.DirectoryInode, name string) error {
-	var newInode filesystem.DirectoryInode
-	newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
-		syscall.S_IFDIR)
-	newInode.Uid = uint32(header.Uid)
-	newInode.Gid = uint32(header.Gid)
+	newInode := makeDirectoryInode(header)
 	if header.Name == "/" {
-		*decoderData.directoryTable[header.Name] = newInode
+		*decoderData.directoryTable[header.Name] = *newInode
 		return nil
 	}
-	decoderData.addEntry(parent, header.Name, name, &newInode)
-	decoderData.directoryTable[header.Name] = &newInode
+	decoderData.addEntry(parent, header.Name, name, newInode)
+	decoderData.directoryTable[header.Name] = newInode
 	return nil
 }
 
@@ -157,39 +146,17 @@ func (decoderData *decoderData) addHardlink(header *tar.Header,
 
 func (decoderData *decoderData) addSymlink(header *tar.Header,
 	parent *filesystem.DirectoryInode, name string) error {
-	var newInode filesystem.SymlinkInode
-	newInode.Uid = uint32(header.Uid)
-	newInode.Gid = uint32(header.Gid)
-	newInode.Symlink = header.Linkname
-	decoderData.addEntry(parent, header.Name, name, &newInode)
+	decoderData.addEntry(parent, header.Name, name, makeSymlinkInode(header))
 	return nil
 }
 
 func (decoderData *decoderData) addSpecialFile(header *tar.Header,
 	parent *filesystem.DirectoryInode, name string) error {
-	var newInode filesystem.SpecialInode
-	if header.Typeflag == tar.TypeChar {
-		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
-			syscall.S_IFCHR)
-	} else if header.Typeflag == tar.TypeBlock {
-		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
-			syscall.S_IFBLK)
-	} else if header.Typeflag == tar.TypeFifo {
-		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
-			syscall.S_IFIFO)
-	} else {
-		return errors.New(fmt.Sprintf("unsupported type: %v", header.Typeflag))
-	}
-	newInode.Uid = uint32(header.Uid)
-	newInode.Gid = uint32(header.Gid)
-	newInode.MtimeNanoSeconds = int32(header.ModTime.Nanosecond())
-	newInode.MtimeSeconds = header.ModTime.Unix()
-	if header.Devminor > 255 {
-		return errors.New(fmt.Sprintf("minor device number: %d too large",
-			header.Devminor))
+	newInode, err := makeSpecialInode(header)
+	if err != nil {
+		return err
 	}
-	newInode.Rdev = uint64(header.Devmajor<<8 | header.Devminor)
-	decoderData.addEntry(parent, header.Name, name, &newInode)
+	decoderData.addEntry(parent, header.Name, name, newInode)
 	return nil
 }
 
@@ -0,0 +1,87 @@
+package untar
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+func makeDirectoryInode(header *tar.Header) *filesystem.DirectoryInode {
+	var newInode filesystem.DirectoryInode
+	newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+		syscall.S_IFDIR)
+	newInode.Uid = uint32(header.Uid)
+	newInode.Gid = uint32(header.Gid)
+	return &newInode
+}
+
+func makeInode(header *tar.Header, hashVal hash.Hash) (
+	filesystem.GenericInode, error) {
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		return makeRegularInode(header, hashVal), nil
+	case tar.TypeSymlink:
+		return makeSymlinkInode(header), nil
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return makeSpecialInode(header)
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported type: %v", header.Typeflag))
+}
+
+func makeRegularInode(header *tar.Header,
+	hashVal hash.Hash) *filesystem.RegularInode {
+	var newInode filesystem.RegularInode
+	newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+		syscall.S_IFREG)
+	newInode.Uid = uint32(header.Uid)
+	newInode.Gid = uint32(header.Gid)
+	newInode.MtimeNanoSeconds = int32(header.ModTime.Nanosecond())
+	newInode.MtimeSeconds = header.ModTime.Unix()
+	newInode.Size = uint64(header.Size)
+	newInode.Hash = hashVal
+	return &newInode
+}
+
+func makeSymlinkInode(header *tar.Header) *filesystem.SymlinkInode {
+	var newInode filesystem.SymlinkInode
+	newInode.Uid = uint32(header.Uid)
+	newInode.Gid = uint32(header.Gid)
+	newInode.Symlink = header.Linkname
+	return &newInode
+}
+
+func makeSpecialInode(header *tar.Header) (*filesystem.SpecialInode, error) {
+	var newInode filesystem.SpecialInode
+	if header.Typeflag == tar.TypeChar {
+		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+			syscall.S_IFCHR)
+	} else if header.Typeflag == tar.TypeBlock {
+		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+			syscall.S_IFBLK)
+	} else if header.Typeflag == tar.TypeFifo {
+		newInode.Mode = filesystem.FileMode((header.Mode & ^syscall.S_IFMT) |
+			syscall.S_IFIFO)
+	} else {
+		return nil, errors.New(fmt.Sprintf("unsupported type: %v",
+			header.Typeflag))
+	}
+	newInode.Uid = uint32(header.Uid)
+	newInode.Gid = uint32(header.Gid)
+	newInode.MtimeNanoSeconds = int32(header.ModTime.Nanosecond())
+	newInode.MtimeSeconds = header.ModTime.Unix()
+	if header.Devminor > 255 {
+		return nil, errors.New(fmt.Sprintf("minor device number: %d too large",
+			header.Devminor))
+	}
+	newInode.Rdev = uint64(header.Devmajor<<8 | header.Devminor)
+	return &newInode, nil
+}
+
+func isExcluded(name string) bool {
+	return name == "/.subd" || strings.HasPrefix(name, "/.subd/")
+}
@@ -35,6 +35,8 @@ type Image struct {
 	CreatedOn    time.Time
 	ExpiresAt    time.Time
 	Packages     []Package
+	Sbom         *Annotation // Software bill of materials: lib/sbom.Document.
+	TestResults  []TestResult
 }
 
 type Package struct {
@@ -43,6 +45,12 @@ type Package struct {
 	Version string
 }
 
+type TestResult struct {
+	Name     string
+	Duration time.Duration
+	Error    string `json:",omitempty"` // Empty if the test passed.
+}
+
 // ForEachObject will call objectFunc for all objects (including those for
 // annotations) for the image. If objectFunc returns a non-nil error, processing
 // stops and the error is returned.
@@ -79,6 +87,12 @@ func (image *Image) Verify() error {
 	return image.verify()
 }
 
+// TestsFailed returns true if any of the tests run when the image was built
+// failed.
+func (image *Image) TestsFailed() bool {
+	return image.testsFailed()
+}
+
 func (image *Image) VerifyObjects(checker objectserver.ObjectsChecker) error {
 	return image.verifyObjects(checker)
 }
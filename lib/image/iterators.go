@@ -25,5 +25,10 @@ func (image *Image) forEachObject(objectFunc func(hash.Hash) error) error {
 			return err
 		}
 	}
+	if image.Sbom != nil && image.Sbom.Object != nil {
+		if err := objectFunc(*image.Sbom.Object); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -30,6 +30,15 @@ func verifyDirectory(directoryInode *filesystem.DirectoryInode,
 	return nil
 }
 
+func (image *Image) testsFailed() bool {
+	for _, result := range image.TestResults {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (image *Image) verifyObjects(checker objectserver.ObjectsChecker) error {
 	missingObjects, err := image.ListMissingObjects(checker)
 	if err != nil {
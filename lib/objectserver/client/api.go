@@ -6,6 +6,7 @@ import (
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
 )
 
 type ObjectClient struct {
@@ -46,6 +47,10 @@ func (objClient *ObjectClient) GetObjects(hashes []hash.Hash) (
 	return objClient.getObjects(hashes)
 }
 
+func (objClient *ObjectClient) GetScrubStatus() (proto.ScrubStatus, error) {
+	return objClient.getScrubStatus()
+}
+
 func (objClient *ObjectClient) SetExclusiveGetObjects(exclusive bool) {
 	objClient.exclusiveGet = exclusive
 }
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/proto/objectserver"
+)
+
+func (objClient *ObjectClient) getScrubStatus() (
+	objectserver.ScrubStatus, error) {
+	var request objectserver.GetScrubStatusRequest
+	var reply objectserver.GetScrubStatusResponse
+	client, err := objClient.getClient()
+	if err != nil {
+		return objectserver.ScrubStatus{}, err
+	}
+	err = client.RequestReply("ObjectServer.GetScrubStatus", request, &reply)
+	if err != nil {
+		return objectserver.ScrubStatus{}, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return objectserver.ScrubStatus{}, err
+	}
+	return reply.Status, nil
+}
@@ -6,9 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/fsrateio"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
 )
 
 var (
@@ -27,6 +29,13 @@ type ObjectServer struct {
 	sizesMap              map[hash.Hash]uint64 // Only set if object is known.
 	lastGarbageCollection time.Time
 	lastMutationTime      time.Time
+	scrubber              *scrubberType
+}
+
+type ScrubberOptions struct {
+	Interval      time.Duration // Minimum time between the start of passes.
+	ReaderContext *fsrateio.ReaderContext
+	RepairSource  objectserver.ObjectGetter // Optional.
 }
 
 func NewObjectServer(baseDir string, logger log.Logger) (
@@ -81,6 +90,11 @@ func (objSrv *ObjectServer) GetObjects(hashes []hash.Hash) (
 	return objSrv.getObjects(hashes)
 }
 
+// GetScrubStatus will return the status of the background scrubber.
+func (objSrv *ObjectServer) GetScrubStatus() proto.ScrubStatus {
+	return objSrv.getScrubStatus()
+}
+
 func (objSrv *ObjectServer) LastMutationTime() time.Time {
 	objSrv.rwLock.RLock()
 	defer objSrv.rwLock.RUnlock()
@@ -101,6 +115,14 @@ func (objSrv *ObjectServer) NumObjects() uint64 {
 	return uint64(len(objSrv.sizesMap))
 }
 
+// StartScrubber will start a goroutine which periodically re-reads all stored
+// objects and verifies their hashes. Objects are read at the rate permitted by
+// options.ReaderContext. Corrupt objects are moved to a quarantine area and if
+// options.RepairSource is not nil a good copy is fetched from it.
+func (objSrv *ObjectServer) StartScrubber(options ScrubberOptions) error {
+	return objSrv.startScrubber(options)
+}
+
 // StashOrVerifyObject will stash an object if it is new or it will verify if it
 // already exists. Object data are read from reader (length bytes are read). The
 // object hash is computed and compared with expectedHash if not nil.
@@ -3,6 +3,7 @@ package filesystem
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/format"
 )
@@ -24,4 +25,33 @@ func (objSrv *ObjectServer) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer,
 		"Number of objects: %d, consuming %s (FS is %.1f%% full)<br>\n",
 		numObjects, format.FormatBytes(totalBytes), utilisation)
+	objSrv.writeScrubberHtml(writer)
+}
+
+func (objSrv *ObjectServer) writeScrubberHtml(writer io.Writer) {
+	status := objSrv.getScrubStatus()
+	if !status.Enabled {
+		return
+	}
+	if status.PassInProgress {
+		fmt.Fprintf(writer,
+			"Scrubber: scanned %d of %d objects (%s) in %s<br>\n",
+			status.NumObjectsScanned, status.NumObjectsToScan,
+			format.FormatBytes(status.NumBytesScanned),
+			format.Duration(time.Since(status.CurrentPassStartTime)))
+	} else if status.NumPassesCompleted > 0 {
+		fmt.Fprintf(writer,
+			"Scrubber: last pass scanned %d objects (%s) in %s<br>\n",
+			status.NumObjectsScanned,
+			format.FormatBytes(status.NumBytesScanned),
+			format.Duration(status.LastPassFinishTime.Sub(
+				status.LastPassStartTime)))
+	}
+	if status.NumCorruptObjects > 0 || len(status.QuarantinedObjects) > 0 {
+		fmt.Fprintf(writer,
+			"Scrubber: <font color=\"red\">%d corrupt objects found</font>, "+
+				"%d repaired, %d in quarantine<br>\n",
+			status.NumCorruptObjects, status.NumRepairedObjects,
+			len(status.QuarantinedObjects))
+	}
 }
@@ -0,0 +1,348 @@
+package filesystem
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
+	"github.com/Symantec/tricorder/go/tricorder"
+	"github.com/Symantec/tricorder/go/tricorder/units"
+)
+
+const quarantineDirectory = ".quarantine"
+
+type scrubberType struct {
+	options     ScrubberOptions
+	lock        sync.Mutex // Protect everything below.
+	status      proto.ScrubStatus
+	quarantined map[hash.Hash]proto.QuarantinedObject
+}
+
+func (objSrv *ObjectServer) startScrubber(options ScrubberOptions) error {
+	if options.ReaderContext == nil {
+		return errors.New("no reader context specified")
+	}
+	if options.Interval < time.Minute {
+		options.Interval = time.Minute
+	}
+	scrubber := &scrubberType{
+		options:     options,
+		quarantined: make(map[hash.Hash]proto.QuarantinedObject),
+	}
+	scrubber.status.Enabled = true
+	if err := objSrv.loadQuarantine(scrubber); err != nil {
+		return err
+	}
+	objSrv.rwLock.Lock()
+	if objSrv.scrubber != nil {
+		objSrv.rwLock.Unlock()
+		return errors.New("scrubber already started")
+	}
+	objSrv.scrubber = scrubber
+	objSrv.rwLock.Unlock()
+	if err := scrubber.registerMetrics(); err != nil {
+		return err
+	}
+	go objSrv.scrubLoop(scrubber)
+	return nil
+}
+
+func (objSrv *ObjectServer) loadQuarantine(scrubber *scrubberType) error {
+	dirname := path.Join(objSrv.baseDir, quarantineDirectory)
+	if _, err := os.Stat(dirname); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now()
+	var mutex sync.Mutex
+	return scan.ScanTree(dirname, func(hashVal hash.Hash, size uint64) {
+		mutex.Lock()
+		scrubber.quarantined[hashVal] = proto.QuarantinedObject{
+			Hash:           hashVal,
+			Length:         size,
+			QuarantineTime: now,
+			Reason:         "found in quarantine at startup",
+		}
+		mutex.Unlock()
+	})
+}
+
+func (scrubber *scrubberType) registerMetrics() error {
+	dir, err := tricorder.RegisterDirectory("/objectserver/scrubber")
+	if err != nil {
+		return err
+	}
+	metrics := []struct {
+		name        string
+		getter      func(status *proto.ScrubStatus) uint64
+		description string
+	}{
+		{"num-passes-completed",
+			func(s *proto.ScrubStatus) uint64 { return s.NumPassesCompleted },
+			"number of completed scrub passes"},
+		{"num-objects-scanned",
+			func(s *proto.ScrubStatus) uint64 { return s.NumObjectsScanned },
+			"number of objects scanned in the current/last pass"},
+		{"num-bytes-scanned",
+			func(s *proto.ScrubStatus) uint64 { return s.NumBytesScanned },
+			"number of bytes scanned in the current/last pass"},
+		{"num-corrupt-objects",
+			func(s *proto.ScrubStatus) uint64 { return s.NumCorruptObjects },
+			"number of corrupt objects found since startup"},
+		{"num-repaired-objects",
+			func(s *proto.ScrubStatus) uint64 { return s.NumRepairedObjects },
+			"number of objects repaired since startup"},
+		{"num-repair-failures",
+			func(s *proto.ScrubStatus) uint64 { return s.NumRepairFailures },
+			"number of failed repair attempts since startup"},
+	}
+	for _, metric := range metrics {
+		getter := metric.getter
+		err := dir.RegisterMetric(metric.name,
+			func() uint64 {
+				scrubber.lock.Lock()
+				defer scrubber.lock.Unlock()
+				return getter(&scrubber.status)
+			},
+			units.None, metric.description)
+		if err != nil {
+			return err
+		}
+	}
+	err = dir.RegisterMetric("num-quarantined-objects",
+		func() uint64 {
+			scrubber.lock.Lock()
+			defer scrubber.lock.Unlock()
+			return uint64(len(scrubber.quarantined))
+		},
+		units.None, "number of objects currently in quarantine")
+	if err != nil {
+		return err
+	}
+	rateDir, err := tricorder.RegisterDirectory("/objectserver/scrubber/read")
+	if err != nil {
+		return err
+	}
+	return scrubber.options.ReaderContext.RegisterMetrics(rateDir)
+}
+
+func (objSrv *ObjectServer) getScrubStatus() proto.ScrubStatus {
+	objSrv.rwLock.RLock()
+	scrubber := objSrv.scrubber
+	objSrv.rwLock.RUnlock()
+	if scrubber == nil {
+		return proto.ScrubStatus{}
+	}
+	scrubber.lock.Lock()
+	defer scrubber.lock.Unlock()
+	status := scrubber.status
+	status.QuarantinedObjects = make([]proto.QuarantinedObject, 0,
+		len(scrubber.quarantined))
+	for _, object := range scrubber.quarantined {
+		status.QuarantinedObjects = append(status.QuarantinedObjects, object)
+	}
+	sort.Slice(status.QuarantinedObjects, func(left, right int) bool {
+		return status.QuarantinedObjects[left].QuarantineTime.Before(
+			status.QuarantinedObjects[right].QuarantineTime)
+	})
+	return status
+}
+
+func (objSrv *ObjectServer) scrubLoop(scrubber *scrubberType) {
+	for {
+		startTime := time.Now()
+		objSrv.repairQuarantinedObjects(scrubber)
+		objSrv.scrubPass(scrubber)
+		sleepTime := scrubber.options.Interval - time.Since(startTime)
+		if sleepTime > 0 {
+			time.Sleep(sleepTime)
+		}
+	}
+}
+
+func (objSrv *ObjectServer) scrubPass(scrubber *scrubberType) {
+	hashes := objSrv.listObjects()
+	startTime := time.Now()
+	scrubber.lock.Lock()
+	scrubber.status.PassInProgress = true
+	scrubber.status.CurrentPassStartTime = startTime
+	scrubber.status.NumObjectsToScan = uint64(len(hashes))
+	scrubber.status.NumObjectsScanned = 0
+	scrubber.status.NumBytesScanned = 0
+	scrubber.lock.Unlock()
+	var numCorrupt uint
+	for _, hashVal := range hashes {
+		nBytes, corrupt, err := objSrv.scrubObject(scrubber, hashVal)
+		if err != nil {
+			objSrv.logger.Printf("Error scrubbing object: %x: %s\n",
+				hashVal, err)
+		}
+		scrubber.lock.Lock()
+		scrubber.status.NumObjectsScanned++
+		scrubber.status.NumBytesScanned += nBytes
+		if corrupt {
+			numCorrupt++
+			scrubber.status.NumCorruptObjects++
+		}
+		scrubber.lock.Unlock()
+		if corrupt {
+			objSrv.quarantineObject(scrubber, hashVal, err.Error())
+		}
+	}
+	scrubber.lock.Lock()
+	scrubber.status.PassInProgress = false
+	scrubber.status.LastPassStartTime = startTime
+	scrubber.status.LastPassFinishTime = time.Now()
+	scrubber.status.NumPassesCompleted++
+	numBytes := scrubber.status.NumBytesScanned
+	scrubber.lock.Unlock()
+	objSrv.logger.Printf(
+		"Scrubbed %d objects (%s) in %s, %d corrupt\n",
+		len(hashes), format.FormatBytes(numBytes),
+		format.Duration(time.Since(startTime)), numCorrupt)
+	if numCorrupt > 0 {
+		objSrv.repairQuarantinedObjects(scrubber)
+	}
+}
+
+// scrubObject will read the object and verify its hash. It returns the number
+// of bytes read, whether the object is known to be corrupt and any error. Only
+// a length or hash mismatch or an I/O error from the storage media mark the
+// object as corrupt. Other errors (such as running out of file descriptors)
+// may be transient, so they are only reported. If the object has gone away,
+// no error is returned.
+func (objSrv *ObjectServer) scrubObject(scrubber *scrubberType,
+	hashVal hash.Hash) (uint64, bool, error) {
+	objSrv.rwLock.RLock()
+	expectedSize, ok := objSrv.sizesMap[hashVal]
+	objSrv.rwLock.RUnlock()
+	if !ok {
+		return 0, false, nil // Deleted since the start of the pass.
+	}
+	filename := path.Join(objSrv.baseDir, objectcache.HashToFilename(hashVal))
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, isIoError(err), err
+	}
+	defer file.Close()
+	hasher := sha512.New()
+	nCopied, err := io.Copy(hasher,
+		scrubber.options.ReaderContext.NewReader(file))
+	if err != nil {
+		return uint64(nCopied), isIoError(err), err
+	}
+	if uint64(nCopied) != expectedSize {
+		return uint64(nCopied), true, fmt.Errorf(
+			"length mismatch: read: %d, expected: %d", nCopied, expectedSize)
+	}
+	var computedHash hash.Hash
+	copy(computedHash[:], hasher.Sum(nil))
+	if computedHash != hashVal {
+		return uint64(nCopied), true, fmt.Errorf(
+			"hash mismatch: computed: %x", computedHash)
+	}
+	return uint64(nCopied), false, nil
+}
+
+// isIoError returns true if err is an EIO error, which indicates a media
+// failure.
+func isIoError(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return err == syscall.EIO
+}
+
+func (objSrv *ObjectServer) quarantineObject(scrubber *scrubberType,
+	hashVal hash.Hash, reason string) {
+	hashName := objectcache.HashToFilename(hashVal)
+	filename := path.Join(objSrv.baseDir, hashName)
+	quarantineFilename := path.Join(objSrv.baseDir, quarantineDirectory,
+		hashName)
+	err := os.MkdirAll(path.Dir(quarantineFilename), syscall.S_IRWXU)
+	if err != nil {
+		objSrv.logger.Printf("Error creating quarantine directory: %s\n", err)
+		return
+	}
+	objSrv.rwLock.Lock()
+	size := objSrv.sizesMap[hashVal]
+	if err := os.Rename(filename, quarantineFilename); err != nil {
+		objSrv.rwLock.Unlock()
+		objSrv.logger.Printf("Error quarantining object: %x: %s\n",
+			hashVal, err)
+		return
+	}
+	delete(objSrv.sizesMap, hashVal)
+	objSrv.lastMutationTime = time.Now()
+	objSrv.rwLock.Unlock()
+	objSrv.logger.Printf("Quarantined object: %x\n", hashVal)
+	scrubber.lock.Lock()
+	scrubber.quarantined[hashVal] = proto.QuarantinedObject{
+		Hash:           hashVal,
+		Length:         size,
+		QuarantineTime: time.Now(),
+		Reason:         reason,
+	}
+	scrubber.lock.Unlock()
+}
+
+func (objSrv *ObjectServer) repairQuarantinedObjects(scrubber *scrubberType) {
+	if scrubber.options.RepairSource == nil {
+		return
+	}
+	scrubber.lock.Lock()
+	hashes := make([]hash.Hash, 0, len(scrubber.quarantined))
+	for hashVal := range scrubber.quarantined {
+		hashes = append(hashes, hashVal)
+	}
+	scrubber.lock.Unlock()
+	for _, hashVal := range hashes {
+		err := objSrv.repairObject(scrubber.options.RepairSource, hashVal)
+		scrubber.lock.Lock()
+		if err != nil {
+			scrubber.status.NumRepairFailures++
+		} else {
+			scrubber.status.NumRepairedObjects++
+			delete(scrubber.quarantined, hashVal)
+		}
+		scrubber.lock.Unlock()
+		if err != nil {
+			objSrv.logger.Printf("Error repairing object: %x: %s\n",
+				hashVal, err)
+		} else {
+			objSrv.logger.Printf("Repaired object: %x\n", hashVal)
+		}
+	}
+}
+
+func (objSrv *ObjectServer) repairObject(
+	repairSource objectserver.ObjectGetter, hashVal hash.Hash) error {
+	length, reader, err := repairSource.GetObject(hashVal)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if _, _, err := objSrv.addObject(reader, length, &hashVal); err != nil {
+		return err
+	}
+	return os.Remove(path.Join(objSrv.baseDir, quarantineDirectory,
+		objectcache.HashToFilename(hashVal)))
+}
@@ -0,0 +1,38 @@
+package oci
+
+import (
+	"io"
+
+	"github.com/Symantec/Dominator/lib/filesystem/untar"
+	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
+)
+
+type EncodeOptions struct {
+	MaxLayerSize uint64 // If non-zero, split into layers of about this size.
+	Tag          string // Optional reference, such as "name:latest".
+}
+
+// Decode will read the OCI image layout directory or tarball (either an OCI
+// image layout or the output of "docker save") specified by source and will
+// apply the layers in order, including whiteouts and opaque directories. The
+// data for regular files are passed to hasher. Files matching filter are
+// skipped. An image with the resulting FileSystem is returned. If a dpkg or RPM
+// package database is present the Packages field is also filled in. As with
+// untar.Decode, the /.subd directory is never included.
+func Decode(source string, hasher untar.Hasher, filter *filter.Filter,
+	logger log.Logger) (*image.Image, error) {
+	return decode(source, hasher, filter, logger)
+}
+
+// Encode will write an OCI image layout tarball for img to writer, which may
+// be loaded by standard container tooling. File data are read from
+// objectsGetter. The filter and triggers are stored as annotations (and config
+// labels). By default a single layer is written. If options.MaxLayerSize is
+// non-zero the file-system is split (in path order) into multiple layers.
+func Encode(writer io.Writer, img *image.Image,
+	objectsGetter objectserver.ObjectsGetter, options EncodeOptions) error {
+	return encode(writer, img, objectsGetter, options)
+}
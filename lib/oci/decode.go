@@ -0,0 +1,363 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filesystem/untar"
+	"github.com/Symantec/Dominator/lib/filter"
+	dhash "github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+const (
+	dirPerms = syscall.S_IRWXU | syscall.S_IRGRP | syscall.S_IXGRP |
+		syscall.S_IROTH | syscall.S_IXOTH
+
+	opaqueWhiteout = ".wh..wh..opq"
+	whiteoutPrefix = ".wh."
+)
+
+type inodeType struct {
+	header *tar.Header // nil for implicitly created directories.
+	hash   dhash.Hash
+	data   []byte // Only retained for package databases.
+}
+
+type nodeType struct {
+	inode    *inodeType // Shared by hardlinks.
+	layer    int
+	children map[string]*nodeType // Only set for directories.
+}
+
+type decoderType struct {
+	filter          *filter.Filter
+	hasher          untar.Hasher
+	layer           int
+	root            *nodeType
+	fileSystem      *filesystem.FileSystem
+	inodeNumbers    map[*inodeType]uint64
+	nextInodeNumber uint64
+}
+
+func decode(source string, hasher untar.Hasher, filter *filter.Filter,
+	logger log.Logger) (*image.Image, error) {
+	opener, err := newBlobOpener(source)
+	if err != nil {
+		return nil, err
+	}
+	defer opener.Close()
+	layers, err := getLayers(opener)
+	if err != nil {
+		return nil, err
+	}
+	decoder := &decoderType{
+		filter: filter,
+		hasher: hasher,
+		root:   newDirectoryNode(nil, 0),
+	}
+	for index, layer := range layers {
+		decoder.layer = index + 1
+		if err := decoder.processBlob(opener, layer); err != nil {
+			return nil, fmt.Errorf("error processing layer: %s: %s",
+				layer.name, err)
+		}
+	}
+	fs, err := decoder.buildFileSystem()
+	if err != nil {
+		return nil, err
+	}
+	packages, err := decoder.listPackages(logger)
+	if err != nil {
+		logger.Printf("Error listing packages: %s\n", err)
+	}
+	return &image.Image{FileSystem: fs, Packages: packages}, nil
+}
+
+func newDirectoryNode(header *tar.Header, layer int) *nodeType {
+	return &nodeType{
+		inode:    &inodeType{header: header},
+		layer:    layer,
+		children: make(map[string]*nodeType),
+	}
+}
+
+func normaliseFilename(filename string) string {
+	return path.Clean("/" + filename)
+}
+
+func (d *decoderType) processBlob(opener blobOpener, layer layerType) error {
+	blob, err := opener.Open(layer.name)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	var digester hash.Hash
+	var rawReader io.Reader = blob
+	if strings.HasPrefix(layer.digest, "sha256:") {
+		digester = sha256.New()
+		rawReader = io.TeeReader(blob, digester)
+	}
+	bufferedReader := bufio.NewReader(rawReader)
+	magic, err := bufferedReader.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var reader io.Reader = bufferedReader
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	} else if bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		return errors.New("zstd compressed layers are not supported")
+	}
+	if err := d.processLayer(tar.NewReader(reader)); err != nil {
+		return err
+	}
+	if digester == nil {
+		return nil
+	}
+	if _, err := io.Copy(ioutil.Discard, bufferedReader); err != nil {
+		return err
+	}
+	computedDigest := fmt.Sprintf("sha256:%x", digester.Sum(nil))
+	if computedDigest != layer.digest {
+		return fmt.Errorf("digest mismatch: computed: %s", computedDigest)
+	}
+	return nil
+}
+
+func (d *decoderType) processLayer(tarReader *tar.Reader) error {
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		name := normaliseFilename(header.Name)
+		dirname := path.Dir(name)
+		leafName := path.Base(name)
+		if leafName == opaqueWhiteout {
+			if node := d.lookup(dirname); node != nil {
+				d.removeLowerLayers(node)
+			}
+			continue
+		}
+		if strings.HasPrefix(leafName, whiteoutPrefix) {
+			d.whiteout(path.Join(dirname, leafName[len(whiteoutPrefix):]))
+			continue
+		}
+		if untar.IsExcluded(name) {
+			continue
+		}
+		if d.filter != nil && d.filter.Match(name) {
+			continue
+		}
+		if name == "/" {
+			if header.Typeflag == tar.TypeDir {
+				d.root.inode = &inodeType{header: header}
+			}
+			continue
+		}
+		if err := d.addEntry(tarReader, header, name); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+func (d *decoderType) lookup(name string) *nodeType {
+	node := d.root
+	if name == "/" {
+		return node
+	}
+	for _, component := range strings.Split(name[1:], "/") {
+		if node.children == nil {
+			return nil
+		}
+		if node = node.children[component]; node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// makeParents will return the directory node for dirname, creating missing
+// directories as needed.
+func (d *decoderType) makeParents(dirname string) (*nodeType, error) {
+	node := d.root
+	if dirname == "/" {
+		return node, nil
+	}
+	for _, component := range strings.Split(dirname[1:], "/") {
+		child := node.children[component]
+		if child == nil {
+			child = newDirectoryNode(nil, d.layer)
+			node.children[component] = child
+		} else if child.children == nil {
+			return nil, fmt.Errorf("parent: %s is not a directory", component)
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// removeLowerLayers will remove all entries under node which were added by
+// lower layers.
+func (d *decoderType) removeLowerLayers(node *nodeType) {
+	for name, child := range node.children {
+		if child.layer < d.layer {
+			delete(node.children, name)
+		} else if child.children != nil {
+			d.removeLowerLayers(child)
+		}
+	}
+}
+
+func (d *decoderType) whiteout(name string) {
+	parent := d.lookup(path.Dir(name))
+	if parent == nil || parent.children == nil {
+		return
+	}
+	leafName := path.Base(name)
+	if child := parent.children[leafName]; child != nil &&
+		child.layer < d.layer {
+		delete(parent.children, leafName)
+	}
+}
+
+func (d *decoderType) addEntry(tarReader *tar.Reader, header *tar.Header,
+	name string) error {
+	parent, err := d.makeParents(path.Dir(name))
+	if err != nil {
+		return err
+	}
+	leafName := path.Base(name)
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if old := parent.children[leafName]; old != nil && old.children != nil {
+			old.inode = &inodeType{header: header}
+			old.layer = d.layer
+		} else {
+			parent.children[leafName] = newDirectoryNode(header, d.layer)
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		inode := &inodeType{header: header}
+		if header.Size > 0 {
+			var reader io.Reader = tarReader
+			var buffer *bytes.Buffer
+			if isPackageDatabase(name) {
+				buffer = &bytes.Buffer{}
+				reader = io.TeeReader(tarReader, buffer)
+			}
+			inode.hash, err = d.hasher.Hash(reader, uint64(header.Size))
+			if err != nil {
+				return err
+			}
+			if buffer != nil {
+				inode.data = buffer.Bytes()
+			}
+		}
+		parent.children[leafName] = &nodeType{inode: inode, layer: d.layer}
+	case tar.TypeLink:
+		target := d.lookup(normaliseFilename(header.Linkname))
+		if target == nil || target.children != nil {
+			return fmt.Errorf("missing hardlink target: %s", header.Linkname)
+		}
+		parent.children[leafName] = &nodeType{inode: target.inode,
+			layer: d.layer}
+	case tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		parent.children[leafName] = &nodeType{
+			inode: &inodeType{header: header},
+			layer: d.layer,
+		}
+	default:
+		return fmt.Errorf("unsupported file type: %v", header.Typeflag)
+	}
+	return nil
+}
+
+func (d *decoderType) buildFileSystem() (*filesystem.FileSystem, error) {
+	d.fileSystem = &filesystem.FileSystem{
+		InodeTable: make(filesystem.InodeTable),
+	}
+	d.inodeNumbers = make(map[*inodeType]uint64)
+	d.nextInodeNumber = 1
+	d.fileSystem.DirectoryInode = *makeDirectoryInode(d.root.inode.header)
+	d.fileSystem.DirectoryCount = 1
+	err := d.buildDirectory(&d.fileSystem.DirectoryInode, d.root)
+	if err != nil {
+		return nil, err
+	}
+	d.fileSystem.ComputeTotalDataBytes()
+	return d.fileSystem, nil
+}
+
+func (d *decoderType) buildDirectory(directory *filesystem.DirectoryInode,
+	node *nodeType) error {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := node.children[name]
+		dirent := &filesystem.DirectoryEntry{Name: name}
+		directory.EntryList = append(directory.EntryList, dirent)
+		if child.children != nil {
+			inode := makeDirectoryInode(child.inode.header)
+			dirent.InodeNumber = d.addInode(inode)
+			dirent.SetInode(inode)
+			d.fileSystem.DirectoryCount++
+			if err := d.buildDirectory(inode, child); err != nil {
+				return err
+			}
+			continue
+		}
+		if inum, ok := d.inodeNumbers[child.inode]; ok {
+			dirent.InodeNumber = inum
+			dirent.SetInode(d.fileSystem.InodeTable[inum])
+			continue
+		}
+		inode, err := untar.MakeInode(child.inode.header, child.inode.hash)
+		if err != nil {
+			return err
+		}
+		dirent.InodeNumber = d.addInode(inode)
+		dirent.SetInode(inode)
+		d.inodeNumbers[child.inode] = dirent.InodeNumber
+	}
+	return nil
+}
+
+func (d *decoderType) addInode(inode filesystem.GenericInode) uint64 {
+	inum := d.nextInodeNumber
+	d.fileSystem.InodeTable[inum] = inode
+	d.nextInodeNumber++
+	return inum
+}
+
+func makeDirectoryInode(header *tar.Header) *filesystem.DirectoryInode {
+	if header == nil {
+		return &filesystem.DirectoryInode{Mode: syscall.S_IFDIR | dirPerms}
+	}
+	return untar.MakeDirectoryInode(header)
+}
@@ -0,0 +1,236 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+type testHasher struct{}
+
+type testFile struct {
+	name     string
+	typeflag byte
+	data     string
+}
+
+func (testHasher) Hash(reader io.Reader, length uint64) (hash.Hash, error) {
+	hasher := sha512.New()
+	if _, err := io.CopyN(hasher, reader, int64(length)); err != nil {
+		return hash.Hash{}, err
+	}
+	var hashVal hash.Hash
+	copy(hashVal[:], hasher.Sum(nil))
+	return hashVal, nil
+}
+
+func makeLayer(files []testFile) []byte {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.name,
+			Typeflag: file.typeflag,
+			Mode:     0644,
+		}
+		if file.typeflag == tar.TypeReg {
+			header.Size = int64(len(file.data))
+		} else if file.typeflag == tar.TypeDir {
+			header.Mode = 0755
+		} else {
+			header.Linkname = file.data
+		}
+		writer.WriteHeader(header)
+		writer.Write([]byte(file.data))
+	}
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func writeBlob(t *testing.T, dirname string, data []byte) string {
+	digest := fmt.Sprintf("%x", sha256.Sum256(data))
+	filename := filepath.Join(dirname, "blobs", "sha256", digest)
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + digest
+}
+
+func makeLayout(t *testing.T, dirname string, layers ...[]testFile) {
+	err := os.MkdirAll(filepath.Join(dirname, "blobs", "sha256"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"schemaVersion": 2, "layers": [`
+	for index, files := range layers {
+		if index > 0 {
+			manifest += ","
+		}
+		manifest += fmt.Sprintf(
+			`{"mediaType": "application/vnd.oci.image.layer.v1.tar",`+
+				` "digest": "%s"}`,
+			writeBlob(t, dirname, makeLayer(files)))
+	}
+	manifest += "]}"
+	index := fmt.Sprintf(`{"schemaVersion": 2, "manifests": [`+
+		`{"mediaType": "application/vnd.oci.image.manifest.v1+json",`+
+		` "digest": "%s"}]}`, writeBlob(t, dirname, []byte(manifest)))
+	err = ioutil.WriteFile(filepath.Join(dirname, "index.json"),
+		[]byte(index), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeLayers(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	dpkgStatus := "Package: bash\nStatus: install ok installed\n" +
+		"Installed-Size: 2\nVersion: 5.0\n\n" +
+		"Package: removed\nStatus: deinstall ok config-files\n" +
+		"Version: 1.0\n"
+	makeLayout(t, dirname,
+		[]testFile{
+			{"a/", tar.TypeDir, ""},
+			{"a/file1", tar.TypeReg, "one"},
+			{"a/file2", tar.TypeReg, "two"},
+			{"b/x", tar.TypeReg, "x"},
+			{"b/sub/y", tar.TypeReg, "y"},
+		},
+		[]testFile{
+			{"a/.wh.file1", tar.TypeReg, ""},
+			{"a/link", tar.TypeLink, "a/file2"},
+			{"a/file2", tar.TypeReg, "new two"},
+			{"b/.wh..wh..opq", tar.TypeReg, ""},
+			{"b/z", tar.TypeReg, "z"},
+			{"var/lib/dpkg/status", tar.TypeReg, dpkgStatus},
+		})
+	img, err := Decode(dirname, testHasher{}, nil, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := img.FileSystem.FilenameToInodeTable()
+	for _, name := range []string{"/a/file1", "/b/x", "/b/sub"} {
+		if _, ok := names[name]; ok {
+			t.Errorf("%s not removed", name)
+		}
+	}
+	for _, name := range []string{"/a/file2", "/a/link", "/b/z"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("%s missing", name)
+		}
+	}
+	link := img.FileSystem.InodeTable[names["/a/link"]]
+	if inode, ok := link.(*filesystem.RegularInode); !ok {
+		t.Error("/a/link is not a regular file")
+	} else if inode.Size != 3 {
+		t.Errorf("/a/link has size: %d, expected 3", inode.Size)
+	}
+	if names["/a/link"] == names["/a/file2"] {
+		t.Error("/a/link shares inode with replaced /a/file2")
+	}
+	if len(img.Packages) != 1 {
+		t.Fatalf("expected 1 package, got: %d", len(img.Packages))
+	}
+	if pkg := img.Packages[0]; pkg.Name != "bash" || pkg.Version != "5.0" ||
+		pkg.Size != 2048 {
+		t.Errorf("unexpected package: %v", pkg)
+	}
+}
+
+func TestDecodeDockerSave(t *testing.T) {
+	file, err := ioutil.TempFile("", "docker-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	layer1 := makeLayer([]testFile{
+		{"etc/", tar.TypeDir, ""},
+		{"etc/hostname", tar.TypeReg, "host"},
+		{".subd/", tar.TypeDir, ""},
+		{".subd/secret", tar.TypeReg, "secret"},
+	})
+	layer2 := makeLayer([]testFile{
+		{"etc/.wh.hostname", tar.TypeReg, ""},
+		{"etc/motd", tar.TypeReg, "hello"},
+		{"bin/sh", tar.TypeSymlink, "bash"},
+	})
+	// Identical layers are stored once by "docker save", with symlinks from
+	// the other layer directories.
+	manifest := `[{"Config": "config.json", "RepoTags": ["test:latest"],` +
+		` "Layers": ["1111/layer.tar", "3333/layer.tar"]}]`
+	writer := tar.NewWriter(file)
+	for _, entry := range []struct {
+		name     string
+		typeflag byte
+		data     []byte
+	}{
+		{"1111/", tar.TypeDir, nil},
+		{"1111/layer.tar", tar.TypeReg, layer1},
+		{"2222/", tar.TypeDir, nil},
+		{"2222/layer.tar", tar.TypeReg, layer2},
+		{"3333/", tar.TypeDir, nil},
+		{"3333/layer.tar", tar.TypeSymlink, []byte("../2222/layer.tar")},
+		{"config.json", tar.TypeReg, []byte("{}")},
+		{"manifest.json", tar.TypeReg, []byte(manifest)},
+	} {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag,
+			Mode: 0644}
+		if entry.typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.data))
+		} else if entry.typeflag == tar.TypeSymlink {
+			header.Linkname = string(entry.data)
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if entry.typeflag == tar.TypeReg {
+			if _, err := writer.Write(entry.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Decode(file.Name(), testHasher{}, nil, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := img.FileSystem.FilenameToInodeTable()
+	for _, name := range []string{"/etc/hostname", "/.subd", "/.subd/secret"} {
+		if _, ok := names[name]; ok {
+			t.Errorf("%s not removed", name)
+		}
+	}
+	inodeTable := img.FileSystem.InodeTable
+	motd, ok := inodeTable[names["/etc/motd"]].(*filesystem.RegularInode)
+	if !ok {
+		t.Fatal("/etc/motd is not a regular file")
+	}
+	if motd.Size != 5 {
+		t.Errorf("/etc/motd has size: %d, expected 5", motd.Size)
+	}
+	sh, ok := inodeTable[names["/bin/sh"]].(*filesystem.SymlinkInode)
+	if !ok {
+		t.Fatal("/bin/sh is not a symlink")
+	}
+	if sh.Symlink != "bash" {
+		t.Errorf("/bin/sh points to: %s, expected bash", sh.Symlink)
+	}
+}
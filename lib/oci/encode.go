@@ -0,0 +1,367 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	fstar "github.com/Symantec/Dominator/lib/filesystem/tar"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/objectserver"
+)
+
+const (
+	annotationCreated  = "org.opencontainers.image.created"
+	annotationFilter   = "com.github.symantec.dominator.filter"
+	annotationRefName  = "org.opencontainers.image.ref.name"
+	annotationTriggers = "com.github.symantec.dominator.triggers"
+)
+
+type blobType struct {
+	descriptor descriptorType
+	data       []byte // If nil, data are in filename.
+	filename   string
+}
+
+type configType struct {
+	Created      string          `json:"created,omitempty"`
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	Config       imageConfigType `json:"config"`
+	RootFS       rootFsType      `json:"rootfs"`
+}
+
+type imageConfigType struct {
+	Labels map[string]string `json:"Labels,omitempty"`
+}
+
+type rootFsType struct {
+	Type    string   `json:"type"`
+	DiffIds []string `json:"diff_ids"`
+}
+
+type splitterType struct {
+	maxLayerSize uint64
+	currentChunk int
+	currentSize  uint64
+	chunks       map[uint64]int // Key: inode number.
+}
+
+func encode(writer io.Writer, img *image.Image,
+	objectsGetter objectserver.ObjectsGetter, options EncodeOptions) error {
+	tmpDir, err := ioutil.TempDir("", "oci-export")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	var blobs []blobType
+	var layers []descriptorType
+	var diffIds []string
+	for index, fs := range splitFileSystem(img.FileSystem,
+		options.MaxLayerSize) {
+		layer, diffId, err := writeLayer(
+			filepath.Join(tmpDir, strconv.Itoa(index)), fs, objectsGetter)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, layer)
+		layers = append(layers, layer.descriptor)
+		diffIds = append(diffIds, diffId)
+	}
+	annotations, err := makeAnnotations(img)
+	if err != nil {
+		return err
+	}
+	config := configType{
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		Config:       imageConfigType{Labels: annotations},
+		RootFS:       rootFsType{Type: "layers", DiffIds: diffIds},
+	}
+	if !img.CreatedOn.IsZero() {
+		config.Created = img.CreatedOn.UTC().Format(time.RFC3339)
+	}
+	configBlob, err := makeJsonBlob(mediaTypeOciConfig, config)
+	if err != nil {
+		return err
+	}
+	manifestBlob, err := makeJsonBlob(mediaTypeOciManifest, manifestType{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeOciManifest,
+		Config:        configBlob.descriptor,
+		Layers:        layers,
+		Annotations:   annotations,
+	})
+	if err != nil {
+		return err
+	}
+	blobs = append(blobs, configBlob, manifestBlob)
+	manifestDescriptor := manifestBlob.descriptor
+	manifestDescriptor.Platform = &platformType{
+		Architecture: config.Architecture,
+		OS:           config.OS,
+	}
+	dockerManifest := dockerManifestType{
+		Config: mustBlobName(configBlob.descriptor.Digest),
+	}
+	for _, layer := range layers {
+		dockerManifest.Layers = append(dockerManifest.Layers,
+			mustBlobName(layer.Digest))
+	}
+	if options.Tag != "" {
+		manifestDescriptor.Annotations = map[string]string{
+			annotationRefName: options.Tag,
+		}
+		dockerManifest.RepoTags = []string{options.Tag}
+	}
+	return writeLayout(writer, blobs,
+		indexType{SchemaVersion: 2,
+			Manifests: []descriptorType{manifestDescriptor}},
+		[]dockerManifestType{dockerManifest}, img.CreatedOn)
+}
+
+func makeAnnotations(img *image.Image) (map[string]string, error) {
+	annotations := make(map[string]string)
+	if !img.CreatedOn.IsZero() {
+		annotations[annotationCreated] =
+			img.CreatedOn.UTC().Format(time.RFC3339)
+	}
+	if img.Filter != nil {
+		data, err := json.Marshal(img.Filter.FilterLines)
+		if err != nil {
+			return nil, err
+		}
+		annotations[annotationFilter] = string(data)
+	}
+	if img.Triggers != nil {
+		data, err := json.Marshal(img.Triggers.Triggers)
+		if err != nil {
+			return nil, err
+		}
+		annotations[annotationTriggers] = string(data)
+	}
+	return annotations, nil
+}
+
+func makeJsonBlob(mediaType string, value interface{}) (blobType, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return blobType{}, err
+	}
+	return blobType{
+		descriptor: descriptorType{
+			MediaType: mediaType,
+			Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+			Size:      int64(len(data)),
+		},
+		data: data,
+	}, nil
+}
+
+func mustBlobName(digest string) string {
+	name, err := blobName(digest)
+	if err != nil {
+		panic(err)
+	}
+	return name
+}
+
+// writeLayer will write a gzip compressed layer to filename. The blob and the
+// digest of the uncompressed layer are returned.
+func writeLayer(filename string, fs *filesystem.FileSystem,
+	objectsGetter objectserver.ObjectsGetter) (blobType, string, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return blobType{}, "", err
+	}
+	defer file.Close()
+	compressedHasher := sha256.New()
+	bufferedWriter := bufio.NewWriter(io.MultiWriter(file, compressedHasher))
+	gzipWriter := gzip.NewWriter(bufferedWriter)
+	uncompressedHasher := sha256.New()
+	err = fstar.Write(io.MultiWriter(gzipWriter, uncompressedHasher), fs,
+		objectsGetter)
+	if err != nil {
+		return blobType{}, "", err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return blobType{}, "", err
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		return blobType{}, "", err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return blobType{}, "", err
+	}
+	return blobType{
+			descriptor: descriptorType{
+				MediaType: mediaTypeOciLayerGzip,
+				Digest: fmt.Sprintf("sha256:%x",
+					compressedHasher.Sum(nil)),
+				Size: fi.Size(),
+			},
+			filename: filename,
+		},
+		fmt.Sprintf("sha256:%x", uncompressedHasher.Sum(nil)), nil
+}
+
+func writeLayout(writer io.Writer, blobs []blobType, index indexType,
+	dockerManifests []dockerManifestType, modTime time.Time) error {
+	tarWriter := tar.NewWriter(writer)
+	writeFile := func(name string, size int64, reader io.Reader) error {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := io.CopyN(tarWriter, reader, size)
+		return err
+	}
+	writeJson := func(name string, value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return writeFile(name, int64(len(data)), bytes.NewReader(data))
+	}
+	err := writeJson("oci-layout",
+		map[string]string{"imageLayoutVersion": "1.0.0"})
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		name := mustBlobName(blob.descriptor.Digest)
+		if blob.data != nil {
+			err = writeFile(name, blob.descriptor.Size,
+				bytes.NewReader(blob.data))
+		} else {
+			err = writeBlobFile(writeFile, name, blob)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeJson("index.json", index); err != nil {
+		return err
+	}
+	if err := writeJson("manifest.json", dockerManifests); err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
+
+func writeBlobFile(writeFile func(string, int64, io.Reader) error,
+	name string, blob blobType) error {
+	file, err := os.Open(blob.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeFile(name, blob.descriptor.Size, bufio.NewReader(file))
+}
+
+// splitFileSystem will split fs into multiple file-systems (layers), each
+// containing approximately maxLayerSize bytes of file data. All names for an
+// inode are placed in the same layer. If maxLayerSize is zero, fs is returned.
+func splitFileSystem(fs *filesystem.FileSystem,
+	maxLayerSize uint64) []*filesystem.FileSystem {
+	if maxLayerSize < 1 {
+		return []*filesystem.FileSystem{fs}
+	}
+	splitter := &splitterType{
+		maxLayerSize: maxLayerSize,
+		chunks:       make(map[uint64]int),
+	}
+	splitter.assign(&fs.DirectoryInode)
+	fileSystems := make([]*filesystem.FileSystem, splitter.currentChunk+1)
+	for chunk := range fileSystems {
+		fileSystems[chunk] = splitter.makeFileSystem(fs, chunk)
+	}
+	return fileSystems
+}
+
+func (s *splitterType) assign(directory *filesystem.DirectoryInode) {
+	for _, dirent := range directory.EntryList {
+		if _, ok := s.chunks[dirent.InodeNumber]; ok {
+			continue // Hardlink to an inode which was already assigned.
+		}
+		if s.currentSize >= s.maxLayerSize {
+			s.currentChunk++
+			s.currentSize = 0
+		}
+		s.chunks[dirent.InodeNumber] = s.currentChunk
+		switch inode := dirent.Inode().(type) {
+		case *filesystem.DirectoryInode:
+			s.assign(inode)
+		case *filesystem.RegularInode:
+			s.currentSize += inode.Size
+		}
+	}
+}
+
+func (s *splitterType) makeFileSystem(fs *filesystem.FileSystem,
+	chunk int) *filesystem.FileSystem {
+	newFs := &filesystem.FileSystem{
+		InodeTable: make(filesystem.InodeTable),
+		DirectoryInode: filesystem.DirectoryInode{
+			Mode: fs.DirectoryInode.Mode,
+			Uid:  fs.DirectoryInode.Uid,
+			Gid:  fs.DirectoryInode.Gid,
+		},
+	}
+	s.copyDirectory(newFs, &newFs.DirectoryInode, &fs.DirectoryInode, chunk)
+	newFs.ComputeTotalDataBytes()
+	return newFs
+}
+
+// copyDirectory will copy the entries in source which are in chunk (and their
+// parent directories) to dest. It returns true if any entries were copied.
+func (s *splitterType) copyDirectory(fs *filesystem.FileSystem,
+	dest, source *filesystem.DirectoryInode, chunk int) bool {
+	for _, dirent := range source.EntryList {
+		var inode filesystem.GenericInode
+		if dirInode, ok := dirent.Inode().(*filesystem.DirectoryInode); ok {
+			newDirInode := &filesystem.DirectoryInode{
+				Mode: dirInode.Mode,
+				Uid:  dirInode.Uid,
+				Gid:  dirInode.Gid,
+			}
+			if !s.copyDirectory(fs, newDirInode, dirInode, chunk) &&
+				s.chunks[dirent.InodeNumber] != chunk {
+				continue
+			}
+			inode = newDirInode
+			fs.DirectoryCount++
+		} else if s.chunks[dirent.InodeNumber] == chunk {
+			inode = dirent.Inode()
+		} else {
+			continue
+		}
+		newDirent := &filesystem.DirectoryEntry{
+			Name:        dirent.Name,
+			InodeNumber: dirent.InodeNumber,
+		}
+		newDirent.SetInode(inode)
+		dest.EntryList = append(dest.EntryList, newDirent)
+		fs.InodeTable[dirent.InodeNumber] = inode
+	}
+	return len(dest.EntryList) > 0
+}
@@ -0,0 +1,85 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/objectserver/memory"
+)
+
+type objectAdder struct {
+	objectServer *memory.ObjectServer
+}
+
+func (h *objectAdder) Hash(reader io.Reader, length uint64) (hash.Hash, error) {
+	hashVal, _, err := h.objectServer.AddObject(reader, length, nil)
+	return hashVal, err
+}
+
+func TestEncodeDecode(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	makeLayout(t, filepath.Join(dirname, "in"),
+		[]testFile{
+			{"a/file1", tar.TypeReg, "one"},
+			{"a/link", tar.TypeLink, "a/file1"},
+			{"b/file2", tar.TypeReg, "two"},
+			{"b/symlink", tar.TypeSymlink, "../a/file1"},
+			{"c/file3", tar.TypeReg, "three"},
+		})
+	logger := testlogger.New(t)
+	adder := &objectAdder{memory.NewObjectServer()}
+	img, err := Decode(filepath.Join(dirname, "in"), adder, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	err = Encode(buffer, img, adder.objectServer,
+		EncodeOptions{MaxLayerSize: 3, Tag: "test:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(dirname, "out.tar")
+	if err := ioutil.WriteFile(tarball, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opener, err := newBlobOpener(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := getLayers(opener)
+	opener.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 3 {
+		t.Errorf("expected 3 layers, got: %d", len(layers))
+	}
+	newImg, err := Decode(tarball, testHasher{}, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldNames := img.FileSystem.FilenameToInodeTable()
+	newNames := newImg.FileSystem.FilenameToInodeTable()
+	if len(oldNames) != len(newNames) {
+		t.Errorf("expected %d names, got: %d", len(oldNames), len(newNames))
+	}
+	for name := range oldNames {
+		if _, ok := newNames[name]; !ok {
+			t.Errorf("%s missing", name)
+		}
+	}
+	if newNames["/a/file1"] != newNames["/a/link"] {
+		t.Error("hardlink not preserved")
+	}
+}
@@ -0,0 +1,121 @@
+package oci
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/dpkg"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+var rpmDatabaseDirectories = []string{
+	"/usr/lib/sysimage/rpm",
+	"/var/lib/rpm",
+}
+
+func isPackageDatabase(name string) bool {
+	if name == dpkg.StatusFile {
+		return true
+	}
+	dirname := path.Dir(name)
+	for _, rpmDirectory := range rpmDatabaseDirectories {
+		if dirname == rpmDirectory {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *decoderType) listPackages(logger log.Logger) (
+	[]image.Package, error) {
+	if node := d.lookup(dpkg.StatusFile); node != nil && node.children == nil {
+		return parseDpkgStatus(node.inode.data)
+	}
+	for _, dirname := range rpmDatabaseDirectories {
+		if node := d.lookup(dirname); node != nil && len(node.children) > 0 {
+			return listRpmPackages(node, logger)
+		}
+	}
+	return nil, nil
+}
+
+func parseDpkgStatus(data []byte) ([]image.Package, error) {
+	dpkgPackages, err := dpkg.ParseStatus(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]image.Package, 0, len(dpkgPackages))
+	for _, pkg := range dpkgPackages {
+		packages = append(packages, image.Package{
+			Name:    pkg.Name,
+			Size:    pkg.Size,
+			Version: pkg.Version,
+		})
+	}
+	sortPackages(packages)
+	return packages, nil
+}
+
+// listRpmPackages will copy the RPM database to a temporary directory and use
+// the rpm(8) utility to list the installed packages.
+func listRpmPackages(node *nodeType, logger log.Logger) (
+	[]image.Package, error) {
+	rpmPath, err := exec.LookPath("rpm")
+	if err != nil {
+		logger.Println("rpm not available, not listing packages")
+		return nil, nil
+	}
+	dbDir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dbDir)
+	for name, child := range node.children {
+		if child.children != nil || child.inode.header == nil {
+			continue
+		}
+		err := ioutil.WriteFile(filepath.Join(dbDir, name), child.inode.data,
+			0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+	output, err := exec.Command(rpmPath, "--dbpath", dbDir, "-qa",
+		"--queryformat", "%{NAME} %{VERSION}-%{RELEASE} %{SIZE}\n").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running rpm: %s", err)
+	}
+	var packages []image.Package
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] == "gpg-pubkey" {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad size for: %s: %s", fields[0], err)
+		}
+		packages = append(packages, image.Package{
+			Name:    fields[0],
+			Size:    size,
+			Version: fields[1],
+		})
+	}
+	sortPackages(packages)
+	return packages, nil
+}
+
+func sortPackages(packages []image.Package) {
+	sort.Slice(packages, func(left, right int) bool {
+		return packages[left].Name < packages[right].Name
+	})
+}
@@ -0,0 +1,268 @@
+package oci
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOciConfig          = "application/vnd.oci.image.config.v1+json"
+	mediaTypeOciIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOciLayerGzip       = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeOciManifest        = "application/vnd.oci.image.manifest.v1+json"
+)
+
+type blobOpener interface {
+	Close() error
+	Open(name string) (io.ReadCloser, error)
+}
+
+type directoryOpener string
+
+type tarballEntry struct {
+	offset   int64
+	size     int64
+	linkname string
+}
+
+type tarballOpener struct {
+	file    *os.File
+	entries map[string]tarballEntry
+}
+
+type descriptorType struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *platformType     `json:"platform,omitempty"`
+}
+
+type dockerManifestType struct {
+	Config   string
+	RepoTags []string `json:",omitempty"`
+	Layers   []string
+}
+
+type indexType struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Manifests     []descriptorType `json:"manifests"`
+}
+
+type layerType struct {
+	digest string // May be empty.
+	name   string
+}
+
+type manifestType struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        descriptorType    `json:"config"`
+	Layers        []descriptorType  `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type platformType struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+func newBlobOpener(source string) (blobOpener, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return directoryOpener(source), nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	opener := &tarballOpener{
+		file:    file,
+		entries: make(map[string]tarballEntry),
+	}
+	if err := opener.index(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error indexing: %s: %s", source, err)
+	}
+	return opener, nil
+}
+
+func (dirname directoryOpener) Close() error {
+	return nil
+}
+
+func (dirname directoryOpener) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(dirname), filepath.FromSlash(name)))
+}
+
+// index will record the location of each file in the tarball so that blobs
+// may be read in any order.
+func (opener *tarballOpener) index() error {
+	tarReader := tar.NewReader(opener.file)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		name := path.Clean(header.Name)
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			// The tar reader does not read ahead, so the file offset is the
+			// start of the data for this entry.
+			offset, err := opener.file.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			opener.entries[name] = tarballEntry{offset: offset,
+				size: header.Size}
+		case tar.TypeSymlink:
+			opener.entries[name] = tarballEntry{
+				linkname: path.Join(path.Dir(name), header.Linkname)}
+		}
+	}
+}
+
+func (opener *tarballOpener) Close() error {
+	return opener.file.Close()
+}
+
+func (opener *tarballOpener) Open(name string) (io.ReadCloser, error) {
+	name = path.Clean(name)
+	for count := 0; count < 16; count++ {
+		entry, ok := opener.entries[name]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: name,
+				Err: os.ErrNotExist}
+		}
+		if entry.linkname == "" {
+			return ioutil.NopCloser(
+					io.NewSectionReader(opener.file, entry.offset, entry.size)),
+				nil
+		}
+		name = entry.linkname
+	}
+	return nil, fmt.Errorf("too many levels of symbolic links: %s", name)
+}
+
+func blobName(digest string) (string, error) {
+	split := strings.SplitN(digest, ":", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" ||
+		strings.ContainsAny(digest, "/") {
+		return "", fmt.Errorf("malformed digest: %s", digest)
+	}
+	return path.Join("blobs", split[0], split[1]), nil
+}
+
+func readJson(opener blobOpener, name string, value interface{}) error {
+	reader, err := opener.Open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(value); err != nil {
+		return fmt.Errorf("error decoding: %s: %s", name, err)
+	}
+	return nil
+}
+
+// getLayers will return the list of layers, lowest first.
+func getLayers(opener blobOpener) ([]layerType, error) {
+	var index indexType
+	if err := readJson(opener, "index.json", &index); err == nil {
+		return getOciLayers(opener, index)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	var dockerManifests []dockerManifestType
+	err := readJson(opener, "manifest.json", &dockerManifests)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New(
+				"not an OCI image layout or docker save tarball")
+		}
+		return nil, err
+	}
+	if len(dockerManifests) != 1 {
+		return nil, fmt.Errorf("%d images in manifest, require 1",
+			len(dockerManifests))
+	}
+	layers := make([]layerType, 0, len(dockerManifests[0].Layers))
+	for _, name := range dockerManifests[0].Layers {
+		layers = append(layers, layerType{name: name})
+	}
+	return layers, nil
+}
+
+func getOciLayers(opener blobOpener, index indexType) ([]layerType, error) {
+	for depth := 0; depth < 4; depth++ {
+		descriptor, err := selectManifest(index.Manifests)
+		if err != nil {
+			return nil, err
+		}
+		name, err := blobName(descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if descriptor.MediaType == mediaTypeOciIndex ||
+			descriptor.MediaType == mediaTypeDockerManifestList {
+			index = indexType{}
+			if err := readJson(opener, name, &index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var manifest manifestType
+		if err := readJson(opener, name, &manifest); err != nil {
+			return nil, err
+		}
+		layers := make([]layerType, 0, len(manifest.Layers))
+		for _, layer := range manifest.Layers {
+			if strings.HasSuffix(layer.MediaType, "+zstd") {
+				return nil, fmt.Errorf("unsupported layer media type: %s",
+					layer.MediaType)
+			}
+			name, err := blobName(layer.Digest)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, layerType{digest: layer.Digest, name: name})
+		}
+		return layers, nil
+	}
+	return nil, errors.New("too many levels of image indices")
+}
+
+// selectManifest will select the only manifest, or else the manifest for the
+// current architecture.
+func selectManifest(manifests []descriptorType) (descriptorType, error) {
+	if len(manifests) < 1 {
+		return descriptorType{}, errors.New("no manifests in index")
+	}
+	if len(manifests) == 1 {
+		return manifests[0], nil
+	}
+	for _, manifest := range manifests {
+		if manifest.Platform != nil && manifest.Platform.OS == "linux" &&
+			manifest.Platform.Architecture == runtime.GOARCH {
+			return manifest, nil
+		}
+	}
+	return descriptorType{}, fmt.Errorf("no manifest for linux/%s",
+		runtime.GOARCH)
+}
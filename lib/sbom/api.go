@@ -0,0 +1,84 @@
+package sbom
+
+import (
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+)
+
+const (
+	PackageTypeDeb = "deb"
+	PackageTypeRpm = "rpm"
+)
+
+// Document is a software bill of materials for an image. It is stored (JSON
+// encoded) as the image Sbom annotation and may be converted to standard
+// formats.
+type Document struct {
+	Name         string
+	Created      time.Time
+	Distribution string `json:",omitempty"` // ID from /etc/os-release.
+	Release      string `json:",omitempty"` // VERSION_ID from /etc/os-release.
+	PackageType  string // One of the PackageType* constants.
+	Packages     []Package
+}
+
+type File struct {
+	Name   string
+	Sha512 string `json:",omitempty"` // Hex encoded.
+}
+
+type Package struct {
+	Name         string
+	Version      string
+	Architecture string `json:",omitempty"`
+	Source       string `json:",omitempty"` // Source package name.
+	License      string `json:",omitempty"`
+	Size         uint64 `json:",omitempty"` // Bytes.
+	Files        []File `json:",omitempty"` // Regular files only.
+}
+
+type PackageChange struct {
+	Name       string // Includes the architecture if present.
+	OldVersion string // Empty if the package was added.
+	NewVersion string // Empty if the package was removed.
+}
+
+// Decode will read a JSON encoded Document.
+func Decode(reader io.Reader) (*Document, error) {
+	return decode(reader)
+}
+
+// Diff will return the list of package changes between left and right,
+// sorted by name.
+func Diff(left, right *Document) []PackageChange {
+	return diff(left, right)
+}
+
+// Generate will generate a Document by reading the dpkg or RPM package database
+// in the file-system tree rooted at rootDir. If there is no supported package
+// database, nil is returned.
+func Generate(name, rootDir string) (*Document, error) {
+	return generate(name, rootDir)
+}
+
+// AddFileHashes will fill in the file hashes using the data in fs.
+func (doc *Document) AddFileHashes(fs *filesystem.FileSystem) {
+	doc.addFileHashes(fs)
+}
+
+// Encode will write the document in JSON format.
+func (doc *Document) Encode(writer io.Writer) error {
+	return doc.encode(writer)
+}
+
+// WriteCycloneDx will write the document in CycloneDX JSON format.
+func (doc *Document) WriteCycloneDx(writer io.Writer) error {
+	return doc.writeCycloneDx(writer)
+}
+
+// WriteSpdx will write the document in SPDX JSON format.
+func (doc *Document) WriteSpdx(writer io.Writer) error {
+	return doc.writeSpdx(writer)
+}
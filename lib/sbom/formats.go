@@ -0,0 +1,288 @@
+package sbom
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+
+	libjson "github.com/Symantec/Dominator/lib/json"
+)
+
+const noAssertion = "NOASSERTION"
+
+type cycloneDxComponent struct {
+	Type       string               `json:"type"`
+	BomRef     string               `json:"bom-ref,omitempty"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version,omitempty"`
+	Purl       string               `json:"purl,omitempty"`
+	Licenses   []cycloneDxLicense   `json:"licenses,omitempty"`
+	Hashes     []cycloneDxHash      `json:"hashes,omitempty"`
+	Properties []cycloneDxProperty  `json:"properties,omitempty"`
+	Components []cycloneDxComponent `json:"components,omitempty"`
+}
+
+type cycloneDxDocument struct {
+	BomFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDxMetadata    `json:"metadata"`
+	Components  []cycloneDxComponent `json:"components"`
+}
+
+type cycloneDxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDxLicense struct {
+	License cycloneDxLicenseName `json:"license"`
+}
+
+type cycloneDxLicenseName struct {
+	Name string `json:"name"`
+}
+
+type cycloneDxMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Component cycloneDxComponent `json:"component"`
+}
+
+type cycloneDxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxDocument struct {
+	SpdxVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SpdxId            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxFile struct {
+	FileName         string         `json:"fileName"`
+	SpdxId           string         `json:"SPDXID"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SpdxId           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	LicenseComments  string            `json:"licenseComments,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxRelationship struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+// purl will return the Package URL for pkg.
+func (doc *Document) purl(pkg Package) string {
+	packageType := doc.PackageType
+	if packageType == "" {
+		packageType = "generic"
+	}
+	purl := "pkg:" + packageType + "/"
+	if doc.Distribution != "" {
+		purl += url.QueryEscape(doc.Distribution) + "/"
+	}
+	purl += url.QueryEscape(pkg.Name) + "@" + url.QueryEscape(pkg.Version)
+	if pkg.Architecture != "" {
+		purl += "?arch=" + url.QueryEscape(pkg.Architecture)
+	}
+	return purl
+}
+
+func (doc *Document) timestamp() string {
+	return doc.Created.UTC().Format(time.RFC3339)
+}
+
+func (doc *Document) writeCycloneDx(writer io.Writer) error {
+	output := cycloneDxDocument{
+		BomFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cycloneDxMetadata{
+			Timestamp: doc.timestamp(),
+			Component: cycloneDxComponent{
+				Type: "operating-system",
+				Name: doc.Name,
+			},
+		},
+		Components: make([]cycloneDxComponent, 0, len(doc.Packages)),
+	}
+	for _, pkg := range doc.Packages {
+		purl := doc.purl(pkg)
+		component := cycloneDxComponent{
+			Type:    "library",
+			BomRef:  purl,
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Purl:    purl,
+		}
+		if pkg.License != "" {
+			component.Licenses = []cycloneDxLicense{
+				{cycloneDxLicenseName{pkg.License}}}
+		}
+		if pkg.Source != "" {
+			component.Properties = append(component.Properties,
+				cycloneDxProperty{"dominator:source", pkg.Source})
+		}
+		for _, file := range pkg.Files {
+			fileComponent := cycloneDxComponent{Type: "file", Name: file.Name}
+			if file.Sha512 != "" {
+				fileComponent.Hashes = []cycloneDxHash{
+					{"SHA-512", file.Sha512}}
+			}
+			component.Components = append(component.Components, fileComponent)
+		}
+		output.Components = append(output.Components, component)
+	}
+	return libjson.WriteWithIndent(writer, "  ", output)
+}
+
+func (doc *Document) writeSpdx(writer io.Writer) error {
+	output := spdxDocument{
+		SpdxVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SpdxId:      "SPDXRef-DOCUMENT",
+		Name:        doc.Name,
+		DocumentNamespace: fmt.Sprintf("urn:dominator:sbom:%s:%d",
+			url.QueryEscape(doc.Name), doc.Created.Unix()),
+		CreationInfo: spdxCreationInfo{
+			Created:  doc.timestamp(),
+			Creators: []string{"Tool: Dominator"},
+		},
+		Packages: []spdxPackage{{
+			Name:             doc.Name,
+			SpdxId:           "SPDXRef-Image",
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+		}},
+		Relationships: []spdxRelationship{
+			{"SPDXRef-DOCUMENT", "DESCRIBES", "SPDXRef-Image"},
+		},
+	}
+	numFiles := 0
+	for index, pkg := range doc.Packages {
+		packageId := fmt.Sprintf("SPDXRef-Package-%d", index)
+		spdxPkg := spdxPackage{
+			Name:             pkg.Name,
+			SpdxId:           packageId,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  doc.purl(pkg),
+			}},
+		}
+		if pkg.License != "" {
+			// Package databases do not reliably use SPDX license expressions.
+			spdxPkg.LicenseComments = "Declared license: " + pkg.License
+		}
+		if pkg.Source != "" {
+			spdxPkg.SourceInfo = "built from source package: " + pkg.Source
+		}
+		output.Packages = append(output.Packages, spdxPkg)
+		output.Relationships = append(output.Relationships,
+			spdxRelationship{"SPDXRef-Image", "CONTAINS", packageId})
+		for _, file := range pkg.Files {
+			fileId := fmt.Sprintf("SPDXRef-File-%d", numFiles)
+			numFiles++
+			spdxFile := spdxFile{
+				FileName:         "." + file.Name,
+				SpdxId:           fileId,
+				LicenseConcluded: noAssertion,
+				CopyrightText:    noAssertion,
+			}
+			if file.Sha512 != "" {
+				spdxFile.Checksums = []spdxChecksum{{"SHA512", file.Sha512}}
+			}
+			output.Files = append(output.Files, spdxFile)
+			output.Relationships = append(output.Relationships,
+				spdxRelationship{packageId, "CONTAINS", fileId})
+		}
+	}
+	return libjson.WriteWithIndent(writer, "  ", output)
+}
+
+func (change PackageChange) String() string {
+	if change.OldVersion == "" {
+		return fmt.Sprintf("+ %s %s", change.Name, change.NewVersion)
+	}
+	if change.NewVersion == "" {
+		return fmt.Sprintf("- %s %s", change.Name, change.OldVersion)
+	}
+	return fmt.Sprintf("~ %s %s -> %s", change.Name, change.OldVersion,
+		change.NewVersion)
+}
+
+func diff(left, right *Document) []PackageChange {
+	leftMap := make(map[string]string, len(left.Packages))
+	for _, pkg := range left.Packages {
+		leftMap[packageKey(pkg)] = pkg.Version
+	}
+	rightMap := make(map[string]string, len(right.Packages))
+	for _, pkg := range right.Packages {
+		rightMap[packageKey(pkg)] = pkg.Version
+	}
+	var changes []PackageChange
+	for name, oldVersion := range leftMap {
+		if newVersion, ok := rightMap[name]; !ok {
+			changes = append(changes, PackageChange{name, oldVersion, ""})
+		} else if newVersion != oldVersion {
+			changes = append(changes,
+				PackageChange{name, oldVersion, newVersion})
+		}
+	}
+	for name, newVersion := range rightMap {
+		if _, ok := leftMap[name]; !ok {
+			changes = append(changes, PackageChange{name, "", newVersion})
+		}
+	}
+	sort.Slice(changes, func(left, right int) bool {
+		return changes[left].Name < changes[right].Name
+	})
+	return changes
+}
@@ -0,0 +1,284 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/dpkg"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	libjson "github.com/Symantec/Dominator/lib/json"
+)
+
+const (
+	dpkgInfoDir   = "/var/lib/dpkg/info"
+	osReleaseFile = "/etc/os-release"
+)
+
+var rpmPaths = []string{"/usr/bin/rpm", "/bin/rpm"}
+
+func decode(reader io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (doc *Document) encode(writer io.Writer) error {
+	return libjson.WriteWithIndent(writer, "    ", doc)
+}
+
+func generate(name, rootDir string) (*Document, error) {
+	doc := &Document{Name: name, Created: time.Now()}
+	doc.Distribution, doc.Release = readDistribution(rootDir)
+	var err error
+	if _, e := os.Stat(filepath.Join(rootDir, dpkg.StatusFile)); e == nil {
+		doc.PackageType = PackageTypeDeb
+		doc.Packages, err = listDpkgPackages(rootDir)
+	} else if rpmPath := findRpm(rootDir); rpmPath != "" {
+		doc.PackageType = PackageTypeRpm
+		doc.Packages, err = listRpmPackages(rootDir, rpmPath)
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(doc.Packages, func(left, right int) bool {
+		return packageKey(doc.Packages[left]) < packageKey(doc.Packages[right])
+	})
+	return doc, nil
+}
+
+func findRpm(rootDir string) string {
+	for _, rpmPath := range rpmPaths {
+		if _, err := os.Stat(filepath.Join(rootDir, rpmPath)); err == nil {
+			return rpmPath
+		}
+	}
+	return ""
+}
+
+func isRegularFile(rootDir, filename string) bool {
+	fi, err := os.Lstat(filepath.Join(rootDir, filename))
+	if err != nil {
+		return false
+	}
+	return fi.Mode().IsRegular()
+}
+
+func packageKey(pkg Package) string {
+	if pkg.Architecture == "" {
+		return pkg.Name
+	}
+	return pkg.Name + ":" + pkg.Architecture
+}
+
+// readDistribution will return the ID and VERSION_ID fields from
+// /etc/os-release.
+func readDistribution(rootDir string) (string, string) {
+	file, err := os.Open(filepath.Join(rootDir, osReleaseFile))
+	if err != nil {
+		return "", ""
+	}
+	defer file.Close()
+	var distribution, release string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "ID=") {
+			distribution = strings.Trim(line[3:], `"'`)
+		} else if strings.HasPrefix(line, "VERSION_ID=") {
+			release = strings.Trim(line[11:], `"'`)
+		}
+	}
+	return distribution, release
+}
+
+func listDpkgPackages(rootDir string) ([]Package, error) {
+	file, err := os.Open(filepath.Join(rootDir, dpkg.StatusFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dpkgPackages, err := dpkg.ParseStatus(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]Package, 0, len(dpkgPackages))
+	for _, dpkgPackage := range dpkgPackages {
+		pkg := Package{
+			Architecture: dpkgPackage.Architecture,
+			Name:         dpkgPackage.Name,
+			Size:         dpkgPackage.Size,
+			Source:       dpkgPackage.Source,
+			Version:      dpkgPackage.Version,
+		}
+		pkg.Files = readDpkgFileList(rootDir, pkg)
+		pkg.License = readDebianCopyright(rootDir, pkg.Name)
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+func readDpkgFileList(rootDir string, pkg Package) []File {
+	file, err := os.Open(filepath.Join(rootDir, dpkgInfoDir,
+		pkg.Name+":"+pkg.Architecture+".list"))
+	if err != nil {
+		file, err = os.Open(filepath.Join(rootDir, dpkgInfoDir,
+			pkg.Name+".list"))
+		if err != nil {
+			return nil
+		}
+	}
+	defer file.Close()
+	var files []File
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if filename := scanner.Text(); isRegularFile(rootDir, filename) {
+			files = append(files, File{Name: filename})
+		}
+	}
+	return files
+}
+
+// readDebianCopyright will extract the licences from a machine-readable
+// copyright file.
+func readDebianCopyright(rootDir, packageName string) string {
+	file, err := os.Open(filepath.Join(rootDir, "usr", "share", "doc",
+		packageName, "copyright"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	licenseMap := make(map[string]struct{})
+	var licenses []string
+	scanner := bufio.NewScanner(file)
+	firstLine := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if firstLine {
+			if !strings.HasPrefix(line, "Format:") {
+				return ""
+			}
+			firstLine = false
+			continue
+		}
+		if !strings.HasPrefix(line, "License:") {
+			continue
+		}
+		license := strings.TrimSpace(line[8:])
+		if license == "" {
+			continue
+		}
+		if _, ok := licenseMap[license]; !ok {
+			licenseMap[license] = struct{}{}
+			licenses = append(licenses, license)
+		}
+	}
+	return strings.Join(licenses, " AND ")
+}
+
+func runRpm(rootDir, rpmPath string, args ...string) ([]byte, error) {
+	cmd := exec.Command(rpmPath, args...)
+	cmd.Dir = "/"
+	cmd.Env = []string{"PATH=/usr/bin:/bin", "LANG=C"}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Chroot: rootDir}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running rpm: %s", err)
+	}
+	return output, nil
+}
+
+func listRpmPackages(rootDir, rpmPath string) ([]Package, error) {
+	output, err := runRpm(rootDir, rpmPath, "-qa", "--queryformat",
+		"%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\t%{SOURCERPM}\t"+
+			"%{LICENSE}\t%{SIZE}\n")
+	if err != nil {
+		return nil, err
+	}
+	packageMap := make(map[string]*Package)
+	var packages []Package
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 || fields[0] == "gpg-pubkey" {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad size for: %s: %s", fields[0], err)
+		}
+		packages = append(packages, Package{
+			Name:         fields[0],
+			Version:      fields[1],
+			Architecture: fields[2],
+			Source:       trimSourceRpm(fields[3]),
+			License:      fields[4],
+			Size:         size,
+		})
+	}
+	for index := range packages {
+		pkg := &packages[index]
+		packageMap[packageKey(*pkg)] = pkg
+	}
+	output, err = runRpm(rootDir, rpmPath, "-qa", "--queryformat",
+		"[%{NAME}\t%{ARCH}\t%{FILENAMES}\n]")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		fields := strings.Split(string(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		pkg := packageMap[fields[0]+":"+fields[1]]
+		if pkg != nil && isRegularFile(rootDir, fields[2]) {
+			pkg.Files = append(pkg.Files, File{Name: fields[2]})
+		}
+	}
+	return packages, nil
+}
+
+// trimSourceRpm will convert a source RPM filename such as
+// "bash-4.2.46-34.el7.src.rpm" to the source package name.
+func trimSourceRpm(sourceRpm string) string {
+	if sourceRpm == "(none)" {
+		return ""
+	}
+	name := strings.TrimSuffix(sourceRpm, ".src.rpm")
+	for count := 0; count < 2; count++ {
+		if index := strings.LastIndex(name, "-"); index > 0 {
+			name = name[:index]
+		}
+	}
+	return name
+}
+
+func (doc *Document) addFileHashes(fs *filesystem.FileSystem) {
+	filenameToInode := fs.FilenameToInodeTable()
+	for pkgIndex := range doc.Packages {
+		files := doc.Packages[pkgIndex].Files
+		for index := range files {
+			inum, ok := filenameToInode[files[index].Name]
+			if !ok {
+				continue
+			}
+			inode, ok := fs.InodeTable[inum].(*filesystem.RegularInode)
+			if ok && inode.Size > 0 {
+				files[index].Sha512 = fmt.Sprintf("%x", inode.Hash)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sbom
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/dpkg"
+)
+
+const testDpkgStatus = `Package: bash
+Status: install ok installed
+Installed-Size: 2
+Architecture: amd64
+Version: 5.0-4
+
+Package: removed
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 1.0
+
+Package: libc6
+Status: install ok installed
+Architecture: amd64
+Source: glibc (2.28-10)
+Version: 2.28-10
+`
+
+func writeTestFile(t *testing.T, rootDir, filename, data string) {
+	pathname := filepath.Join(rootDir, filename)
+	if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pathname, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateDpkg(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "sbom-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootDir)
+	writeTestFile(t, rootDir, dpkg.StatusFile, testDpkgStatus)
+	writeTestFile(t, rootDir, osReleaseFile, "NAME=\"Debian\"\nID=debian\n")
+	writeTestFile(t, rootDir, "/bin/bash", "#!")
+	writeTestFile(t, rootDir, filepath.Join(dpkgInfoDir, "bash.list"),
+		"/.\n/bin\n/bin/bash\n/missing\n")
+	writeTestFile(t, rootDir, "/usr/share/doc/bash/copyright",
+		"Format: https://example.com\n\nLicense: GPL-3+\nLicense: GPL-3+\n")
+	doc, err := Generate("test", rootDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Distribution != "debian" || doc.PackageType != PackageTypeDeb {
+		t.Fatalf("bad document: %s %s", doc.Distribution, doc.PackageType)
+	}
+	if len(doc.Packages) != 2 {
+		t.Fatalf("expected 2 packages, got: %d", len(doc.Packages))
+	}
+	bash := doc.Packages[0]
+	if bash.Name != "bash" || bash.Size != 2048 || bash.License != "GPL-3+" {
+		t.Errorf("bad package: %v", bash)
+	}
+	if len(bash.Files) != 1 || bash.Files[0].Name != "/bin/bash" {
+		t.Errorf("bad file list: %v", bash.Files)
+	}
+	if source := doc.Packages[1].Source; source != "glibc" {
+		t.Errorf("bad source: %s", source)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	left := &Document{Packages: []Package{
+		{Name: "a", Version: "1"},
+		{Name: "b", Version: "1"},
+		{Name: "c", Version: "1"},
+	}}
+	right := &Document{Packages: []Package{
+		{Name: "b", Version: "2"},
+		{Name: "c", Version: "1"},
+		{Name: "d", Version: "1"},
+	}}
+	changes := Diff(left, right)
+	expected := []string{"- a 1", "~ b 1 -> 2", "+ d 1"}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got: %v", len(expected), changes)
+	}
+	for index, change := range changes {
+		if change.String() != expected[index] {
+			t.Errorf("expected: %s, got: %s", expected[index], change)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+	Package vulnerability matches package lists against an offline
+	vulnerability feed.
+
+	Feeds are loaded from a directory tree containing OSV JSON files (with a
+	.json suffix) and OVAL XML files (with a .xml suffix), such as those
+	published by Debian and Red Hat. Version comparison follows the rules of
+	the package manager for the distribution.
+*/
+package vulnerability
+
+import (
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+const (
+	SchemeDeb = "deb"
+	SchemeRpm = "rpm"
+)
+
+type Advisory struct {
+	Id           string
+	Aliases      []string
+	Summary      string
+	Severity     string
+	Distribution string // ID from /etc/os-release.
+	Release      string // Empty for all releases.
+	Scheme       string // One of the Scheme* constants.
+	Packages     []AffectedPackage
+}
+
+type AffectedPackage struct {
+	Name     string // Binary or source package name.
+	Ranges   []Range
+	Versions []string // Specific affected versions.
+}
+
+type Database struct {
+	advisories map[string][]advisoryPackage // Key: package name.
+	count      uint
+}
+
+type Match struct {
+	Id             string
+	Aliases        []string `json:",omitempty"`
+	Summary        string   `json:",omitempty"`
+	Severity       string   `json:",omitempty"`
+	PackageName    string
+	PackageVersion string
+	FixedVersion   string `json:",omitempty"` // Empty if no fix available.
+}
+
+// Platform describes the distribution of an image. Any field may be empty if
+// unknown, in which case matching is less precise.
+type Platform struct {
+	Distribution   string
+	Release        string
+	Scheme         string
+	SourcePackages map[string]string // Key: binary package name.
+}
+
+type Range struct {
+	Introduced   string // Empty for all versions.
+	Fixed        string // Empty if not fixed.
+	LastAffected string // Empty if not known.
+}
+
+// CompareVersions will compare two package versions using the rules for the
+// specified scheme. It returns -1, 0 or 1 if left is less than, equal to or
+// greater than right.
+func CompareVersions(scheme, left, right string) int {
+	return compareVersions(scheme, left, right)
+}
+
+// LoadDirectory will load all the feed files in the directory tree rooted at
+// dirname.
+func LoadDirectory(dirname string) (*Database, error) {
+	return loadDirectory(dirname)
+}
+
+// NewDatabase will create a Database from a list of advisories.
+func NewDatabase(advisories []*Advisory) *Database {
+	return newDatabase(advisories)
+}
+
+// SchemeForDistribution returns the version comparison scheme for a
+// distribution, or an empty string if it is not known.
+func SchemeForDistribution(distribution string) string {
+	return schemeForDistribution(distribution)
+}
+
+// Count returns the number of advisories in the database.
+func (db *Database) Count() uint {
+	return db.count
+}
+
+// Match returns the vulnerabilities which affect packages. The results are
+// sorted by package name and ID.
+func (db *Database) Match(platform Platform, packages []image.Package) []Match {
+	return db.match(platform, packages)
+}
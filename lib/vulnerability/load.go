@@ -0,0 +1,375 @@
+package vulnerability
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type osvAffected struct {
+	Package  osvPackage `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+type osvDatabaseSpecific struct {
+	Severity string `json:"severity"`
+}
+
+type osvEntry struct {
+	Id               string               `json:"id"`
+	Aliases          []string             `json:"aliases"`
+	Summary          string               `json:"summary"`
+	Details          string               `json:"details"`
+	Severity         []osvSeverity        `json:"severity"`
+	Affected         []osvAffected        `json:"affected"`
+	DatabaseSpecific *osvDatabaseSpecific `json:"database_specific"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+type osvPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type ovalAffected struct {
+	Platforms []string `xml:"platform"`
+}
+
+type ovalCriteria struct {
+	Criteria  []ovalCriteria  `xml:"criteria"`
+	Criterion []ovalCriterion `xml:"criterion"`
+}
+
+type ovalCriterion struct {
+	Comment string `xml:"comment,attr"`
+}
+
+type ovalDefinition struct {
+	Id       string       `xml:"id,attr"`
+	Class    string       `xml:"class,attr"`
+	Metadata ovalMetadata `xml:"metadata"`
+	Criteria ovalCriteria `xml:"criteria"`
+}
+
+type ovalDefinitions struct {
+	Definitions []ovalDefinition `xml:"definitions>definition"`
+}
+
+type ovalMetadata struct {
+	Title       string          `xml:"title"`
+	Affected    []ovalAffected  `xml:"affected"`
+	References  []ovalReference `xml:"reference"`
+	Description string          `xml:"description"`
+	Severity    string          `xml:"advisory>severity"`
+}
+
+type ovalReference struct {
+	Source string `xml:"source,attr"`
+	RefId  string `xml:"ref_id,attr"`
+}
+
+// Map of OSV ecosystem names to distribution IDs.
+var osvEcosystems = map[string]string{
+	"AlmaLinux":   "almalinux",
+	"Debian":      "debian",
+	"Mageia":      "mageia",
+	"openSUSE":    "opensuse",
+	"Red Hat":     "rhel",
+	"Rocky Linux": "rocky",
+	"SUSE":        "sles",
+	"Ubuntu":      "ubuntu",
+}
+
+// Map of OVAL platform name prefixes to distribution IDs.
+var ovalPlatforms = []struct {
+	prefix       string
+	distribution string
+}{
+	{"CentOS Linux ", "centos"},
+	{"Debian GNU/Linux ", "debian"},
+	{"Red Hat Enterprise Linux ", "rhel"},
+	{"Ubuntu ", "ubuntu"},
+}
+
+var ovalCommentRegexp = regexp.MustCompile(
+	`^(\S+)( DPKG)? is earlier than (\S+)$`)
+
+var releaseRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+func loadDirectory(dirname string) (*Database, error) {
+	var advisories []*Advisory
+	err := filepath.Walk(dirname,
+		func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			var loader func(io.Reader) ([]*Advisory, error)
+			switch filepath.Ext(path) {
+			case ".json":
+				loader = loadOsv
+			case ".xml":
+				loader = loadOval
+			default:
+				return nil
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			newAdvisories, err := loader(bufio.NewReader(file))
+			if err != nil {
+				return fmt.Errorf("error loading: %s: %s", path, err)
+			}
+			advisories = append(advisories, newAdvisories...)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return newDatabase(advisories), nil
+}
+
+// loadOsv will load a file containing a single OSV entry or a list of entries.
+func loadOsv(reader io.Reader) ([]*Advisory, error) {
+	var data json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, err
+	}
+	var entries []osvEntry
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 &&
+		trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+	} else {
+		var entry osvEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	var advisories []*Advisory
+	for _, entry := range entries {
+		advisories = append(advisories, entry.convert()...)
+	}
+	return advisories, nil
+}
+
+// convert will convert an OSV entry into one advisory per distribution
+// release. Entries for ecosystems which are not Linux distributions are
+// ignored.
+func (entry osvEntry) convert() []*Advisory {
+	advisoryMap := make(map[string]*Advisory)
+	var advisories []*Advisory
+	for _, affected := range entry.Affected {
+		split := strings.SplitN(affected.Package.Ecosystem, ":", 3)
+		distribution, ok := osvEcosystems[split[0]]
+		if !ok {
+			continue
+		}
+		var release string
+		if len(split) > 1 {
+			release = parseOsvRelease(strings.Join(split[1:], ":"))
+		}
+		key := distribution + ":" + release
+		advisory := advisoryMap[key]
+		if advisory == nil {
+			advisory = &Advisory{
+				Id:           entry.Id,
+				Aliases:      entry.Aliases,
+				Summary:      entry.Summary,
+				Severity:     entry.severity(),
+				Distribution: distribution,
+				Release:      release,
+			}
+			if advisory.Summary == "" {
+				advisory.Summary = firstLine(entry.Details)
+			}
+			advisoryMap[key] = advisory
+			advisories = append(advisories, advisory)
+		}
+		pkg := AffectedPackage{
+			Name:     affected.Package.Name,
+			Versions: affected.Versions,
+		}
+		for _, osvRange := range affected.Ranges {
+			if osvRange.Type != "ECOSYSTEM" {
+				continue
+			}
+			pkg.Ranges = append(pkg.Ranges, convertOsvEvents(osvRange.Events)...)
+		}
+		advisory.Packages = append(advisory.Packages, pkg)
+	}
+	return advisories
+}
+
+// parseOsvRelease will extract the release from the part of an OSV ecosystem
+// after the distribution name. The format depends on the distribution, such as
+// "10" (Debian), "22.04:LTS" (Ubuntu), "enterprise_linux:8::appstream"
+// (Red Hat), "Linux Enterprise Server 15 SP5" (SUSE) or "Leap 15.5"
+// (openSUSE), so the first field which is a version number is used. If there
+// is none (such as for rolling releases), the release is empty and the
+// advisory applies to all releases.
+func parseOsvRelease(text string) string {
+	for _, field := range strings.FieldsFunc(text, func(ch rune) bool {
+		return ch == ':' || ch == ' '
+	}) {
+		if releaseRegexp.MatchString(field) {
+			return field
+		}
+	}
+	return ""
+}
+
+func (entry osvEntry) severity() string {
+	if entry.DatabaseSpecific != nil && entry.DatabaseSpecific.Severity != "" {
+		return entry.DatabaseSpecific.Severity
+	}
+	for _, severity := range entry.Severity {
+		return severity.Score
+	}
+	return ""
+}
+
+// convertOsvEvents will convert a list of OSV events into ranges. Each
+// introduced event starts a new range.
+func convertOsvEvents(events []osvEvent) []Range {
+	var ranges []Range
+	var current *Range
+	for _, event := range events {
+		if event.Introduced != "" {
+			ranges = append(ranges, Range{Introduced: event.Introduced})
+			current = &ranges[len(ranges)-1]
+			continue
+		}
+		if current == nil {
+			ranges = append(ranges, Range{})
+			current = &ranges[len(ranges)-1]
+		}
+		if event.Fixed != "" {
+			current.Fixed = event.Fixed
+			current = nil
+		} else if event.LastAffected != "" {
+			current.LastAffected = event.LastAffected
+			current = nil
+		}
+	}
+	return ranges
+}
+
+// loadOval will load an OVAL definitions file. Affected packages are found
+// from the "package is earlier than version" criteria comments which are
+// used by the Debian, Red Hat and CentOS OVAL feeds.
+func loadOval(reader io.Reader) ([]*Advisory, error) {
+	var definitions ovalDefinitions
+	if err := xml.NewDecoder(reader).Decode(&definitions); err != nil {
+		return nil, err
+	}
+	var advisories []*Advisory
+	for _, definition := range definitions.Definitions {
+		if definition.Class != "" && definition.Class != "vulnerability" &&
+			definition.Class != "patch" {
+			continue
+		}
+		var distribution, release string
+		for _, affected := range definition.Metadata.Affected {
+			for _, platform := range affected.Platforms {
+				distribution, release = parseOvalPlatform(platform)
+				if distribution != "" {
+					break
+				}
+			}
+		}
+		advisory := &Advisory{
+			Summary:      definition.Metadata.Title,
+			Severity:     definition.Metadata.Severity,
+			Distribution: distribution,
+			Release:      release,
+		}
+		for _, reference := range definition.Metadata.References {
+			if advisory.Id == "" {
+				advisory.Id = reference.RefId
+			} else {
+				advisory.Aliases = append(advisory.Aliases, reference.RefId)
+			}
+		}
+		if advisory.Id == "" {
+			advisory.Id = definition.Id
+		}
+		definition.Criteria.walk(func(comment string) {
+			submatches := ovalCommentRegexp.FindStringSubmatch(comment)
+			if submatches == nil {
+				return
+			}
+			if submatches[2] != "" {
+				advisory.Scheme = SchemeDeb
+			}
+			advisory.Packages = append(advisory.Packages, AffectedPackage{
+				Name:   submatches[1],
+				Ranges: []Range{{Fixed: submatches[3]}},
+			})
+		})
+		if len(advisory.Packages) > 0 {
+			advisories = append(advisories, advisory)
+		}
+	}
+	return advisories, nil
+}
+
+func (criteria *ovalCriteria) walk(commentFunc func(string)) {
+	for _, criterion := range criteria.Criterion {
+		commentFunc(criterion.Comment)
+	}
+	for index := range criteria.Criteria {
+		criteria.Criteria[index].walk(commentFunc)
+	}
+}
+
+// parseOvalPlatform will convert a platform name such as "Debian GNU/Linux 10"
+// to a distribution ID and release.
+func parseOvalPlatform(platform string) (string, string) {
+	for _, entry := range ovalPlatforms {
+		if strings.HasPrefix(platform, entry.prefix) {
+			fields := strings.Fields(platform[len(entry.prefix):])
+			if len(fields) < 1 {
+				return entry.distribution, ""
+			}
+			return entry.distribution, fields[0]
+		}
+	}
+	return "", ""
+}
+
+func firstLine(text string) string {
+	if index := strings.IndexByte(text, '\n'); index >= 0 {
+		return text[:index]
+	}
+	return text
+}
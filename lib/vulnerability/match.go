@@ -0,0 +1,151 @@
+package vulnerability
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+type advisoryPackage struct {
+	advisory *Advisory
+	pkg      *AffectedPackage
+}
+
+var distributionSchemes = map[string]string{
+	"almalinux":     SchemeRpm,
+	"amzn":          SchemeRpm,
+	"centos":        SchemeRpm,
+	"debian":        SchemeDeb,
+	"fedora":        SchemeRpm,
+	"mageia":        SchemeRpm,
+	"opensuse":      SchemeRpm,
+	"opensuse-leap": SchemeRpm,
+	"rhel":          SchemeRpm,
+	"rocky":         SchemeRpm,
+	"sles":          SchemeRpm,
+	"ubuntu":        SchemeDeb,
+}
+
+// Distributions which use the advisories of another distribution.
+var distributionParents = map[string]string{
+	"centos":        "rhel",
+	"opensuse-leap": "opensuse",
+}
+
+func newDatabase(advisories []*Advisory) *Database {
+	db := &Database{advisories: make(map[string][]advisoryPackage)}
+	for _, advisory := range advisories {
+		if advisory.Scheme == "" {
+			advisory.Scheme = schemeForDistribution(advisory.Distribution)
+		}
+		for index := range advisory.Packages {
+			pkg := &advisory.Packages[index]
+			db.advisories[pkg.Name] = append(db.advisories[pkg.Name],
+				advisoryPackage{advisory, pkg})
+		}
+		db.count++
+	}
+	return db
+}
+
+func schemeForDistribution(distribution string) string {
+	return distributionSchemes[distribution]
+}
+
+func (db *Database) match(platform Platform,
+	packages []image.Package) []Match {
+	if platform.Scheme == "" {
+		platform.Scheme = schemeForDistribution(platform.Distribution)
+	}
+	var matches []Match
+	for _, pkg := range packages {
+		found := make(map[string]struct{})
+		entries := db.advisories[pkg.Name]
+		if source := platform.SourcePackages[pkg.Name]; source != "" &&
+			source != pkg.Name {
+			entries = append(entries[:len(entries):len(entries)],
+				db.advisories[source]...)
+		}
+		for _, entry := range entries {
+			if _, ok := found[entry.advisory.Id]; ok {
+				continue
+			}
+			if !platform.matches(entry.advisory) {
+				continue
+			}
+			affected, fixedVersion := entry.pkg.isAffected(
+				entry.advisory.Scheme, pkg.Version)
+			if !affected {
+				continue
+			}
+			found[entry.advisory.Id] = struct{}{}
+			matches = append(matches, Match{
+				Id:             entry.advisory.Id,
+				Aliases:        entry.advisory.Aliases,
+				Summary:        entry.advisory.Summary,
+				Severity:       entry.advisory.Severity,
+				PackageName:    pkg.Name,
+				PackageVersion: pkg.Version,
+				FixedVersion:   fixedVersion,
+			})
+		}
+	}
+	sort.Slice(matches, func(left, right int) bool {
+		if matches[left].PackageName != matches[right].PackageName {
+			return matches[left].PackageName < matches[right].PackageName
+		}
+		return matches[left].Id < matches[right].Id
+	})
+	return matches
+}
+
+// matches returns true if the advisory applies to the platform.
+func (platform Platform) matches(advisory *Advisory) bool {
+	if platform.Scheme != "" && advisory.Scheme != platform.Scheme {
+		return false
+	}
+	if platform.Distribution == "" || advisory.Distribution == "" {
+		return true
+	}
+	if advisory.Distribution != platform.Distribution &&
+		advisory.Distribution != distributionParents[platform.Distribution] {
+		return false
+	}
+	if platform.Release == "" || advisory.Release == "" {
+		return true
+	}
+	// Advisory releases may be major versions only (such as "8" for "8.6").
+	return platform.Release == advisory.Release ||
+		strings.HasPrefix(platform.Release, advisory.Release+".")
+}
+
+// isAffected returns true if version is affected, and the version which fixes
+// the vulnerability (if known).
+func (pkg *AffectedPackage) isAffected(scheme, version string) (bool, string) {
+	for _, affectedVersion := range pkg.Versions {
+		if compareVersions(scheme, version, affectedVersion) == 0 {
+			return true, ""
+		}
+	}
+	for _, r := range pkg.Ranges {
+		if r.Introduced != "" && r.Introduced != "0" &&
+			compareVersions(scheme, version, r.Introduced) < 0 {
+			continue
+		}
+		if r.Fixed != "" {
+			if compareVersions(scheme, version, r.Fixed) < 0 {
+				return true, r.Fixed
+			}
+			continue
+		}
+		if r.LastAffected != "" {
+			if compareVersions(scheme, version, r.LastAffected) <= 0 {
+				return true, ""
+			}
+			continue
+		}
+		return true, ""
+	}
+	return false, ""
+}
@@ -0,0 +1,216 @@
+package vulnerability
+
+import (
+	"strings"
+)
+
+func compareVersions(scheme, left, right string) int {
+	switch scheme {
+	case SchemeRpm:
+		return compareRpmVersions(left, right)
+	default:
+		return compareDebVersions(left, right)
+	}
+}
+
+// splitEpoch splits "epoch:rest" and returns the numeric epoch and the rest.
+func splitEpoch(version string) (string, string) {
+	if index := strings.IndexByte(version, ':'); index >= 0 {
+		return version[:index], version[index+1:]
+	}
+	return "0", version
+}
+
+// compareNumbers compares two strings of digits numerically.
+func compareNumbers(left, right string) int {
+	left = strings.TrimLeft(left, "0")
+	right = strings.TrimLeft(right, "0")
+	if len(left) < len(right) {
+		return -1
+	} else if len(left) > len(right) {
+		return 1
+	}
+	return strings.Compare(left, right)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// compareDebVersions compares versions using the rules from deb-version(7).
+func compareDebVersions(left, right string) int {
+	leftEpoch, left := splitEpoch(left)
+	rightEpoch, right := splitEpoch(right)
+	if result := compareNumbers(leftEpoch, rightEpoch); result != 0 {
+		return result
+	}
+	var leftRevision, rightRevision string
+	if index := strings.LastIndexByte(left, '-'); index >= 0 {
+		left, leftRevision = left[:index], left[index+1:]
+	}
+	if index := strings.LastIndexByte(right, '-'); index >= 0 {
+		right, rightRevision = right[:index], right[index+1:]
+	}
+	if result := compareDebStrings(left, right); result != 0 {
+		return result
+	}
+	return compareDebStrings(leftRevision, rightRevision)
+}
+
+// debOrder returns the sort weight of a character in the non-digit part of a
+// version: '~' sorts before everything (even the end of the string) and
+// letters sort before other characters.
+func debOrder(ch byte) int {
+	if ch == '~' {
+		return -1
+	}
+	if isLetter(ch) {
+		return int(ch)
+	}
+	return int(ch) + 256
+}
+
+func compareDebStrings(left, right string) int {
+	for len(left) > 0 || len(right) > 0 {
+		for (len(left) > 0 && !isDigit(left[0])) ||
+			(len(right) > 0 && !isDigit(right[0])) {
+			var leftOrder, rightOrder int
+			if len(left) > 0 && !isDigit(left[0]) {
+				leftOrder = debOrder(left[0])
+			}
+			if len(right) > 0 && !isDigit(right[0]) {
+				rightOrder = debOrder(right[0])
+			}
+			if leftOrder < rightOrder {
+				return -1
+			} else if leftOrder > rightOrder {
+				return 1
+			}
+			left, right = left[1:], right[1:]
+		}
+		var leftNumber, rightNumber string
+		leftNumber, left = splitDigits(left)
+		rightNumber, right = splitDigits(right)
+		if result := compareNumbers(leftNumber, rightNumber); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func splitDigits(str string) (string, string) {
+	index := 0
+	for index < len(str) && isDigit(str[index]) {
+		index++
+	}
+	return str[:index], str[index:]
+}
+
+// compareRpmVersions compares [epoch:]version[-release] strings using the
+// rules from rpmvercmp.
+func compareRpmVersions(left, right string) int {
+	leftEpoch, left := splitEpoch(left)
+	rightEpoch, right := splitEpoch(right)
+	if result := compareNumbers(leftEpoch, rightEpoch); result != 0 {
+		return result
+	}
+	var leftRelease, rightRelease string
+	if index := strings.LastIndexByte(left, '-'); index >= 0 {
+		left, leftRelease = left[:index], left[index+1:]
+	}
+	if index := strings.LastIndexByte(right, '-'); index >= 0 {
+		right, rightRelease = right[:index], right[index+1:]
+	}
+	if result := compareRpmStrings(left, right); result != 0 {
+		return result
+	}
+	if leftRelease == "" || rightRelease == "" {
+		return 0 // A missing release matches any release.
+	}
+	return compareRpmStrings(leftRelease, rightRelease)
+}
+
+func compareRpmStrings(left, right string) int {
+	if left == right {
+		return 0
+	}
+	for len(left) > 0 || len(right) > 0 {
+		left = strings.TrimLeftFunc(left, isRpmSeparator)
+		right = strings.TrimLeftFunc(right, isRpmSeparator)
+		// A tilde sorts before everything else.
+		if strings.HasPrefix(left, "~") || strings.HasPrefix(right, "~") {
+			if !strings.HasPrefix(left, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(right, "~") {
+				return -1
+			}
+			left, right = left[1:], right[1:]
+			continue
+		}
+		// A caret sorts after the end of the string but before anything else.
+		if strings.HasPrefix(left, "^") || strings.HasPrefix(right, "^") {
+			if left == "" {
+				return -1
+			}
+			if right == "" {
+				return 1
+			}
+			if !strings.HasPrefix(left, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(right, "^") {
+				return -1
+			}
+			left, right = left[1:], right[1:]
+			continue
+		}
+		if left == "" || right == "" {
+			break
+		}
+		var leftSegment, rightSegment string
+		if isDigit(left[0]) {
+			leftSegment, left = splitDigits(left)
+			if !isDigit(right[0]) {
+				return 1 // Numeric segments are newer than alphabetic.
+			}
+			rightSegment, right = splitDigits(right)
+			if result := compareNumbers(leftSegment, rightSegment); result != 0 {
+				return result
+			}
+			continue
+		}
+		if isDigit(right[0]) {
+			return -1
+		}
+		leftSegment, left = splitLetters(left)
+		rightSegment, right = splitLetters(right)
+		if result := strings.Compare(leftSegment, rightSegment); result != 0 {
+			return result
+		}
+	}
+	if left == "" && right == "" {
+		return 0
+	}
+	if left == "" {
+		return -1
+	}
+	return 1
+}
+
+func isRpmSeparator(ch rune) bool {
+	return !(ch < 128 && (isDigit(byte(ch)) || isLetter(byte(ch)))) &&
+		ch != '~' && ch != '^'
+}
+
+func splitLetters(str string) (string, string) {
+	index := 0
+	for index < len(str) && isLetter(str[index]) {
+		index++
+	}
+	return str[:index], str[index:]
+}
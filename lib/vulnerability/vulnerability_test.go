@@ -0,0 +1,177 @@
+package vulnerability
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+const testOsv = `[{
+  "id": "DSA-1",
+  "aliases": ["CVE-2020-0001"],
+  "summary": "glibc bug",
+  "affected": [{
+    "package": {"ecosystem": "Debian:10", "name": "glibc"},
+    "ranges": [{"type": "ECOSYSTEM",
+                "events": [{"introduced": "0"}, {"fixed": "2.28-10+deb10u1"}]}]
+  }, {
+    "package": {"ecosystem": "PyPI", "name": "glibc"},
+    "versions": ["2.28-10"]
+  }]
+}]`
+
+const testRedHatOsv = `{
+  "id": "RHSA-2",
+  "summary": "openssl security update",
+  "affected": [{
+    "package": {"ecosystem": "Red Hat:enterprise_linux:8::appstream",
+                "name": "openssl"},
+    "ranges": [{"type": "ECOSYSTEM",
+                "events": [{"introduced": "0"}, {"fixed": "1:1.1.1k-7.el8"}]}]
+  }]
+}`
+
+const testOval = `<?xml version="1.0"?>
+<oval_definitions xmlns="http://oval.mitre.org/XMLSchema/oval-definitions-5">
+ <definitions>
+  <definition id="oval:com.redhat.rhsa:def:1" class="patch">
+   <metadata>
+    <title>RHSA-1: bash security update (Important)</title>
+    <affected family="unix">
+     <platform>Red Hat Enterprise Linux 7</platform>
+    </affected>
+    <reference ref_id="RHSA-1" source="RHSA"/>
+    <reference ref_id="CVE-2020-0002" source="CVE"/>
+    <advisory><severity>Important</severity></advisory>
+   </metadata>
+   <criteria operator="AND">
+    <criterion comment="Red Hat Enterprise Linux 7 is installed"/>
+    <criteria operator="OR">
+     <criterion comment="bash is earlier than 0:4.2.46-34.el7"/>
+     <criterion comment="bash is signed with Red Hat redhatrelease2 key"/>
+    </criteria>
+   </criteria>
+  </definition>
+ </definitions>
+</oval_definitions>`
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		scheme, left, right string
+		result              int
+	}{
+		{SchemeDeb, "1.0", "1.0", 0},
+		{SchemeDeb, "1.0~rc1", "1.0", -1},
+		{SchemeDeb, "1.0", "1.0+b1", -1},
+		{SchemeDeb, "1:0.9", "2.0", 1},
+		{SchemeDeb, "2.28-10", "2.28-10+deb10u1", -1},
+		{SchemeDeb, "1.10", "1.9", 1},
+		{SchemeDeb, "1.0a", "1.0-1", 1},
+		{SchemeRpm, "4.2.46-34.el7", "0:4.2.46-34.el7", 0},
+		{SchemeRpm, "4.2.46-33.el7", "4.2.46-34.el7", -1},
+		{SchemeRpm, "1.0~rc1", "1.0", -1},
+		{SchemeRpm, "1.0^git1", "1.0", 1},
+		{SchemeRpm, "1.0^git1", "1.0.1", -1},
+		{SchemeRpm, "1.a", "1.1", -1},
+		{SchemeRpm, "2.0", "10.0", -1},
+	}
+	for _, test := range tests {
+		result := CompareVersions(test.scheme, test.left, test.right)
+		if result != test.result {
+			t.Errorf("%s: compare(%s, %s): expected %d, got %d",
+				test.scheme, test.left, test.right, test.result, result)
+		}
+	}
+}
+
+func TestParseOsvRelease(t *testing.T) {
+	tests := map[string]string{
+		"10":                             "10",
+		"22.04:LTS":                      "22.04",
+		"enterprise_linux:8::appstream":  "8",
+		"Linux Enterprise Server 15 SP5": "15",
+		"Leap 15.5":                      "15.5",
+		"Tumbleweed":                     "",
+	}
+	for text, expected := range tests {
+		if release := parseOsvRelease(text); release != expected {
+			t.Errorf("%s: expected: \"%s\", got: \"%s\"",
+				text, expected, release)
+		}
+	}
+}
+
+func TestMatchRedHatOsv(t *testing.T) {
+	advisories, err := loadOsv(strings.NewReader(testRedHatOsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != 1 {
+		t.Fatalf("expected 1 OSV advisory, got: %d", len(advisories))
+	}
+	if advisories[0].Distribution != "rhel" || advisories[0].Release != "8" {
+		t.Fatalf("bad distribution/release: %s/%s",
+			advisories[0].Distribution, advisories[0].Release)
+	}
+	db := NewDatabase(advisories)
+	packages := []image.Package{{Name: "openssl", Version: "1:1.1.1k-6.el8"}}
+	platform := Platform{Distribution: "rhel", Release: "8.6"}
+	if matches := db.Match(platform, packages); len(matches) != 1 {
+		t.Errorf("expected 1 match, got: %v", matches)
+	}
+	platform.Release = "9.0"
+	if matches := db.Match(platform, packages); len(matches) != 0 {
+		t.Errorf("expected no matches for other release, got: %v", matches)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	advisories, err := loadOsv(strings.NewReader(testOsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != 1 {
+		t.Fatalf("expected 1 OSV advisory, got: %d", len(advisories))
+	}
+	ovalAdvisories, err := loadOval(strings.NewReader(testOval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ovalAdvisories) != 1 {
+		t.Fatalf("expected 1 OVAL advisory, got: %d", len(ovalAdvisories))
+	}
+	db := NewDatabase(append(advisories, ovalAdvisories...))
+	debPackages := []image.Package{
+		{Name: "bash", Version: "5.0-4"},
+		{Name: "libc6", Version: "2.28-10"},
+	}
+	platform := Platform{
+		Distribution:   "debian",
+		Release:        "10",
+		SourcePackages: map[string]string{"libc6": "glibc"},
+	}
+	matches := db.Match(platform, debPackages)
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got: %v", matches)
+	}
+	if matches[0].Id != "DSA-1" || matches[0].PackageName != "libc6" ||
+		matches[0].FixedVersion != "2.28-10+deb10u1" {
+		t.Errorf("bad match: %v", matches[0])
+	}
+	platform.Release = "11"
+	if matches := db.Match(platform, debPackages); len(matches) != 0 {
+		t.Errorf("expected no matches for other release, got: %v", matches)
+	}
+	rpmPackages := []image.Package{{Name: "bash", Version: "4.2.46-33.el7"}}
+	matches = db.Match(Platform{Distribution: "centos", Release: "7"},
+		rpmPackages)
+	if len(matches) != 1 || matches[0].Id != "RHSA-1" ||
+		matches[0].Severity != "Important" {
+		t.Fatalf("bad RPM matches: %v", matches)
+	}
+	rpmPackages[0].Version = "4.2.46-34.el7"
+	if matches := db.Match(Platform{}, rpmPackages); len(matches) != 0 {
+		t.Errorf("expected no matches for fixed version, got: %v", matches)
+	}
+}
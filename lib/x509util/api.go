@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 
 	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/tags"
 )
 
 // GetGroupList decodes the list of groups in the certificate.
@@ -30,3 +31,20 @@ func GetPermittedMethods(cert *x509.Certificate) (map[string]struct{}, error) {
 func GetUsername(cert *x509.Certificate) (string, error) {
 	return getUsername(cert)
 }
+
+// GetVmOwnerGroups decodes the list of owner groups for the VM which the
+// certificate was granted to. An empty map indicates no groups listed.
+func GetVmOwnerGroups(cert *x509.Certificate) (map[string]struct{}, error) {
+	return getList(cert, constants.VmOwnerGroupsOID)
+}
+
+// GetVmOwnerUsers decodes the list of owner users for the VM which the
+// certificate was granted to. An empty map indicates no users listed.
+func GetVmOwnerUsers(cert *x509.Certificate) (map[string]struct{}, error) {
+	return getList(cert, constants.VmOwnerUsersOID)
+}
+
+// GetVmTags decodes the tags for the VM which the certificate was granted to.
+func GetVmTags(cert *x509.Certificate) (tags.Tags, error) {
+	return getVmTags(cert)
+}
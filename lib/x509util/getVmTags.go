@@ -0,0 +1,25 @@
+package x509util
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/tags"
+)
+
+func getVmTags(cert *x509.Certificate) (tags.Tags, error) {
+	list, err := getList(cert, constants.VmTagsOID)
+	if err != nil {
+		return nil, err
+	}
+	vmTags := make(tags.Tags, len(list))
+	for line := range list {
+		var tag tags.Tag
+		if err := tag.Set(line); err != nil {
+			return nil, fmt.Errorf("bad tag: \"%s\": %s", line, err)
+		}
+		vmTags[tag.Key] = tag.Value
+	}
+	return vmTags, nil
+}
@@ -0,0 +1,21 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/objectserver"
+)
+
+type scrubStatusGetter interface {
+	GetScrubStatus() objectserver.ScrubStatus
+}
+
+func (t *srpcType) GetScrubStatus(conn *srpc.Conn,
+	request objectserver.GetScrubStatusRequest,
+	reply *objectserver.GetScrubStatusResponse) error {
+	if getter, ok := t.objectServer.(scrubStatusGetter); !ok {
+		reply.Error = "scrubbing not supported by this object server"
+	} else {
+		reply.Status = getter.GetScrubStatus()
+	}
+	return nil
+}
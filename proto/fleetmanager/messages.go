@@ -16,6 +16,21 @@ type ChangeMachineTagsResponse struct {
 	Error string
 }
 
+// The DrainHypervisor() RPC is fully streamed.
+// The client sends a single DrainHypervisorRequest message.
+// The server sends a stream of DrainHypervisorResponse messages until Final is
+// true.
+type DrainHypervisorRequest struct {
+	Hostname       string
+	MaxConcurrency uint // Zero means one at a time.
+}
+
+type DrainHypervisorResponse struct {
+	Error           string
+	Final           bool
+	ProgressMessage string
+}
+
 type GetHypervisorForVMRequest struct {
 	IpAddress net.IP
 }
@@ -109,3 +124,11 @@ type NetworkEntry struct {
 	HostIpAddress  net.IP       `json:",omitempty"`
 	HostMacAddress HardwareAddr `json:",omitempty"`
 }
+
+type UndrainHypervisorRequest struct {
+	Hostname string
+}
+
+type UndrainHypervisorResponse struct {
+	Error string
+}
@@ -12,6 +12,10 @@ const (
 	ConsoleDummy = 1
 	ConsoleVNC   = 2
 
+	RestartNever     = 0
+	RestartOnFailure = 1
+	RestartAlways    = 2
+
 	StateStarting      = 0
 	StateRunning       = 1
 	StateFailedToStart = 2
@@ -33,6 +37,16 @@ type AcknowledgeVmResponse struct {
 	Error string
 }
 
+type AddVmVolumesRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	Volumes     []Volume
+}
+
+type AddVmVolumesResponse struct {
+	Error string
+}
+
 type Address struct {
 	IpAddress  net.IP `json:",omitempty"`
 	MacAddress string
@@ -83,6 +97,15 @@ type ChangeVmDestroyProtectionResponse struct {
 	Error string
 }
 
+type ChangeVmFirewallRulesRequest struct {
+	FirewallRules *FirewallRules // If nil, remove all rules.
+	IpAddress     net.IP
+}
+
+type ChangeVmFirewallRulesResponse struct {
+	Error string
+}
+
 type ChangeVmOwnerUsersRequest struct {
 	IpAddress  net.IP
 	OwnerUsers []string
@@ -92,6 +115,30 @@ type ChangeVmOwnerUsersResponse struct {
 	Error string
 }
 
+type ChangeVmRestartPolicyRequest struct {
+	IpAddress     net.IP
+	MaxRestarts   uint
+	RestartPolicy RestartPolicy
+}
+
+type ChangeVmRestartPolicyResponse struct {
+	Error string
+}
+
+// The ChangeVmSize RPC does not hotplug memory or CPUs. The memory and the
+// number of vCPUs (MilliCPUs rounded up to whole CPUs) may only be changed
+// while the VM is stopped. MilliCPUs may be changed within the same number of
+// vCPUs while the VM is running.
+type ChangeVmSizeRequest struct {
+	IpAddress   net.IP
+	MemoryInMiB uint64 // Unchanged if zero.
+	MilliCPUs   uint   // Unchanged if zero.
+}
+
+type ChangeVmSizeResponse struct {
+	Error string
+}
+
 type ChangeVmTagsRequest struct {
 	IpAddress net.IP
 	Tags      tags.Tags
@@ -134,8 +181,19 @@ type ConnectToVmSerialPortResponse struct {
 
 type ConsoleType uint
 
+// CrashInfo records unexpected exits of a VM and automatic restarts.
+type CrashInfo struct {
+	ConsecutiveRestarts uint   `json:",omitempty"` // Reset once stable.
+	LastCrashReason     string `json:",omitempty"`
+	LastCrashTime       time.Time
+	NumCrashes          uint
+	NumRestarts         uint `json:",omitempty"`
+	RestartPending      bool `json:",omitempty"`
+}
+
 type CopyVmRequest struct {
 	AccessToken      []byte
+	DiscardSnapshots bool // Named snapshots are not copied.
 	IpAddress        net.IP
 	SourceHypervisor string
 	VmInfo
@@ -215,6 +273,7 @@ type DiscardVmOldUserDataResponse struct {
 
 type DiscardVmSnapshotRequest struct {
 	IpAddress net.IP
+	Name      string // If empty, discard the unnamed snapshot.
 }
 
 type DiscardVmSnapshotResponse struct {
@@ -240,6 +299,27 @@ type ExportLocalVmResponse struct {
 // The client may or may not send GetUpdateRequest messages to the server.
 // The server sends a stream of Update messages.
 
+// FirewallRule permits traffic with a peer. The peer is matched by any of
+// Cidrs or PeerTags (if both are empty, any peer is matched). Ports are
+// destination ports and may only be given for the tcp and udp protocols.
+type FirewallRule struct {
+	Cidrs    []string    `json:",omitempty"` // Addresses or networks.
+	PeerTags tags.Tags   `json:",omitempty"` // VMs which have all these tags.
+	Ports    []PortRange `json:",omitempty"`
+	Protocol string      `json:",omitempty"` // tcp, udp, icmp or "" (any).
+}
+
+// FirewallRules specify the traffic permitted to and from a VM. If a
+// DefaultDeny flag is false, all traffic in that direction is permitted,
+// otherwise only established traffic and traffic matching one of the rules is
+// permitted. Anti-spoofing rules are always applied.
+type FirewallRules struct {
+	Egress             []FirewallRule `json:",omitempty"`
+	EgressDefaultDeny  bool           `json:",omitempty"`
+	Ingress            []FirewallRule `json:",omitempty"`
+	IngressDefaultDeny bool           `json:",omitempty"`
+}
+
 type GetUpdateRequest struct{}
 
 type Update struct {
@@ -247,6 +327,8 @@ type Update struct {
 	AddressPool      []Address          `json:",omitempty"` // Used & free.
 	NumFreeAddresses map[string]uint    `json:",omitempty"` // Key: subnet ID.
 	HealthStatus     string             `json:",omitempty"`
+	MemoryInMiB      uint64             `json:",omitempty"` // Initial only.
+	NumCPUs          uint               `json:",omitempty"` // Initial only.
 	HaveSerialNumber bool               `json:",omitempty"`
 	SerialNumber     string             `json:",omitempty"`
 	HaveSubnets      bool               `json:",omitempty"`
@@ -274,6 +356,35 @@ type GetVmInfoResponse struct {
 	Error  string
 }
 
+// The GetVmMemory RPC is used by the destination hypervisor during live
+// migration. The source hypervisor sends a GetVmMemoryResponse, the migration
+// stream (as length-prefixed chunks, ending with an empty chunk) and another
+// GetVmMemoryResponse with the migration result. The VM is then paused on the
+// source until the destination sends a GetVmMemoryResponseResponse. If Commit
+// is false or the connection is closed, the VM is resumed on the source,
+// otherwise it is stopped and a final GetVmMemoryResponse is sent.
+type GetVmMemoryRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+}
+
+type GetVmMemoryResponse struct { // Multiple responses are sent.
+	Error string
+}
+
+type GetVmMemoryResponseResponse struct {
+	Commit bool
+}
+
+type GetVmStatsRequest struct {
+	IpAddress net.IP
+}
+
+type GetVmStatsResponse struct {
+	Error string
+	Stats VmStats
+}
+
 type GetVmUserDataRequest struct {
 	AccessToken []byte
 	IpAddress   net.IP
@@ -315,6 +426,15 @@ type ListVMsResponse struct {
 	IpAddresses []net.IP
 }
 
+type ListVmSnapshotsRequest struct {
+	IpAddress net.IP
+}
+
+type ListVmSnapshotsResponse struct {
+	Error     string
+	Snapshots []Snapshot
+}
+
 type ListVolumeDirectoriesRequest struct{}
 
 type ListVolumeDirectoriesResponse struct {
@@ -335,6 +455,7 @@ type LocalVmInfo struct {
 type MigrateVmRequest struct {
 	AccessToken      []byte
 	DhcpTimeout      time.Duration
+	DiscardSnapshots bool // Named snapshots are not migrated.
 	IpAddress        net.IP
 	SourceHypervisor string
 }
@@ -377,6 +498,11 @@ type PatchVmImageResponse struct { // Multiple responses are sent.
 	Error           string
 }
 
+type PortRange struct {
+	First uint16
+	Last  uint16 `json:",omitempty"` // If zero, only First.
+}
+
 type PrepareVmForMigrationRequest struct {
 	AccessToken []byte
 	Enable      bool
@@ -426,9 +552,23 @@ type ReplaceVmUserDataResponse struct {
 	Error string
 }
 
+type ResizeVmVolumeRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	VolumeIndex uint
+	VolumeSize  uint64
+}
+
+type ResizeVmVolumeResponse struct {
+	Error string
+}
+
+type RestartPolicy uint
+
 type RestoreVmFromSnapshotRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
+	Name              string // If empty, restore the unnamed snapshot.
 }
 
 type RestoreVmFromSnapshotResponse struct {
@@ -451,10 +591,18 @@ type RestoreVmUserDataResponse struct {
 	Error string
 }
 
+type Snapshot struct {
+	CreatedOn time.Time
+	Name      string
+	RootOnly  bool   `json:",omitempty"`
+	Size      uint64 // Space used by copies of volumes.
+}
+
 type SnapshotVmRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
 	RootOnly          bool
+	Name              string // If empty, replace the unnamed snapshot.
 }
 
 type SnapshotVmResponse struct {
@@ -515,24 +663,49 @@ type UpdateSubnetsResponse struct {
 
 type VmInfo struct {
 	Address            Address
-	ConsoleType        ConsoleType `json:",omitempty"`
-	DestroyProtection  bool        `json:",omitempty"`
-	DisableVirtIO      bool        `json:",omitempty"`
-	Hostname           string      `json:",omitempty"`
-	ImageName          string      `json:",omitempty"`
-	ImageURL           string      `json:",omitempty"`
+	CloudInitSeed      bool           `json:",omitempty"` // SMBIOS seed URL.
+	ConsoleType        ConsoleType    `json:",omitempty"`
+	CrashInfo          *CrashInfo     `json:",omitempty"`
+	DestroyProtection  bool           `json:",omitempty"`
+	DisableVirtIO      bool           `json:",omitempty"`
+	FirewallDisabled   bool           `json:",omitempty"` // nft unavailable.
+	FirewallRules      *FirewallRules `json:",omitempty"`
+	Hostname           string         `json:",omitempty"`
+	ImageName          string         `json:",omitempty"`
+	ImageURL           string         `json:",omitempty"`
+	MaxRestarts        uint           `json:",omitempty"` // 0: unlimited.
 	MemoryInMiB        uint64
 	MilliCPUs          uint
-	OwnerGroups        []string `json:",omitempty"`
-	OwnerUsers         []string `json:",omitempty"`
-	SpreadVolumes      bool     `json:",omitempty"`
+	OwnerGroups        []string      `json:",omitempty"`
+	OwnerUsers         []string      `json:",omitempty"`
+	RestartPolicy      RestartPolicy `json:",omitempty"`
+	SpreadVolumes      bool          `json:",omitempty"`
 	State              State
-	Tags               tags.Tags `json:",omitempty"`
-	SecondaryAddresses []Address `json:",omitempty"`
-	SecondarySubnetIDs []string  `json:",omitempty"`
-	SubnetId           string    `json:",omitempty"`
-	Uncommitted        bool      `json:",omitempty"`
-	Volumes            []Volume  `json:",omitempty"`
+	Tags               tags.Tags  `json:",omitempty"`
+	SecondaryAddresses []Address  `json:",omitempty"`
+	SecondarySubnetIDs []string   `json:",omitempty"`
+	Snapshots          []Snapshot `json:",omitempty"`
+	SubnetId           string     `json:",omitempty"`
+	Uncommitted        bool       `json:",omitempty"`
+	Volumes            []Volume   `json:",omitempty"`
+}
+
+// NetworkStats are from the perspective of the VM.
+type NetworkStats struct {
+	Interface       string
+	ReceivedBytes   uint64
+	ReceivedPackets uint64
+	SentBytes       uint64
+	SentPackets     uint64
+}
+
+type VmStats struct {
+	BalloonBytes uint64 `json:",omitempty"` // Zero if no balloon device.
+	CpuTime      time.Duration
+	Networks     []NetworkStats `json:",omitempty"`
+	RssBytes     uint64
+	Timestamp    time.Time
+	Volumes      []VolumeStats `json:",omitempty"`
 }
 
 type Volume struct {
@@ -541,3 +714,11 @@ type Volume struct {
 }
 
 type VolumeFormat uint
+
+type VolumeStats struct {
+	Device          string
+	ReadBytes       uint64
+	ReadOperations  uint64
+	WriteBytes      uint64
+	WriteOperations uint64
+}
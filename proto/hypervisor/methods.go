@@ -2,10 +2,12 @@ package hypervisor
 
 import (
 	"errors"
+	"fmt"
 	"net"
 )
 
 const consoleTypeUnknown = "UNKNOWN ConsoleType"
+const restartPolicyUnknown = "UNKNOWN RestartPolicy"
 const stateUnknown = "UNKNOWN State"
 const volumeFormatUnknown = "UNKNOWN VolumeFormat"
 
@@ -17,6 +19,13 @@ var (
 	}
 	textToConsoleType map[string]ConsoleType
 
+	restartPolicyToText = map[RestartPolicy]string{
+		RestartNever:     "never",
+		RestartOnFailure: "on-failure",
+		RestartAlways:    "always",
+	}
+	textToRestartPolicy map[string]RestartPolicy
+
 	stateToText = map[State]string{
 		StateStarting:      "starting",
 		StateRunning:       "running",
@@ -41,6 +50,11 @@ func init() {
 	for consoleType, text := range consoleTypeToText {
 		textToConsoleType[text] = consoleType
 	}
+	textToRestartPolicy = make(map[string]RestartPolicy,
+		len(restartPolicyToText))
+	for policy, text := range restartPolicyToText {
+		textToRestartPolicy[text] = policy
+	}
 	textToState = make(map[string]State, len(stateToText))
 	for state, text := range stateToText {
 		textToState[text] = state
@@ -135,6 +149,160 @@ func (consoleType *ConsoleType) UnmarshalText(text []byte) error {
 	}
 }
 
+func (rule *FirewallRule) CheckValid() error {
+	switch rule.Protocol {
+	case "", "icmp":
+		if len(rule.Ports) > 0 {
+			return errors.New("ports require the tcp or udp protocol")
+		}
+	case "tcp", "udp":
+	default:
+		return errors.New("unsupported protocol: " + rule.Protocol)
+	}
+	for _, cidr := range rule.Cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err == nil {
+			continue
+		}
+		if ipAddr := net.ParseIP(cidr); ipAddr == nil || ipAddr.To4() == nil {
+			return errors.New("bad IPv4 address or network: " + cidr)
+		}
+	}
+	for _, portRange := range rule.Ports {
+		if portRange.First < 1 {
+			return errors.New("port cannot be zero")
+		}
+		if portRange.Last != 0 && portRange.Last < portRange.First {
+			return fmt.Errorf("bad port range: %d-%d",
+				portRange.First, portRange.Last)
+		}
+	}
+	return nil
+}
+
+func (left *FirewallRule) Equal(right *FirewallRule) bool {
+	if !stringSlicesEqual(left.Cidrs, right.Cidrs) {
+		return false
+	}
+	if !left.PeerTags.Equal(right.PeerTags) {
+		return false
+	}
+	if len(left.Ports) != len(right.Ports) {
+		return false
+	}
+	for index, leftPortRange := range left.Ports {
+		if leftPortRange != right.Ports[index] {
+			return false
+		}
+	}
+	return left.Protocol == right.Protocol
+}
+
+func (rules *FirewallRules) CheckValid() error {
+	for _, rule := range rules.Egress {
+		if err := rule.CheckValid(); err != nil {
+			return fmt.Errorf("egress rule: %s", err)
+		}
+	}
+	for _, rule := range rules.Ingress {
+		if err := rule.CheckValid(); err != nil {
+			return fmt.Errorf("ingress rule: %s", err)
+		}
+	}
+	return nil
+}
+
+func (left *FirewallRules) Equal(right *FirewallRules) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if left.EgressDefaultDeny != right.EgressDefaultDeny {
+		return false
+	}
+	if left.IngressDefaultDeny != right.IngressDefaultDeny {
+		return false
+	}
+	if !firewallRulesEqual(left.Egress, right.Egress) {
+		return false
+	}
+	return firewallRulesEqual(left.Ingress, right.Ingress)
+}
+
+func firewallRulesEqual(left, right []FirewallRule) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index, leftRule := range left {
+		if !leftRule.Equal(&right[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (left *CrashInfo) Equal(right *CrashInfo) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if !left.LastCrashTime.Equal(right.LastCrashTime) {
+		return false
+	}
+	return left.ConsecutiveRestarts == right.ConsecutiveRestarts &&
+		left.LastCrashReason == right.LastCrashReason &&
+		left.NumCrashes == right.NumCrashes &&
+		left.NumRestarts == right.NumRestarts &&
+		left.RestartPending == right.RestartPending
+}
+
+func (policy *RestartPolicy) CheckValid() error {
+	if _, ok := restartPolicyToText[*policy]; !ok {
+		return errors.New(restartPolicyUnknown)
+	} else {
+		return nil
+	}
+}
+
+func (policy RestartPolicy) MarshalText() ([]byte, error) {
+	if text := policy.String(); text == restartPolicyUnknown {
+		return nil, errors.New(text)
+	} else {
+		return []byte(text), nil
+	}
+}
+
+func (policy *RestartPolicy) Set(value string) error {
+	if val, ok := textToRestartPolicy[value]; !ok {
+		return errors.New(restartPolicyUnknown)
+	} else {
+		*policy = val
+		return nil
+	}
+}
+
+func (policy RestartPolicy) String() string {
+	if str, ok := restartPolicyToText[policy]; !ok {
+		return restartPolicyUnknown
+	} else {
+		return str
+	}
+}
+
+func (policy *RestartPolicy) UnmarshalText(text []byte) error {
+	txt := string(text)
+	if val, ok := textToRestartPolicy[txt]; ok {
+		*policy = val
+		return nil
+	} else {
+		return errors.New("unknown RestartPolicy: " + txt)
+	}
+}
+
+func (left *Snapshot) Equal(right *Snapshot) bool {
+	return left.CreatedOn.Equal(right.CreatedOn) &&
+		left.Name == right.Name &&
+		left.RootOnly == right.RootOnly &&
+		left.Size == right.Size
+}
+
 func (state State) MarshalText() ([]byte, error) {
 	if text := state.String(); text == stateUnknown {
 		return nil, errors.New(text)
@@ -216,15 +384,27 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !left.Address.Equal(&right.Address) {
 		return false
 	}
+	if left.CloudInitSeed != right.CloudInitSeed {
+		return false
+	}
 	if left.ConsoleType != right.ConsoleType {
 		return false
 	}
+	if !left.CrashInfo.Equal(right.CrashInfo) {
+		return false
+	}
 	if left.DestroyProtection != right.DestroyProtection {
 		return false
 	}
 	if left.DisableVirtIO != right.DisableVirtIO {
 		return false
 	}
+	if left.FirewallDisabled != right.FirewallDisabled {
+		return false
+	}
+	if !left.FirewallRules.Equal(right.FirewallRules) {
+		return false
+	}
 	if left.Hostname != right.Hostname {
 		return false
 	}
@@ -234,6 +414,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.ImageURL != right.ImageURL {
 		return false
 	}
+	if left.MaxRestarts != right.MaxRestarts {
+		return false
+	}
 	if left.MemoryInMiB != right.MemoryInMiB {
 		return false
 	}
@@ -246,6 +429,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !stringSlicesEqual(left.OwnerUsers, right.OwnerUsers) {
 		return false
 	}
+	if left.RestartPolicy != right.RestartPolicy {
+		return false
+	}
 	if left.SpreadVolumes != right.SpreadVolumes {
 		return false
 	}
@@ -266,6 +452,14 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !stringSlicesEqual(left.SecondarySubnetIDs, right.SecondarySubnetIDs) {
 		return false
 	}
+	if len(left.Snapshots) != len(right.Snapshots) {
+		return false
+	}
+	for index, leftSnapshot := range left.Snapshots {
+		if !leftSnapshot.Equal(&right.Snapshots[index]) {
+			return false
+		}
+	}
 	if left.SubnetId != right.SubnetId {
 		return false
 	}
@@ -291,6 +485,15 @@ func (volumeFormat VolumeFormat) MarshalText() ([]byte, error) {
 	}
 }
 
+func (volumeFormat *VolumeFormat) Set(value string) error {
+	if val, ok := textToVolumeFormat[value]; !ok {
+		return errors.New(volumeFormatUnknown)
+	} else {
+		*volumeFormat = val
+		return nil
+	}
+}
+
 func (volumeFormat VolumeFormat) String() string {
 	if text, ok := volumeFormatToText[volumeFormat]; ok {
 		return text
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/vulnerability"
 )
 
 type AddImageRequest struct {
@@ -14,6 +15,28 @@ type AddImageRequest struct {
 
 type AddImageResponse struct{}
 
+// AuditLogEntry records a mutation. Entries form a hash chain: Hash is computed
+// over all other fields (including PreviousHash), so modifying or removing an
+// entry invalidates all subsequent entries. Entries replicated from another
+// imageserver have ReplicatedFrom set and record the sequence number, time,
+// previous hash and hash of the entry on that server, so that the original
+// entry may be reconstructed and verified.
+type AuditLogEntry struct {
+	Sequence             uint64
+	Time                 time.Time
+	Username             string
+	Method               string
+	Arguments            map[string]string
+	Error                string
+	ReplicatedFrom       string
+	OriginalSequence     uint64
+	OriginalTime         time.Time
+	OriginalPreviousHash hash.Hash
+	OriginalHash         hash.Hash
+	PreviousHash         hash.Hash
+	Hash                 hash.Hash
+}
+
 type ChangeImageExpirationRequest struct {
 	ExpiresAt time.Time
 	ImageName string
@@ -59,6 +82,18 @@ type DeleteUnreferencedObjectsRequest struct {
 
 type DeleteUnreferencedObjectsResponse struct{}
 
+type ExportOciImageRequest struct {
+	ImageName    string
+	MaxLayerSize uint64 // If non-zero, split into layers of about this size.
+	Tag          string // Optional reference, such as "name:latest".
+}
+
+// The response is followed by Size bytes of OCI image layout tarball data.
+type ExportOciImageResponse struct {
+	Error string
+	Size  uint64
+}
+
 type FindLatestImageRequest struct {
 	DirectoryName        string
 	IgnoreExpiringImages bool
@@ -69,6 +104,16 @@ type FindLatestImageResponse struct {
 	Error     string
 }
 
+type GetAuditLogRequest struct {
+	StartSequence uint64
+	MaxEntries    uint // Server may return fewer entries.
+}
+
+type GetAuditLogResponse struct {
+	Entries []AuditLogEntry
+	Error   string
+}
+
 type GetImageExpirationRequest struct {
 	ImageName string
 }
@@ -78,6 +123,15 @@ type GetImageExpirationResponse struct {
 	ExpiresAt time.Time
 }
 
+type GetImageVulnerabilitiesRequest struct {
+	ImageNames []string
+}
+
+type GetImageVulnerabilitiesResponse struct {
+	Error  string
+	Images []ImageVulnerabilities // Same order as request.
+}
+
 type GetImageRequest struct {
 	ImageName                  string
 	IgnoreFilesystem           bool
@@ -93,6 +147,7 @@ const (
 	OperationAddImage = iota
 	OperationDeleteImage
 	OperationMakeDirectory
+	OperationAuditLogEntry
 )
 
 // The GetImageUpdates() RPC is fully streamed.
@@ -100,9 +155,16 @@ const (
 // The server sends a stream of ImageUpdate messages.
 
 type ImageUpdate struct {
-	Name      string // "" signifies initial list is sent, changes to follow.
-	Directory *image.Directory
-	Operation uint
+	Name          string // "" signifies initial list is sent, changes to follow.
+	Directory     *image.Directory
+	Operation     uint
+	AuditLogEntry *AuditLogEntry
+}
+
+type ImageVulnerabilities struct {
+	ImageName       string
+	Error           string
+	Vulnerabilities []vulnerability.Match
 }
 
 // The ListDirectories() RPC is fully streamed.
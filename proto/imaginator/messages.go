@@ -11,6 +11,8 @@ type BuildImageRequest struct {
 	ExpiresIn             time.Duration
 	GitBranch             string
 	MaxSourceAge          time.Duration
+	Priority              int // Higher priorities are built first.
+	Reproducible          bool
 	ReturnImage           bool
 	StreamBuildLog        bool
 	StreamName            string
@@ -23,3 +25,70 @@ type BuildImageResponse struct {
 	BuildLog    []byte
 	ErrorString string
 }
+
+type BuildQueueEntry struct {
+	Id         uint64
+	StreamName string
+	GitBranch  string `json:",omitempty"`
+	Priority   int    `json:",omitempty"`
+	QueuedAt   time.Time
+	StartedAt  time.Time `json:",omitempty"` // Zero if not yet started.
+	Requesters []string  `json:",omitempty"` // Empty for automatic builds.
+}
+
+type BuildRecord struct {
+	CacheResult   string `json:",omitempty"`
+	Error         string `json:",omitempty"`
+	FinishTime    time.Time
+	Id            string
+	ImageName     string `json:",omitempty"`
+	Request       BuildImageRequest
+	ResourceUsage *BuildResourceUsage `json:",omitempty"`
+	StartTime     time.Time
+	StreamName    string
+	Username      string `json:",omitempty"` // Empty for automatic builds.
+}
+
+type BuildResourceUsage struct {
+	CpuTime      time.Duration
+	IoReadBytes  uint64 `json:",omitempty"`
+	IoWriteBytes uint64 `json:",omitempty"`
+	MaxMemory    uint64 `json:",omitempty"` // Bytes.
+	MaxPids      uint64 `json:",omitempty"`
+	OomKills     uint64 `json:",omitempty"`
+	TimedOut     bool   `json:",omitempty"`
+}
+
+type CancelBuildRequest struct {
+	Id uint64
+}
+
+type CancelBuildResponse struct {
+	Error string
+}
+
+type GetBuildHistoryRequest struct {
+	StreamName string
+	MaxRecords uint // Zero means all records.
+}
+
+type GetBuildHistoryResponse struct {
+	Error   string
+	Records []BuildRecord // Most recent first.
+}
+
+type GetBuildLogRequest struct {
+	StreamName string
+	Id         string
+}
+
+type GetBuildLogResponse struct {
+	BuildLog []byte
+	Error    string
+}
+
+type GetBuildQueueRequest struct{}
+
+type GetBuildQueueResponse struct {
+	Entries []BuildQueueEntry
+}
@@ -40,6 +40,36 @@ type GetObjectsResponse struct {
 	ObjectSizes    []uint64
 } // Object datas are streamed afterwards.
 
+type GetScrubStatusRequest struct{}
+
+type GetScrubStatusResponse struct {
+	Error  string
+	Status ScrubStatus
+}
+
+type QuarantinedObject struct {
+	Hash           hash.Hash
+	Length         uint64
+	QuarantineTime time.Time
+	Reason         string
+}
+
+type ScrubStatus struct {
+	Enabled              bool
+	PassInProgress       bool
+	CurrentPassStartTime time.Time
+	LastPassStartTime    time.Time
+	LastPassFinishTime   time.Time
+	NumPassesCompleted   uint64
+	NumObjectsToScan     uint64 // Current or last pass.
+	NumObjectsScanned    uint64 // Current or last pass.
+	NumBytesScanned      uint64 // Current or last pass.
+	NumCorruptObjects    uint64 // Since startup.
+	NumRepairedObjects   uint64 // Since startup.
+	NumRepairFailures    uint64 // Since startup.
+	QuarantinedObjects   []QuarantinedObject
+}
+
 type TestBandwidthRequest struct {
 	Duration     time.Duration // Ignored when sending to server.
 	ChunkSize    uint          // Maximum permitted: 65535.